@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+// color returns the color value of the red and green brightness from 0 (off) to 3 (full)
+func color(red, green byte) byte {
+	return 0x10*green + 12 + red
+}
+
+// parseColor reads a color name like "AmberFull" or "ColorAmberFull" or the red and green brightness like "r3g0"
+func parseColor(text string) (byte, error) {
+	if value, ok := lm.ColorNames[text]; ok {
+		return value, nil
+	}
+	if value, ok := lm.ColorNames["Color"+text]; ok {
+		return value, nil
+	}
+
+	upper := strings.ToUpper(text)
+	if len(upper) == 4 && upper[0] == 'R' && upper[2] == 'G' {
+		r, err1 := strconv.ParseUint(upper[1:2], 10, 8)
+		g, err2 := strconv.ParseUint(upper[3:4], 10, 8)
+		if err1 == nil && err2 == nil && r <= 3 && g <= 3 {
+			return color(byte(r), byte(g)), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid color \"%s\", must be a name like AmberFull or rXgY with X and Y between 0 and 3", text)
+}
+
+// parseButton reads a button name like "A" (A-H), "A1" (A1-H8) or "L1" (L1-L8)
+func parseButton(text string) (byte, error) {
+	name := strings.ToUpper(text)
+	id := "Button" + name
+	if strings.HasPrefix(name, "L") && len(name) == 2 {
+		id = "LiveButton" + name[1:]
+	}
+
+	if value, ok := lm.ButtonValues[id]; ok {
+		return value, nil
+	}
+	return 0, fmt.Errorf("invalid button \"%s\", valid names are A-H, A1-H8 and L1-L8", text)
+}
+
+// parseButtons reads a comma separated list of buttons and colors like "a2:r3g2,c5:AmberFull"
+func parseButtons(text string) (map[byte]byte, error) {
+	buttons := make(map[byte]byte)
+	for _, entry := range strings.Split(text, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid button \"%s\", must have the format X:Y with X being the button name and Y being the color", entry)
+		}
+
+		button, err := parseButton(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		value, err := parseColor(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		buttons[button] = value
+	}
+	return buttons, nil
+}
+
+// artColors are the colors of the characters of ASCII art, "." and " " are off
+var artColors = map[rune]byte{
+	'.': lm.ColorOff, ' ': lm.ColorOff,
+	'r': lm.ColorRedLow, 'R': lm.ColorRedFull,
+	'g': lm.ColorGreenLow, 'G': lm.ColorGreenFull,
+	'a': lm.ColorAmberLow, 'A': lm.ColorAmberFull,
+	'y': lm.ColorYellowFull, 'Y': lm.ColorYellowFull,
+}
+
+// artRow sets the grid buttons of a row of ASCII art in the frame. Missing columns stay off.
+func artRow(row int, line string, frame map[byte]byte) error {
+	if row >= lm.GridSize {
+		return fmt.Errorf("more than %d rows", lm.GridSize)
+	}
+	columns := []rune(line)
+	if len(columns) > lm.GridSize {
+		return fmt.Errorf("more than %d columns in \"%s\"", lm.GridSize, line)
+	}
+
+	for column, c := range columns {
+		value, ok := artColors[c]
+		if !ok {
+			return fmt.Errorf("invalid character '%c' in \"%s\", valid are . r R g G a A y", c, line)
+		}
+		frame[lm.GridButton(byte(row), byte(column))] = value
+	}
+	return nil
+}
+
+// parseArt reads a grid of up to 8 lines of 8 characters like "..RR..GG"
+func parseArt(input io.Reader) (map[byte]byte, error) {
+	frame := make(map[byte]byte)
+	scanner := bufio.NewScanner(input)
+	for row := 0; scanner.Scan(); row++ {
+		if err := artRow(row, strings.TrimRight(scanner.Text(), "\r"), frame); err != nil {
+			return nil, fmt.Errorf("line %d: %s", row+1, err.Error())
+		}
+	}
+	return frame, scanner.Err()
+}
+
+// level converts a color channel of an image to a brightness from 0 to 3
+func level(value uint32) byte {
+	return byte((value*3 + 0x7fff) / 0xffff)
+}
+
+// quantize scales the image to the grid and converts the red and green channels of every cell to the brightness of
+// its red and green LEDs. Blue is ignored, transparent pixels are off.
+func quantize(img image.Image) map[byte]byte {
+	bounds := img.Bounds()
+	frame := make(map[byte]byte)
+
+	for row := 0; row < lm.GridSize; row++ {
+		top := bounds.Min.Y + row*bounds.Dy()/lm.GridSize
+		bottom := bounds.Min.Y + (row+1)*bounds.Dy()/lm.GridSize
+		if bottom <= top {
+			bottom = top + 1
+		}
+
+		for column := 0; column < lm.GridSize; column++ {
+			left := bounds.Min.X + column*bounds.Dx()/lm.GridSize
+			right := bounds.Min.X + (column+1)*bounds.Dx()/lm.GridSize
+			if right <= left {
+				right = left + 1
+			}
+
+			// Average of all pixels of the cell, colors are premultiplied by alpha
+			var red, green, count uint64
+			for y := top; y < bottom; y++ {
+				for x := left; x < right; x++ {
+					r, g, _, _ := img.At(x, y).RGBA()
+					red += uint64(r)
+					green += uint64(g)
+					count++
+				}
+			}
+			frame[lm.GridButton(byte(row), byte(column))] = color(level(uint32(red/count)), level(uint32(green/count)))
+		}
+	}
+	return frame
+}
+
+// loadImage reads a PNG file or a file with ASCII art
+func loadImage(path string) (map[byte]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if strings.ToLower(filepath.Ext(path)) == ".png" {
+		img, err := png.Decode(file)
+		if err != nil {
+			return nil, err
+		}
+		return quantize(img), nil
+	}
+	return parseArt(file)
+}
+
+// patterns are built-in images in ASCII art
+var patterns = map[string]string{
+	"checkerboard": "RGRGRGRG\nGRGRGRGR\nRGRGRGRG\nGRGRGRGR\nRGRGRGRG\nGRGRGRGR\nRGRGRGRG\nGRGRGRGR",
+	"border":       "AAAAAAAA\nA......A\nA......A\nA......A\nA......A\nA......A\nA......A\nAAAAAAAA",
+	"cross":        "R......R\n.R....R.\n..R..R..\n...RR...\n...RR...\n..R..R..\n.R....R.\nR......R",
+	"gradient":     "RRrAagGG\nRRrAagGG\nRRrAagGG\nRRrAagGG\nRRrAagGG\nRRrAagGG\nRRrAagGG\nRRrAagGG",
+	"heart":        "........\n.RR..RR.\nRRRRRRRR\nRRRRRRRR\n.RRRRRR.\n..RRRR..\n...RR...\n........",
+	"smiley":       "..YYYY..\n.Y....Y.\nY.G..G.Y\nY......Y\nY.R..R.Y\nY..RR..Y\n.Y....Y.\n..YYYY..",
+	"up":           "...GG...\n..GGGG..\n.GGGGGG.\nGG.GG.GG\n...GG...\n...GG...\n...GG...\n...GG...",
+	"down":         "...RR...\n...RR...\n...RR...\n...RR...\nRR.RR.RR\n.RRRRRR.\n..RRRR..\n...RR...",
+}
+
+// patternNames returns the names of the patterns in ascending order
+func patternNames() []string {
+	names := make([]string, 0, len(patterns))
+	for name := range patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pattern returns the frame of a built-in pattern
+func pattern(name string) (map[byte]byte, error) {
+	art, ok := patterns[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown pattern \"%s\", valid are %s", name, strings.Join(patternNames(), ", "))
+	}
+	return parseArt(strings.NewReader(art))
+}
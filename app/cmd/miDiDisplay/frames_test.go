@@ -0,0 +1,132 @@
+package main
+
+import (
+	"image"
+	imgcolor "image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+func TestParseButtons(t *testing.T) {
+	buttons, err := parseButtons("a2:r3g2, c5:AmberFull,H:ColorRedLow,l1:R0G3")
+	if err != nil {
+		t.Fatalf("Buttons not parsed: %s", err.Error())
+	}
+	expected := map[byte]byte{
+		lm.ButtonA2:    0x2f,
+		lm.ButtonC5:    lm.ColorAmberFull,
+		lm.ButtonH:     lm.ColorRedLow,
+		lm.LiveButton1: lm.ColorGreenFull,
+	}
+	if len(buttons) != len(expected) {
+		t.Errorf("Wrong buttons: %v", buttons)
+	}
+	for button, value := range expected {
+		if buttons[button] != value {
+			t.Errorf("Wrong color of %s: Got %d, expected %d", lm.ButtonNames[button], buttons[button], value)
+		}
+	}
+
+	for _, invalid := range []string{"a2", "a9:RedLow", "a2:Blue", "a2:r4g0", "a2:r1", "l9:r1g1"} {
+		if _, err := parseButtons(invalid); err == nil {
+			t.Errorf("Invalid buttons %s parsed", invalid)
+		}
+	}
+}
+
+func TestParseArt(t *testing.T) {
+	frame, err := parseArt(strings.NewReader("R.......\r\n.g\n\n.......A"))
+	if err != nil {
+		t.Fatalf("Art not parsed: %s", err.Error())
+	}
+	expected := map[byte]byte{lm.ButtonA1: lm.ColorRedFull, lm.ButtonB1: lm.ColorOff, lm.ButtonB2: lm.ColorGreenLow, lm.ButtonD8: lm.ColorAmberFull}
+	for button, value := range expected {
+		if frame[button] != value {
+			t.Errorf("Wrong color of %s: Got %d, expected %d", lm.ButtonNames[button], frame[button], value)
+		}
+	}
+	if _, ok := frame[lm.ButtonC1]; ok {
+		t.Errorf("Empty line set buttons")
+	}
+
+	for _, invalid := range []string{"RRRRRRRRR", "..x", strings.Repeat(".\n", 9)} {
+		if _, err := parseArt(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Invalid art %q parsed", invalid)
+		}
+	}
+
+	for _, name := range patternNames() {
+		if frame, err := pattern(name); err != nil || len(frame) != 64 {
+			t.Errorf("Pattern %s not valid: %v", name, err)
+		}
+	}
+	if _, err := pattern("spiral"); err == nil {
+		t.Errorf("Unknown pattern found")
+	}
+}
+
+func TestLoadImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mididisplay")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// 16x16 image, so every button shows the average of 2x2 pixels
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	img.Set(0, 0, imgcolor.NRGBA{255, 0, 0, 255})
+	img.Set(1, 0, imgcolor.NRGBA{255, 0, 0, 255})
+	img.Set(0, 1, imgcolor.NRGBA{255, 0, 0, 255})
+	img.Set(1, 1, imgcolor.NRGBA{255, 0, 0, 255})
+	for x := 14; x < 16; x++ {
+		for y := 0; y < 2; y++ {
+			img.Set(x, y, imgcolor.NRGBA{0, 255, 255, 255}) // Blue is ignored
+		}
+	}
+	img.Set(2, 2, imgcolor.NRGBA{255, 255, 0, 255}) // One of four pixels
+	img.Set(0, 14, imgcolor.NRGBA{255, 128, 0, 255})
+	img.Set(1, 14, imgcolor.NRGBA{255, 128, 0, 255})
+	img.Set(0, 15, imgcolor.NRGBA{255, 128, 0, 255})
+	img.Set(1, 15, imgcolor.NRGBA{255, 128, 0, 128}) // Half transparent
+
+	path := filepath.Join(dir, "image.png")
+	file, _ := os.Create(path)
+	png.Encode(file, img)
+	file.Close()
+
+	frame, err := loadImage(path)
+	if err != nil {
+		t.Fatalf("Image not loaded: %s", err.Error())
+	}
+	expected := map[byte]byte{
+		lm.ButtonA1: lm.ColorRedFull,
+		lm.ButtonA2: lm.ColorOff,
+		lm.ButtonA8: lm.ColorGreenFull,
+		lm.ButtonB2: color(1, 1),
+		lm.ButtonH1: color(3, 1),
+	}
+	if len(frame) != 64 {
+		t.Errorf("Wrong number of buttons: %d", len(frame))
+	}
+	for button, c := range expected {
+		if frame[button] != c {
+			t.Errorf("Wrong color of %s: Got %d, expected %d", lm.ButtonNames[button], frame[button], c)
+		}
+	}
+
+	path = filepath.Join(dir, "image.txt")
+	ioutil.WriteFile(path, []byte("G"), 0644)
+	if frame, err := loadImage(path); err != nil || frame[lm.ButtonA1] != lm.ColorGreenFull {
+		t.Errorf("Art not loaded: %v", err)
+	}
+
+	if _, err := loadImage(filepath.Join(dir, "missing.png")); err == nil {
+		t.Errorf("Missing image loaded")
+	}
+}
@@ -1,109 +1,120 @@
 package main
 
+/**
+ * miDiDisplay shows colors, images, patterns and text on a Launchpad Mini, for example
+ *
+ *   miDiDisplay buttons a2:r3g2,c5:AmberFull
+ *   miDiDisplay image smiley.png
+ *   some-script | miDiDisplay stream
+ */
+
 import (
 	"flag"
 	"fmt"
-	"strconv"
+	"log"
+	"os"
 	"strings"
 
 	lm "github.com/sirion/gomidi/lib/launchpadmini"
 )
 
-func main() {
-
-	/**
-	--buttons="a2:r3g2,c5:r2g1"
-	--buttons="a2:AmberFull,c5:Yellow"
-	*/
-
-	device := flag.String("device", "auto", "Midi device for Launchpad")
-	buttonStr := flag.String(
-		"buttons",
-		"",
-		"List of comma separated buttons and their color values to set on the Launchpad.\n"+
-			"Buttons and color values are separated by \":\".\n"+
-			"Valid buttons are A-H, A1-H8 and L1-L8\n"+
-			"Colors are described as their red and green values: r0g3 means full green, r3g0 means full red\n"+
-			"Alternatively supported color names:\n"+
-			"    ColorOff,\n"+
-			"    ColorRedLow, ColorRedFull,\n"+
-			"    ColorGreenLow, ColorGreenFull,\n"+
-			"    ColorAmberLow, ColorAmberFull,\n"+
-			"    ColorYellowFull\n"+
-			"\n"+
-			"Examples:\n"+
-			"  a2:AmberFull - sets button A2 to the color amber with full brightness\n"+
-			"  g3:r3g0      - sets button G3 to the color red with full brightness",
-	)
-	clear := flag.Bool("clear", false, "Start with all buttons turned off")
-	help := flag.Bool("help", false, "Show this help")
-	flag.Parse()
-
-	lp := lm.New(*device)
+const usage = `Usage: %s COMMAND [options] [ARGUMENTS]
+
+Commands:
+  buttons LIST   Set buttons to colors, LIST is comma separated like "a2:AmberFull,g3:r3g0"
+                 Valid buttons are A-H, A1-H8 and L1-L8
+                 Colors are names (Off, RedLow, RedFull, GreenLow, GreenFull, AmberLow, AmberFull, YellowFull)
+                 or red and green values: r0g3 means full green, r3g0 means full red
+  clear          Turn all buttons off
+  image FILE     Show an 8x8 PNG (other sizes are scaled) or ASCII art file on the grid. ASCII art has up to
+                 8 lines of 8 characters: . (off), r/R (red low/full), g/G (green), a/A (amber), y (yellow)
+  pattern NAME   Show a built-in pattern: %s
+  text TEXT      Scroll a text over the launchpad
+  stream         Read frames from stdin: ASCII art followed by an empty line, button lists like in the
+                 buttons command or "clear"
+
+Options:
+`
+
+func printUsage(flags *flag.FlagSet) {
+	fmt.Fprintf(os.Stderr, usage, os.Args[0], strings.Join(patternNames(), ", "))
+	flags.PrintDefaults()
+}
 
-	showHelp := *help || flag.NFlag() == 0
-	if showHelp {
-		fmt.Printf("Available command line options:\n")
-		flag.PrintDefaults()
-		return
+func main() {
+	name := ""
+	if len(os.Args) > 1 {
+		name = os.Args[1]
 	}
 
-	if *clear {
-		// TODO: If flashing is used reset cannot be used to clear the buttons without disabling flashing
-		lp.Reset()
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	device := flags.String("device", "auto", "Midi device for Launchpad")
+	clearOthers := flags.Bool("clear", false, "Turn all other buttons off (buttons)")
+	colorName := flags.String("color", "GreenFull", "Color of the text (text)")
+	flags.Usage = func() { printUsage(flags) }
+
+	arguments := map[string]int{"buttons": 1, "clear": 0, "image": 1, "pattern": 1, "text": 1, "stream": 0}
+	count, ok := arguments[name]
+	if !ok {
+		if strings.TrimLeft(name, "-") == "help" {
+			printUsage(flags)
+			return
+		}
+		if name != "" {
+			fmt.Fprintf(os.Stderr, "Unknown command %s\n\n", name)
+		}
+		printUsage(flags)
+		os.Exit(1)
 	}
-
-	if *buttonStr != "" {
-		setButtonsFromString(lp, *buttonStr)
+	flags.Parse(os.Args[2:])
+	if flags.NArg() != count {
+		printUsage(flags)
+		os.Exit(1)
+	}
+	argument := flags.Arg(0)
+
+	// Everything is read before the launchpad is opened, so invalid input does not change the lights
+	var buttons map[byte]byte
+	var err error
+	switch name {
+	case "buttons":
+		buttons, err = parseButtons(argument)
+	case "image":
+		buttons, err = loadImage(argument)
+	case "pattern":
+		buttons, err = pattern(argument)
+	}
+	if err != nil {
+		log.Fatalf("Invalid %s: %s", name, err.Error())
+	}
+	textColor, err := parseColor(*colorName)
+	if err != nil {
+		log.Fatalf("Invalid text color: %s", err.Error())
 	}
 
-}
-
-func setButtonsFromString(lp *lm.LaunchpadMini, buttonStr string) {
-
-	buttons := strings.Split(buttonStr, ",")
-
-	for _, button := range buttons {
-		parts := strings.Split(button, ":")
-
-		if len(parts) != 2 || len(parts[0]) > 2 || len(parts[1]) < 4 {
-			fmt.Printf("Invalid button \"%s\", must have the format X:Y with X being the button name and Y being the color\n", button)
-			continue
-		}
+	lp := lm.New(*device)
+	defer lp.Close()
 
-		buttonName := strings.ToUpper(parts[0])
-		var buttonID string
-		if buttonName[0:1] == "L" {
-			buttonID = "LiveButton" + buttonName[1:2]
-		} else {
-			buttonID = "Button" + buttonName
+	switch name {
+	case "buttons":
+		if *clearOthers {
+			lp.RapidUpdate(buttons)
+			break
 		}
-
-		buttonValue, ok := lm.ButtonValues[buttonID]
-
-		if !ok {
-			fmt.Printf("Invalid button name \"%s\". Valid Names are A-H, A1-H8, L1-L8\n", buttonName)
-			continue
+		for button, value := range buttons {
+			lp.Button(button, value)
 		}
-
-		color := parts[1]
-		colorValue, ok := lm.ColorNames["Color"+color]
-		if !ok {
-			color := strings.ToUpper(color)
-
-			r, err1 := strconv.ParseInt(color[1:2], 10, 8)
-			g, err2 := strconv.ParseInt(color[3:4], 10, 8)
-
-			if color[0:1] != "R" || color[2:3] != "G" || err1 != nil || err2 != nil || r > 3 || g > 3 || r < 0 || g < 0 {
-				fmt.Printf("Invalid color value \"%s\" for button \"%s\". Please provide either a valid name or a value in the form of rXgY, with X and Y between 0 and 3\n", parts[1], parts[0])
-				continue
-			}
-
-			colorValue = byte((0x10 * g) + 12 + r)
+	case "clear":
+		// Reset also turns off flashing, which the other commands do not use
+		lp.Reset()
+	case "image", "pattern":
+		lp.RapidUpdate(buttons)
+	case "text":
+		lp.Text(argument, textColor)
+	case "stream":
+		if err := stream(lp, os.Stdin); err != nil {
+			lp.Close()
+			log.Fatalf("Invalid frame: %s", err.Error())
 		}
-
-		lp.Button(buttonValue, colorValue)
-
 	}
-
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// display is the part of the launchpad that shows frames
+type display interface {
+	Button(button, color byte)
+	RapidUpdate(buttons map[byte]byte)
+}
+
+// stream shows the frames read from input until it ends:
+//
+//   - Up to 8 lines of ASCII art followed by an empty line replace all buttons
+//   - A line of buttons like "a1:GreenFull,h:r3g0" changes only these buttons
+//   - "clear" turns all buttons off
+func stream(d display, input io.Reader) error {
+	scanner := bufio.NewScanner(input)
+	frame := make(map[byte]byte)
+	rows := 0
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+
+		switch {
+		case text == "":
+			if rows > 0 {
+				d.RapidUpdate(frame)
+				frame = make(map[byte]byte)
+				rows = 0
+			}
+		case text == "clear":
+			d.RapidUpdate(map[byte]byte{})
+		case strings.Contains(text, ":"):
+			buttons, err := parseButtons(text)
+			if err != nil {
+				return fmt.Errorf("line %d: %s", line, err.Error())
+			}
+			for button, value := range buttons {
+				d.Button(button, value)
+			}
+		default:
+			if err := artRow(rows, text, frame); err != nil {
+				return fmt.Errorf("line %d: %s", line, err.Error())
+			}
+			rows++
+		}
+	}
+
+	if rows > 0 {
+		d.RapidUpdate(frame)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+// fakeDisplay records the updates like "A1=60" for buttons and "frame:3" for frames with 3 buttons on
+type fakeDisplay struct {
+	updates []string
+}
+
+func (d *fakeDisplay) Button(button, color byte) {
+	d.updates = append(d.updates, fmt.Sprintf("%s=%d", lm.ButtonNames[button], color))
+}
+
+func (d *fakeDisplay) RapidUpdate(buttons map[byte]byte) {
+	on := 0
+	for _, value := range buttons {
+		if value != lm.ColorOff {
+			on++
+		}
+	}
+	d.updates = append(d.updates, fmt.Sprintf("frame:%d", on))
+}
+
+func TestStream(t *testing.T) {
+	input := strings.Join([]string{
+		"RR",
+		"..G",
+		"",
+		"",
+		"a1:GreenFull",
+		"clear",
+		"AAAAAAAA",
+	}, "\n")
+
+	d := &fakeDisplay{}
+	if err := stream(d, strings.NewReader(input)); err != nil {
+		t.Fatalf("Stream failed: %s", err.Error())
+	}
+	expected := "frame:3, ButtonA1=60, frame:0, frame:8"
+	if strings.Join(d.updates, ", ") != expected {
+		t.Errorf("Wrong updates: Got %v, expected %s", d.updates, expected)
+	}
+
+	for input, message := range map[string]string{
+		"RR\nxx\n":               "line 2: invalid character",
+		"a1:Blue":                "line 1: invalid color",
+		strings.Repeat("R\n", 9): "line 9: more than 8 rows",
+	} {
+		err := stream(&fakeDisplay{}, strings.NewReader(input))
+		if err == nil || !strings.HasPrefix(err.Error(), message) {
+			t.Errorf("Wrong error for %q: %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultTimeout is used for commands and requests without a configured timeout
+const defaultTimeout = 30 * time.Second
+
+// Event describes what started a macro, its fields can be used in templates like {{.Button}}
+type Event struct {
+	Button string
+	Time   time.Time
+
+	scripts *Scripts
+	output  io.Writer // Midi output of midi steps
+	backend Backend   // Presses keys and moves the mouse, robotgo if nil
+}
+
+// input returns the backend of key and mouse steps
+func (e Event) input() Backend {
+	if e.backend == nil {
+		return robotgoBackend{}
+	}
+	return e.backend
+}
+
+func (s *Step) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return time.Duration(s.Timeout) * time.Millisecond
+	}
+	return defaultTimeout
+}
+
+// runCommand executes the command of the step directly or with "sh -c" if Shell is set.
+// The error contains the output of failed commands.
+func (s *Step) runCommand() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout())
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if s.Shell {
+		cmd = exec.CommandContext(ctx, "sh", "-c", s.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, s.Command, s.Args...)
+	}
+
+	cmd.Dir = s.Dir
+	if len(s.Env) > 0 {
+		cmd.Env = os.Environ()
+		for key, value := range s.Env {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("command %s timed out after %s", s.Command, s.timeout())
+	}
+	if err != nil {
+		return fmt.Errorf("command %s failed: %s: %s", s.Command, err.Error(), strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// compileBody parses the body template of an HTTP step
+func (s *Step) compileBody() error {
+	body, err := template.New(s.URL).Option("missingkey=error").Parse(s.Body)
+	if err != nil {
+		return err
+	}
+	s.body = body
+	return nil
+}
+
+// runRequest sends the templated body to the URL of the step. Responses with a status other
+// than 2xx are errors.
+func (s *Step) runRequest(event Event) error {
+	if s.body == nil {
+		if err := s.compileBody(); err != nil {
+			return err
+		}
+	}
+
+	body := &bytes.Buffer{}
+	if err := s.body.Execute(body, event); err != nil {
+		return fmt.Errorf("error in body template: %s", err.Error())
+	}
+
+	method := s.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	request, err := http.NewRequest(method, s.URL, body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range s.Headers {
+		request.Header.Set(key, value)
+	}
+
+	client := &http.Client{Timeout: s.timeout()}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("%s %s returned %s: %s", method, s.URL, response.Status, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
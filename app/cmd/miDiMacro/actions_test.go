@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "midimacro")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	macro := Macro{Steps: []Step{
+		{Type: StepCommand, Command: "touch", Args: []string{"created"}, Dir: dir},
+		{Type: StepCommand, Command: "echo $GREETING > greeting", Shell: true, Dir: dir, Env: map[string]string{"GREETING": "hello"}},
+	}}
+	if err := macro.Validate(); err != nil {
+		t.Fatalf("Valid macro not accepted: %s", err.Error())
+	}
+	if !macro.Reports() {
+		t.Errorf("Macro with commands does not report results")
+	}
+
+	if err := macro.Run(Event{}); err != nil {
+		t.Fatalf("Error running commands: %s", err.Error())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "created")); err != nil {
+		t.Errorf("Command not executed in directory: %s", err.Error())
+	}
+	if greeting, _ := ioutil.ReadFile(filepath.Join(dir, "greeting")); string(greeting) != "hello\n" {
+		t.Errorf("Wrong environment: Got \"%s\"", greeting)
+	}
+
+	// Without shell, the command is not interpreted
+	failing := []Step{
+		{Type: StepCommand, Command: "echo $GREETING > greeting"},
+		{Type: StepCommand, Command: "sh", Args: []string{"-c", "echo broken; exit 3"}},
+		{Type: StepCommand, Command: "sleep", Args: []string{"5"}, Timeout: 50},
+	}
+	for i, step := range failing {
+		start := time.Now()
+		err := step.run(Event{})
+		if err == nil {
+			t.Errorf("Failing command %d returned no error", i)
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Errorf("Command %d was not stopped by the timeout", i)
+		}
+	}
+
+	if err := failing[1].run(Event{}); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Error does not contain the output: %v", err)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	var method, body, token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		method, body, token = r.Method, string(data), r.Header.Get("X-Token")
+		if r.URL.Path == "/fail" {
+			http.Error(w, "light not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	step := Step{
+		Type:    StepHTTP,
+		URL:     server.URL + "/lights",
+		Headers: map[string]string{"X-Token": "secret"},
+		Body:    `{"button": "{{.Button}}", "on": true}`,
+	}
+	if err := step.validate(); err != nil {
+		t.Fatalf("Valid step not accepted: %s", err.Error())
+	}
+
+	if err := step.run(Event{Button: "ButtonA1"}); err != nil {
+		t.Fatalf("Error sending request: %s", err.Error())
+	}
+	if method != http.MethodPost || body != `{"button": "ButtonA1", "on": true}` || token != "secret" {
+		t.Errorf("Wrong request: %s %s (token %s)", method, body, token)
+	}
+
+	step.URL = server.URL + "/fail"
+	step.Method = http.MethodPut
+	if err := step.run(Event{}); err == nil || !strings.Contains(err.Error(), "light not found") {
+		t.Errorf("Failed request not reported: %v", err)
+	}
+	if method != http.MethodPut {
+		t.Errorf("Wrong method: Got %s, expected PUT", method)
+	}
+
+	invalid := []Step{
+		{Type: StepHTTP, URL: "ftp://example.com"},
+		{Type: StepHTTP, URL: server.URL, Body: "{{.Button"},
+		{Type: StepCommand},
+		{Type: StepCommand, Command: "ls", Args: []string{"-l"}, Shell: true},
+	}
+	for i, step := range invalid {
+		if err := step.validate(); err == nil {
+			t.Errorf("Invalid step %d accepted", i)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-vgo/robotgo"
+)
+
+// Backends that press keys and move the mouse
+const (
+	BackendRobotgo = "robotgo" // X11, macOS and Windows with robotgo (default)
+	BackendUinput  = "uinput"  // Virtual keyboard and mouse of the Linux kernel, works with Wayland
+	BackendLog     = "log"     // Only logs the keys and mouse actions, for trying configurations
+)
+
+// uinputPath is the device that creates virtual input devices
+const uinputPath = "/dev/uinput"
+
+// Backend presses keys and moves the mouse for the steps of macros and the controls. Keys are single characters or
+// keyNames, mouse buttons are left, center or right.
+type Backend interface {
+	KeyTap(key string) error
+	KeyDown(key string) error
+	KeyUp(key string) error
+	Type(text string) error
+
+	Click(button string, double bool) error
+	MouseDown(button string) error
+	MouseUp(button string) error
+	Move(x, y int, relative bool) error // Move to the position or by the offset if relative is true
+	Scroll(x, y int) error
+
+	Close() error
+}
+
+// validBackend returns whether openBackend knows the backend, an empty name is the default
+func validBackend(name string) bool {
+	return name == "" || name == BackendRobotgo || name == BackendUinput || name == BackendLog
+}
+
+// openBackend creates the backend with the given name
+func openBackend(name string) (Backend, error) {
+	switch name {
+	case "", BackendRobotgo:
+		return robotgoBackend{}, nil
+	case BackendUinput:
+		return openUinput(uinputPath)
+	case BackendLog:
+		return &Recorder{print: func(action string) {
+			logger.Info("dry run", "action", action)
+		}}, nil
+	}
+	return nil, fmt.Errorf("unknown backend \"%s\"", name)
+}
+
+// robotgoBackend uses robotgo, which needs an X11 session on Linux
+type robotgoBackend struct{}
+
+// robotgoError converts the error messages returned by the key functions of robotgo
+func robotgoError(message string) error {
+	if message == "" {
+		return nil
+	}
+	return errors.New(message)
+}
+
+func (robotgoBackend) KeyTap(key string) error {
+	return robotgoError(robotgo.KeyTap(key))
+}
+
+func (robotgoBackend) KeyDown(key string) error {
+	return robotgoError(robotgo.KeyToggle(key, "down"))
+}
+
+func (robotgoBackend) KeyUp(key string) error {
+	return robotgoError(robotgo.KeyToggle(key, "up"))
+}
+
+func (robotgoBackend) Type(text string) error {
+	robotgo.TypeStr(text)
+	return nil
+}
+
+func (robotgoBackend) Click(button string, double bool) error {
+	robotgo.MouseClick(button, double)
+	return nil
+}
+
+func (robotgoBackend) MouseDown(button string) error {
+	robotgo.MouseToggle("down", button)
+	return nil
+}
+
+func (robotgoBackend) MouseUp(button string) error {
+	robotgo.MouseToggle("up", button)
+	return nil
+}
+
+func (robotgoBackend) Move(x, y int, relative bool) error {
+	if relative {
+		currentX, currentY := robotgo.GetMousePos()
+		x, y = currentX+x, currentY+y
+	}
+	robotgo.Move(x, y)
+	return nil
+}
+
+func (robotgoBackend) Scroll(x, y int) error {
+	robotgo.Scroll(x, y)
+	return nil
+}
+
+func (robotgoBackend) Close() error {
+	return nil
+}
+
+// Recorder is a backend that records keys and mouse actions instead of sending them. Actions are described like
+// "tap ctrl", "type hello", "click left" or "move by 10 -5".
+type Recorder struct {
+	mutex   sync.Mutex
+	actions []string
+	print   func(action string) // Prints the actions instead of keeping them if set
+}
+
+func (r *Recorder) record(format string, args ...interface{}) error {
+	action := fmt.Sprintf(format, args...)
+	if r.print != nil {
+		r.print(action)
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.actions = append(r.actions, action)
+	return nil
+}
+
+// Actions returns the recorded actions and clears them
+func (r *Recorder) Actions() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	actions := r.actions
+	r.actions = nil
+	return actions
+}
+
+func (r *Recorder) KeyTap(key string) error {
+	return r.record("tap %s", key)
+}
+
+func (r *Recorder) KeyDown(key string) error {
+	return r.record("down %s", key)
+}
+
+func (r *Recorder) KeyUp(key string) error {
+	return r.record("up %s", key)
+}
+
+func (r *Recorder) Type(text string) error {
+	return r.record("type %s", text)
+}
+
+func (r *Recorder) Click(button string, double bool) error {
+	if double {
+		return r.record("doubleclick %s", button)
+	}
+	return r.record("click %s", button)
+}
+
+func (r *Recorder) MouseDown(button string) error {
+	return r.record("mousedown %s", button)
+}
+
+func (r *Recorder) MouseUp(button string) error {
+	return r.record("mouseup %s", button)
+}
+
+func (r *Recorder) Move(x, y int, relative bool) error {
+	if relative {
+		return r.record("move by %d %d", x, y)
+	}
+	return r.record("move %d %d", x, y)
+}
+
+func (r *Recorder) Scroll(x, y int) error {
+	return r.record("scroll %d %d", x, y)
+}
+
+func (r *Recorder) Close() error {
+	return nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/sirion/gomidi/lib/midi"
+)
+
+// Control actions
+const (
+	ControlScroll           = "scroll"           // Scroll vertically
+	ControlScrollHorizontal = "scrollHorizontal" // Scroll horizontally
+	ControlMoveX            = "moveX"            // Move the mouse cursor horizontally
+	ControlMoveY            = "moveY"            // Move the mouse cursor vertically
+)
+
+// Control modes
+const (
+	// ModeAbsolute converts changes of a fader or knob position into movement
+	ModeAbsolute = "absolute"
+	// ModeRelative is used for endless encoders that send 64 plus the number of steps turned
+	// clockwise or 64 minus the number of steps turned counter clockwise
+	ModeRelative = "relative"
+	// ModeRate moves continuously while the fader is away from its center, the distance sets the speed
+	ModeRate = "rate"
+)
+
+// rateInterval is the time between movements of controls in rate mode
+const rateInterval = 20 * time.Millisecond
+
+// Control assigns a continuous mouse action to a midi controller (fader or knob)
+type Control struct {
+	Controller byte    `json:"controller"`
+	Channel    int     `json:"channel,omitempty"` // Channel from 1 to 16, all channels if empty
+	Action     string  `json:"action"`
+	Mode       string  `json:"mode,omitempty"`  // absolute (default), relative or rate
+	Speed      float64 `json:"speed,omitempty"` // Pixels or scroll steps per controller step, per interval in rate mode at full distance
+}
+
+// Controls executes the actions of the configured controls for received controller messages
+type Controls struct {
+	controls []Control
+	move     func(dx, dy int)
+	scroll   func(dx, dy int)
+
+	mutex     sync.Mutex
+	values    map[int]int     // Last value of every control (absolute mode) or distance from the center (rate mode)
+	remainder map[int]float64 // Fractions of movements that are added to the next movement
+}
+
+// NewControls creates the handler for the given controls that moves the mouse with the backend
+func NewControls(controls []Control, backend Backend) *Controls {
+	return &Controls{
+		controls: controls,
+		move: func(dx, dy int) {
+			backend.Move(dx, dy, true)
+		},
+		scroll: func(dx, dy int) {
+			backend.Scroll(dx, dy)
+		},
+		values:    make(map[int]int),
+		remainder: make(map[int]float64),
+	}
+}
+
+// Validate checks the control configuration
+func (c *Control) Validate() error {
+	if c.Controller > 127 {
+		return fmt.Errorf("invalid controller %d", c.Controller)
+	}
+	if c.Channel < 0 || c.Channel > 16 {
+		return fmt.Errorf("invalid channel %d, must be between 1 and 16", c.Channel)
+	}
+
+	switch c.Action {
+	case ControlScroll, ControlScrollHorizontal, ControlMoveX, ControlMoveY:
+	default:
+		return fmt.Errorf("unknown action \"%s\"", c.Action)
+	}
+
+	switch c.Mode {
+	case "":
+		c.Mode = ModeAbsolute
+	case ModeAbsolute, ModeRelative, ModeRate:
+	default:
+		return fmt.Errorf("unknown mode \"%s\"", c.Mode)
+	}
+
+	if c.Speed == 0 {
+		c.Speed = 1
+	}
+
+	return nil
+}
+
+// Listen reads controller messages from the port until it returns an error.
+// Controls in rate mode are moved in the background.
+func (c *Controls) Listen(r io.Reader) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(rateInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.Tick()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	parser := midi.Parser{}
+	buffer := make([]byte, 1024)
+	for {
+		read, err := r.Read(buffer)
+		for _, message := range parser.Parse(buffer[:read]) {
+			c.Handle(message)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Handle executes the actions of all controls matching the controller message
+func (c *Controls) Handle(message []byte) {
+	if midi.Type(message) != midi.TypeController || len(message) < 3 {
+		return
+	}
+	channel, _ := midi.Channel(message)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i, control := range c.controls {
+		if control.Controller != message[1] || (control.Channel > 0 && byte(control.Channel-1) != channel) {
+			continue
+		}
+
+		value := int(message[2])
+		switch control.Mode {
+		case ModeRelative:
+			c.apply(i, float64(value-64)*control.Speed)
+		case ModeRate:
+			c.values[i] = value - 64
+		default:
+			last, known := c.values[i]
+			c.values[i] = value
+			if known {
+				c.apply(i, float64(value-last)*control.Speed)
+			}
+		}
+	}
+}
+
+// Tick moves all controls in rate mode according to their distance from the center
+func (c *Controls) Tick() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i, control := range c.controls {
+		if control.Mode == ModeRate && c.values[i] != 0 {
+			c.apply(i, float64(c.values[i])/64*control.Speed)
+		}
+	}
+}
+
+// apply executes the action of a control, fractions are kept for the next movement. The mutex must be locked.
+func (c *Controls) apply(i int, amount float64) {
+	amount += c.remainder[i]
+	steps := math.Trunc(amount)
+	c.remainder[i] = amount - steps
+	if steps == 0 {
+		return
+	}
+
+	switch c.controls[i].Action {
+	case ControlScroll:
+		c.scroll(0, int(steps))
+	case ControlScrollHorizontal:
+		c.scroll(int(steps), 0)
+	case ControlMoveX:
+		c.move(int(steps), 0)
+	case ControlMoveY:
+		c.move(0, int(steps))
+	}
+}
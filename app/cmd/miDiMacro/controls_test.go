@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sirion/gomidi/lib/midi"
+)
+
+type movement struct {
+	action string
+	dx, dy int
+}
+
+func testControls(controls []Control) (*Controls, *[]movement) {
+	movements := &[]movement{}
+	for i := range controls {
+		controls[i].Validate()
+	}
+
+	c := NewControls(controls, robotgoBackend{})
+	c.move = func(dx, dy int) {
+		*movements = append(*movements, movement{"move", dx, dy})
+	}
+	c.scroll = func(dx, dy int) {
+		*movements = append(*movements, movement{"scroll", dx, dy})
+	}
+	return c, movements
+}
+
+func compareMovements(t *testing.T, got []movement, expected ...movement) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("Wrong movements: Got %v, expected %v", got, expected)
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Errorf("Wrong movement %d: Got %v, expected %v", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestControls(t *testing.T) {
+	c, movements := testControls([]Control{
+		{Controller: 1, Action: ControlScroll, Speed: 0.5},
+		{Controller: 2, Channel: 2, Action: ControlMoveX, Mode: ModeRelative, Speed: 10},
+	})
+
+	c.Handle(midi.Controller(0, 1, 60)) // First value only sets the position
+	c.Handle(midi.Controller(0, 1, 64))
+	c.Handle(midi.Controller(0, 1, 65)) // Half a step is kept
+	c.Handle(midi.Controller(0, 1, 66))
+	c.Handle(midi.Controller(0, 1, 60))
+	c.Handle(midi.Controller(0, 2, 66)) // Wrong channel
+	c.Handle(midi.Controller(1, 2, 66))
+	c.Handle(midi.Controller(1, 2, 63))
+	c.Handle(midi.NoteOn(0, 1, 100))
+
+	compareMovements(t, *movements,
+		movement{"scroll", 0, 2},
+		movement{"scroll", 0, 1},
+		movement{"scroll", 0, -3},
+		movement{"move", 20, 0},
+		movement{"move", -10, 0},
+	)
+}
+
+func TestRateControls(t *testing.T) {
+	c, movements := testControls([]Control{{Controller: 7, Action: ControlScrollHorizontal, Mode: ModeRate, Speed: 4}})
+
+	c.Tick()
+	c.Handle(midi.Controller(0, 7, 96))
+	c.Tick()
+	c.Tick()
+	c.Handle(midi.Controller(0, 7, 64))
+	c.Tick()
+
+	compareMovements(t, *movements, movement{"scroll", 2, 0}, movement{"scroll", 2, 0})
+}
+
+func TestInvalidControls(t *testing.T) {
+	invalid := []Control{
+		{Controller: 200, Action: ControlScroll},
+		{Controller: 1, Channel: 17, Action: ControlScroll},
+		{Controller: 1, Action: "zoom"},
+		{Controller: 1, Action: ControlScroll, Mode: "fast"},
+	}
+	for i, control := range invalid {
+		if err := control.Validate(); err == nil {
+			t.Errorf("Invalid control %d accepted", i)
+		}
+	}
+}
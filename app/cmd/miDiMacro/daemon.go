@@ -0,0 +1,317 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+// defaultSocketPath returns the path of the control socket in $XDG_RUNTIME_DIR or the temporary directory
+func defaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "midimacro.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("midimacro-%d.sock", os.Getuid()))
+}
+
+// Daemon is the API of the control socket. It is served as the JSON-RPC service "MiDiMacro", so other programs can
+// call methods like "MiDiMacro.SetPage" with a single object as parameter.
+//
+// All calls are run by the main loop, which receives them from calls, so they can use the current setup.
+type Daemon struct {
+	calls chan func()
+
+	setup  func() *Setup
+	update func() // Shows the lights of the current setup
+	run    func(button byte, macro *Macro, event Event)
+	reload func() error
+}
+
+// Empty is the parameter and result of methods without one
+type Empty struct{}
+
+// MacroInfo describes a macro of the configuration
+type MacroInfo struct {
+	ID          string `json:"id"` // Button name, "page/Button" for macros of pages or the name of a gesture
+	Description string `json:"description"`
+	Toggle      bool   `json:"toggle,omitempty"`
+	On          bool   `json:"on,omitempty"`
+}
+
+// PagesInfo contains the pages of the configuration and the active page
+type PagesInfo struct {
+	Pages  []string `json:"pages"`
+	Active string   `json:"active"`
+}
+
+// PageArgs selects a page
+type PageArgs struct {
+	Page string `json:"page"`
+}
+
+// LEDArgs sets the color of a button, an empty color removes it
+type LEDArgs struct {
+	Button string `json:"button"`
+	Color  string `json:"color"`
+}
+
+// TriggerArgs selects a macro by its id
+type TriggerArgs struct {
+	ID string `json:"id"`
+}
+
+// call runs the function in the main loop and returns its error
+func (d *Daemon) call(f func(setup *Setup) error) error {
+	result := make(chan error)
+	d.calls <- func() {
+		result <- f(d.setup())
+	}
+	return <-result
+}
+
+// Macros lists all macros sorted by their id
+func (d *Daemon) Macros(args *Empty, reply *[]MacroInfo) error {
+	return d.call(func(setup *Setup) error {
+		*reply = []MacroInfo{}
+		for id, found := range setup.macros() {
+			*reply = append(*reply, MacroInfo{
+				ID:          id,
+				Description: describeMacro(found.macro),
+				Toggle:      found.macro.Toggle,
+				On:          found.macro.Toggle && setup.layers.toggles.On(found.macro.id),
+			})
+		}
+		sort.Slice(*reply, func(i, j int) bool {
+			return (*reply)[i].ID < (*reply)[j].ID
+		})
+		return nil
+	})
+}
+
+// Pages lists the pages and the active page
+func (d *Daemon) Pages(args *Empty, reply *PagesInfo) error {
+	return d.call(func(setup *Setup) error {
+		reply.Pages = []string{}
+		for name := range setup.config.Pages {
+			reply.Pages = append(reply.Pages, name)
+		}
+		sort.Strings(reply.Pages)
+		reply.Active, _ = setup.layers.Page()
+		return nil
+	})
+}
+
+// SetPage activates a page
+func (d *Daemon) SetPage(args *PageArgs, reply *Empty) error {
+	return d.call(func(setup *Setup) error {
+		if _, ok := setup.config.Pages[args.Page]; !ok {
+			return fmt.Errorf("unknown page %s", args.Page)
+		}
+		if setup.layers.SetPage(args.Page) {
+			d.update()
+		}
+		return nil
+	})
+}
+
+// SetLED sets the color of a button until it is removed with an empty color
+func (d *Daemon) SetLED(args *LEDArgs, reply *Empty) error {
+	button, ok := lm.ButtonValues[args.Button]
+	if !ok {
+		return fmt.Errorf("unknown button %s", args.Button)
+	}
+	color, ok := lm.ColorNames[args.Color]
+	if !ok && args.Color != "" {
+		return fmt.Errorf("unknown color %s", args.Color)
+	}
+
+	return d.call(func(setup *Setup) error {
+		if args.Color == "" {
+			setup.scripts.ClearLED(button)
+		} else {
+			setup.scripts.SetLED(button, color)
+		}
+		return nil
+	})
+}
+
+// Trigger runs a macro like a press of its button
+func (d *Daemon) Trigger(args *TriggerArgs, reply *Empty) error {
+	return d.call(func(setup *Setup) error {
+		found, ok := setup.macros()[args.ID]
+		if !ok {
+			return fmt.Errorf("unknown macro %s", args.ID)
+		}
+		d.run(found.button, &found.macro, setup.Event(args.ID, time.Now()))
+		return nil
+	})
+}
+
+// Reload loads the configuration file again
+func (d *Daemon) Reload(args *Empty, reply *Empty) error {
+	return d.call(func(setup *Setup) error {
+		return d.reload()
+	})
+}
+
+// Serve answers JSON-RPC requests on the connections of the listener until it is closed
+func (d *Daemon) Serve(listener net.Listener) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName("MiDiMacro", d); err != nil {
+		return err
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// listenSocket creates the control socket. A socket left by a program that ended without removing it is replaced.
+func listenSocket(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is used by another miDiMacro", path)
+		}
+		os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// foundMacro is a macro with the button that shows its state
+type foundMacro struct {
+	macro  Macro
+	button byte
+}
+
+// macros returns all macros by their id
+func (s *Setup) macros() map[string]foundMacro {
+	macros := make(map[string]foundMacro)
+	for button, macro := range s.layers.global {
+		macros[macro.id] = foundMacro{macro, button}
+	}
+	for _, page := range s.layers.pages {
+		for button, macro := range page.macros {
+			macros[macro.id] = foundMacro{macro, button}
+		}
+	}
+	for _, gesture := range s.recognizer.all {
+		macros[gesture.Name()] = foundMacro{gesture.Macro, gesture.Button()}
+	}
+	return macros
+}
+
+// clientUsage describes the commands of the client
+const clientUsage = `Usage: miDiMacro ctl [--socket PATH] COMMAND
+Commands:
+  macros              List all macros
+  pages               List all pages, the active page is marked with *
+  page NAME           Activate a page
+  led BUTTON [COLOR]  Set the color of a button, without color the color is removed
+  trigger ID          Run a macro by its id from the list of macros
+  reload              Load the configuration file again`
+
+// runClient sends a command to the control socket and writes the result to out
+func runClient(socket string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(clientUsage)
+	}
+
+	client, err := jsonrpc.Dial("unix", socket)
+	if err != nil {
+		return fmt.Errorf("miDiMacro is not running with --daemon: %s", err.Error())
+	}
+	defer client.Close()
+
+	arguments := func(count int) error {
+		if len(args)-1 < count {
+			return errors.New(clientUsage)
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "macros":
+		macros := []MacroInfo{}
+		if err := client.Call("MiDiMacro.Macros", &Empty{}, &macros); err != nil {
+			return err
+		}
+		for _, macro := range macros {
+			state := ""
+			if macro.Toggle {
+				state = " (off)"
+				if macro.On {
+					state = " (on)"
+				}
+			}
+			fmt.Fprintf(out, "%s\t%s%s\n", macro.ID, macro.Description, state)
+		}
+
+	case "pages":
+		pages := PagesInfo{}
+		if err := client.Call("MiDiMacro.Pages", &Empty{}, &pages); err != nil {
+			return err
+		}
+		for _, page := range pages.Pages {
+			if page == pages.Active {
+				page = "* " + page
+			} else {
+				page = "  " + page
+			}
+			fmt.Fprintln(out, page)
+		}
+
+	case "page":
+		if err := arguments(1); err != nil {
+			return err
+		}
+		return client.Call("MiDiMacro.SetPage", &PageArgs{Page: args[1]}, &Empty{})
+
+	case "led":
+		if err := arguments(1); err != nil {
+			return err
+		}
+		led := LEDArgs{Button: args[1]}
+		if len(args) > 2 {
+			led.Color = args[2]
+		}
+		return client.Call("MiDiMacro.SetLED", &led, &Empty{})
+
+	case "trigger":
+		if err := arguments(1); err != nil {
+			return err
+		}
+		return client.Call("MiDiMacro.Trigger", &TriggerArgs{ID: strings.Join(args[1:], " ")}, &Empty{})
+
+	case "reload":
+		return client.Call("MiDiMacro.Reload", &Empty{}, &Empty{})
+
+	default:
+		return fmt.Errorf("unknown command %s\n%s", args[0], clientUsage)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+const daemonConfiguration = `{
+	"keyMacros": {
+		"ButtonA1": {"key": "t", "modifiers": ["ctrl"]},
+		"ButtonA2": {"toggle": true, "steps": [{"key": "m"}]}
+	},
+	"pages": {
+		"main": {"keyMacros": {"ButtonB1": {"key": "1"}}},
+		"media": {"keyMacros": {"ButtonB1": {"key": "audio_play"}}}
+	},
+	"startPage": "main",
+	"gestures": [
+		{"trigger": "longPress", "buttons": ["ButtonC1"], "macro": {"key": "escape"}}
+	]
+}`
+
+func TestDaemon(t *testing.T) {
+	dir, err := ioutil.TempDir("", "midimacro")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	ioutil.WriteFile(path, []byte(daemonConfiguration), 0644)
+
+	load := func() *Setup {
+		config := &Configuration{}
+		if err := config.Load(path); err != nil {
+			t.Fatalf("Configuration not loaded: %s", err.Error())
+		}
+		setup, err := NewSetup(config)
+		if err != nil {
+			t.Fatalf("Setup failed: %s", err.Error())
+		}
+		return setup
+	}
+
+	// The main loop runs the calls
+	setup := load()
+	updates, reloads := 0, 0
+	triggered := []string{}
+	server := &Daemon{
+		calls:  make(chan func()),
+		setup:  func() *Setup { return setup },
+		update: func() { updates++ },
+		run: func(button byte, macro *Macro, event Event) {
+			triggered = append(triggered, lm.ButtonNames[button]+" "+event.Button+" "+describeMacro(*macro))
+		},
+		reload: func() error {
+			reloads++
+			setup = load()
+			return nil
+		},
+	}
+	go func() {
+		for call := range server.calls {
+			call()
+		}
+	}()
+
+	socket := filepath.Join(dir, "control.sock")
+	listener, err := listenSocket(socket)
+	if err != nil {
+		t.Fatalf("Socket not created: %s", err.Error())
+	}
+	defer listener.Close()
+	go server.Serve(listener)
+
+	if _, err := listenSocket(socket); err == nil {
+		t.Errorf("Socket in use replaced")
+	}
+
+	client := func(args ...string) string {
+		out := &bytes.Buffer{}
+		if err := runClient(socket, args, out); err != nil {
+			return "error: " + err.Error()
+		}
+		return out.String()
+	}
+
+	macros := "ButtonA1\tctrl+t\nButtonA2\tm (off)\nlongPress ButtonC1\tescape\nmain/ButtonB1\t1\nmedia/ButtonB1\taudio_play\n"
+	if out := client("macros"); out != macros {
+		t.Errorf("Wrong macros:\n%s", out)
+	}
+
+	if out := client("page", "media"); out != "" || updates != 1 {
+		t.Errorf("Page not changed: %s", out)
+	}
+	if out := client("pages"); out != "  main\n* media\n" {
+		t.Errorf("Wrong pages:\n%s", out)
+	}
+	if out := client("page", "other"); out != "error: unknown page other" {
+		t.Errorf("Wrong error: %s", out)
+	}
+
+	client("led", "ButtonH8", "ColorRedFull")
+	if setup.LEDs()[lm.ButtonH8] != lm.ColorRedFull {
+		t.Errorf("Light not set")
+	}
+	client("led", "ButtonH8")
+	if _, ok := setup.LEDs()[lm.ButtonH8]; ok {
+		t.Errorf("Light not removed")
+	}
+	if out := client("led", "ButtonH8", "Purple"); out != "error: unknown color Purple" {
+		t.Errorf("Wrong error: %s", out)
+	}
+
+	client("trigger", "main/ButtonB1")
+	client("trigger", "longPress", "ButtonC1")
+	if strings.Join(triggered, ",") != "ButtonB1 main/ButtonB1 1,ButtonC1 longPress ButtonC1 escape" {
+		t.Errorf("Wrong macros triggered: %v", triggered)
+	}
+	if out := client("trigger", "ButtonH1"); out != "error: unknown macro ButtonH1" {
+		t.Errorf("Wrong error: %s", out)
+	}
+
+	if out := client("reload"); out != "" || reloads != 1 {
+		t.Errorf("Not reloaded: %s", out)
+	}
+	if out := client("pages"); out != "* main\n  media\n" {
+		t.Errorf("Wrong pages after reload:\n%s", out)
+	}
+
+	if out := client("unknown"); !strings.HasPrefix(out, "error: unknown command unknown") {
+		t.Errorf("Wrong error: %s", out)
+	}
+	if err := runClient(filepath.Join(dir, "missing.sock"), []string{"pages"}, &bytes.Buffer{}); err == nil {
+		t.Errorf("Missing socket not reported")
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration file formats, selected by the file extension
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// configurationFiles are the names of configuration files in the order they are searched
+var configurationFiles = []string{"config.json", "config.yaml", "config.yml", "config.toml"}
+
+// fileFormat returns the format of the configuration file, files with unknown extensions are JSON
+func fileFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// configurationDirectories returns the directories searched for the midimacro directory: XDG_CONFIG_HOME
+// (~/.config by default) followed by the directories in XDG_CONFIG_DIRS (/etc/xdg by default)
+func configurationDirectories() []string {
+	home := os.Getenv("XDG_CONFIG_HOME")
+	if home == "" {
+		home = filepath.Join(getUserDir(), ".config")
+	}
+
+	dirs := os.Getenv("XDG_CONFIG_DIRS")
+	if dirs == "" {
+		dirs = "/etc/xdg"
+	}
+
+	return append([]string{home}, filepath.SplitList(dirs)...)
+}
+
+// findConfiguration returns the first existing configuration file in the midimacro directories of the given
+// directories or config.json in the first one if there is none
+func findConfiguration(directories []string) string {
+	for _, directory := range directories {
+		for _, name := range configurationFiles {
+			path := filepath.Join(directory, "midimacro", name)
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+	}
+	return filepath.Join(directories[0], "midimacro", configurationFiles[0])
+}
+
+// decodeConfiguration converts the content of a configuration file in the given format into JSON. The second
+// result is whether the lines of the JSON are the lines of the file. YAML is converted line by line, so errors
+// found in the JSON can be reported with the line of the YAML file.
+func decodeConfiguration(format string, data []byte) ([]byte, bool, error) {
+	switch format {
+	case FormatYAML:
+		var document yaml.Node
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return nil, false, err
+		}
+		if len(document.Content) == 0 {
+			return []byte("{}"), true, nil
+		}
+
+		w := &lineWriter{line: 1}
+		if err := w.node(document.Content[0]); err != nil {
+			return nil, false, err
+		}
+		return w.Bytes(), true, nil
+
+	case FormatTOML:
+		document := map[string]interface{}{}
+		if _, err := toml.Decode(string(data), &document); err != nil {
+			if parseErr, ok := err.(toml.ParseError); ok {
+				return nil, false, tomlError(data, parseErr)
+			}
+			return nil, false, err
+		}
+		out, err := json.Marshal(document)
+		return out, false, err
+
+	default:
+		return data, true, nil
+	}
+}
+
+// tomlError converts a syntax error of a TOML file into a ConfigError with its line and column
+func tomlError(data []byte, err toml.ParseError) error {
+	start := err.Position.Start
+	if start > len(data) {
+		start = len(data)
+	}
+	// The message follows the position in the text of the error, which may also contain the last key
+	parts := strings.SplitN(err.Error(), ": ", 3)
+	return ConfigErrors{{
+		Line:    err.Position.Line,
+		Column:  start - bytes.LastIndexByte(data[:start], '\n'),
+		Message: parts[len(parts)-1],
+	}}
+}
+
+// updateYAML returns the YAML document with its keyMacros replaced by the given macros. Unchanged macros keep
+// their nodes and new ones are added at the end, so comments and the order of keys stay as they are.
+func updateYAML(data []byte, macros map[string]Macro) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		document = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("the configuration is not a mapping")
+	}
+
+	var list *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "keyMacros" {
+			list = root.Content[i+1]
+		}
+	}
+	if list == nil || list.Kind != yaml.MappingNode {
+		list = &yaml.Node{Kind: yaml.MappingNode}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "keyMacros"}, list)
+	}
+
+	added := make(map[string]Macro, len(macros))
+	for name, macro := range macros {
+		added[name] = macro
+	}
+	content := []*yaml.Node{}
+	for i := 0; i+1 < len(list.Content); i += 2 {
+		key, value := list.Content[i], list.Content[i+1]
+		macro, ok := added[key.Value]
+		if !ok {
+			continue
+		}
+		delete(added, key.Value)
+
+		if !sameMacro(value, macro) {
+			var err error
+			if value, err = yamlValue(macro); err != nil {
+				return nil, err
+			}
+		}
+		content = append(content, key, value)
+	}
+
+	names := make([]string, 0, len(added))
+	for name := range added {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, err := yamlValue(added[name])
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, value)
+	}
+	list.Content = content
+
+	out := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// sameMacro returns whether the YAML node is the given macro
+func sameMacro(node *yaml.Node, macro Macro) bool {
+	w := &lineWriter{line: node.Line}
+	if err := w.node(node); err != nil {
+		return false
+	}
+	existing := Macro{}
+	if err := json.Unmarshal(w.Bytes(), &existing); err != nil || existing.Validate() != nil {
+		return false
+	}
+
+	a, errA := json.Marshal(existing)
+	b, errB := json.Marshal(macro)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
+
+// yamlValue converts a value into a YAML node in the way encodeConfiguration writes it
+func yamlValue(value interface{}) (*yaml.Node, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	node := &yaml.Node{}
+	return node, node.Encode(normalize(document))
+}
+
+// encodeConfiguration converts a JSON configuration into the given format
+func encodeConfiguration(format string, data []byte) ([]byte, error) {
+	if format == FormatJSON {
+		out := &bytes.Buffer{}
+		if err := json.Indent(out, data, "", "  "); err != nil {
+			return nil, err
+		}
+		out.WriteByte('\n')
+		return out.Bytes(), nil
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	document = normalize(document)
+
+	if format == FormatYAML {
+		return yaml.Marshal(document)
+	}
+
+	out := &bytes.Buffer{}
+	if err := toml.NewEncoder(out).Encode(document); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// normalize prepares decoded JSON for other formats: whole numbers become integers and null values, which
+// TOML does not support, are removed
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			if item == nil {
+				delete(v, key)
+			} else {
+				v[key] = normalize(item)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = normalize(v[i])
+		}
+	}
+	return value
+}
+
+// lineWriter writes a YAML node tree as JSON and starts every value on the line it had in the YAML document
+type lineWriter struct {
+	bytes.Buffer
+	line int
+}
+
+func (w *lineWriter) moveTo(line int) {
+	for w.line < line {
+		w.WriteByte('\n')
+		w.line++
+	}
+}
+
+func (w *lineWriter) node(n *yaml.Node) error {
+	w.moveTo(n.Line)
+
+	switch n.Kind {
+	case yaml.AliasNode:
+		return w.node(n.Alias)
+
+	case yaml.MappingNode:
+		w.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			key := n.Content[i]
+			w.moveTo(key.Line)
+			name, _ := json.Marshal(key.Value)
+			w.Write(name)
+			w.WriteByte(':')
+			if err := w.node(n.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		w.WriteByte('}')
+
+	case yaml.SequenceNode:
+		w.WriteByte('[')
+		for i, item := range n.Content {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := w.node(item); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+
+	case yaml.ScalarNode:
+		var value interface{}
+		if err := n.Decode(&value); err != nil {
+			return err
+		}
+		out, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("line %d: %s", n.Line, err.Error())
+		}
+		w.Write(out)
+
+	default:
+		return fmt.Errorf("line %d: unsupported YAML node", n.Line)
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+const yamlConfiguration = `# Comments are allowed
+device: seq:Launchpad Mini
+keyMacros:
+  ButtonA1: {key: "1", modifiers: [ctrl]}
+  ButtonA2:
+    color: ColorRedLow
+    steps:
+      - type: type
+        text: "yes"
+      - {type: wait, ms: 100}
+`
+
+const tomlConfiguration = `# Comments are allowed
+device = "seq:Launchpad Mini"
+
+[keyMacros.ButtonA1]
+key = "1"
+modifiers = ["ctrl"]
+
+[keyMacros.ButtonA2]
+color = "ColorRedLow"
+steps = [
+	{type = "type", text = "yes"},
+	{type = "wait", ms = 100},
+]
+`
+
+func TestFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "midimacro")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	for name, configuration := range map[string]string{"config.yaml": yamlConfiguration, "config.toml": tomlConfiguration} {
+		path := filepath.Join(dir, name)
+		ioutil.WriteFile(path, []byte(configuration), 0644)
+
+		config := Configuration{}
+		if err := config.Load(path); err != nil {
+			t.Fatalf("%s not loaded: %s", name, err.Error())
+		}
+		if config.Device != "seq:Launchpad Mini" || len(config.Macros) != 2 {
+			t.Errorf("%s: Wrong configuration: %v", name, config)
+		}
+		macro := config.Macros[lm.ButtonA2]
+		if macro.color != lm.ColorRedLow || len(macro.Steps) != 2 || macro.Steps[0].Text != "yes" || macro.Steps[1].Duration != 100 {
+			t.Errorf("%s: Wrong macro: %v", name, macro)
+		}
+		if macro := config.Macros[lm.ButtonA1]; macro.Steps[0].Key != "1" || macro.Steps[0].Modifiers[0] != "ctrl" {
+			t.Errorf("%s: Wrong key combination: %v", name, macro)
+		}
+	}
+
+	// Errors in YAML files have the line of the YAML file
+	path := filepath.Join(dir, "invalid.yml")
+	ioutil.WriteFile(path, []byte("keyMacros:\n  ButtonA1:\n    key: a\n\n  ButtonZ1:\n    key: b\n"), 0644)
+	config := Configuration{}
+	if err := config.Load(path); err == nil || err.Error() != "line 5: unknown button ButtonZ1" {
+		t.Errorf("Wrong error: %v", err)
+	}
+
+	// TOML errors have no line of the converted JSON
+	path = filepath.Join(dir, "invalid.toml")
+	ioutil.WriteFile(path, []byte("[keyMacros.ButtonZ1]\nkey = \"b\"\n"), 0644)
+	if err := config.Load(path); err == nil || err.Error() != "unknown button ButtonZ1" {
+		t.Errorf("Wrong error: %v", err)
+	}
+
+	// TOML syntax errors have their line and column
+	ioutil.WriteFile(path, []byte("device = \"a\"\n[keyMacros.ButtonA1]\nkey = b\n"), 0644)
+	if err := config.Load(path); err == nil || !strings.HasPrefix(err.Error(), "line 3, column 7: ") {
+		t.Errorf("Wrong error: %v", err)
+	}
+}
+
+func TestSaveFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "midimacro")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// Only the changed macros of YAML files are written again
+	path := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(path, []byte(yamlConfiguration), 0644)
+	config := Configuration{}
+	if err := config.Load(path); err != nil {
+		t.Fatalf("Configuration not loaded: %s", err.Error())
+	}
+	delete(config.Macros, lm.ButtonA1)
+	config.Macros[lm.ButtonB1] = Macro{KeyCombination: KeyCombination{Key: "b"}}
+	if err := config.Save(); err != nil {
+		t.Fatalf("Configuration not saved: %s", err.Error())
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	saved := string(data)
+	for _, part := range []string{"# Comments are allowed\ndevice: seq:Launchpad Mini\nkeyMacros:\n  ButtonA2:\n", "      - {type: wait, ms: 100}\n  ButtonB1:\n"} {
+		if !strings.Contains(saved, part) {
+			t.Errorf("Saved YAML does not contain %q:\n%s", part, saved)
+		}
+	}
+	if strings.Contains(saved, "ButtonA1") {
+		t.Errorf("Removed macro saved:\n%s", saved)
+	}
+	if err := config.Load(path); err != nil || len(config.Macros) != 2 || config.Macros[lm.ButtonB1].Steps[0].Key != "b" {
+		t.Errorf("Saved configuration not loaded: %v", err)
+	}
+
+	// TOML files would lose their comments
+	path = filepath.Join(dir, "config.toml")
+	ioutil.WriteFile(path, []byte(tomlConfiguration), 0644)
+	if err := config.Load(path); err != nil {
+		t.Fatalf("Configuration not loaded: %s", err.Error())
+	}
+	if err := config.Save(); err == nil {
+		t.Errorf("TOML configuration saved")
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != tomlConfiguration {
+		t.Errorf("TOML configuration changed")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "midimacro")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	example := Configuration{}
+	if err := example.Load("config-example/config.json"); err != nil {
+		t.Fatalf("Example configuration not loaded: %s", err.Error())
+	}
+
+	for _, name := range []string{"config.yaml", "config.toml", "config.json"} {
+		path := filepath.Join(dir, name)
+		if err := convert("config-example/config.json", path); err != nil {
+			t.Fatalf("Not converted to %s: %s", name, err.Error())
+		}
+
+		config := Configuration{}
+		if err := config.Load(path); err != nil {
+			t.Fatalf("Converted %s not loaded: %s", name, err.Error())
+		}
+		if !reflect.DeepEqual(config.Macros, example.Macros) || !reflect.DeepEqual(config.Controls, example.Controls) {
+			t.Errorf("Converted %s differs from the example", name)
+		}
+	}
+
+	if err := convert("config-example/config.json", filepath.Join(dir, "config.yaml")); err == nil {
+		t.Errorf("Existing file overwritten")
+	}
+}
+
+func TestFindConfiguration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "midimacro")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	home, system := filepath.Join(dir, "home"), filepath.Join(dir, "system")
+	directories := []string{home, system}
+	write := func(path string) {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte("{}"), 0644)
+	}
+
+	if path := findConfiguration(directories); path != filepath.Join(home, "midimacro", "config.json") {
+		t.Errorf("Wrong default path: %s", path)
+	}
+
+	write(filepath.Join(system, "midimacro", "config.json"))
+	if path := findConfiguration(directories); path != filepath.Join(system, "midimacro", "config.json") {
+		t.Errorf("Configuration of the system not found: %s", path)
+	}
+
+	write(filepath.Join(home, "midimacro", "config.toml"))
+	if path := findConfiguration(directories); path != filepath.Join(home, "midimacro", "config.toml") {
+		t.Errorf("Configuration of the user not preferred: %s", path)
+	}
+
+	write(filepath.Join(home, "midimacro", "config.yaml"))
+	if path := findConfiguration(directories); path != filepath.Join(home, "midimacro", "config.yaml") {
+		t.Errorf("YAML not preferred to TOML: %s", path)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("XDG_CONFIG_DIRS", system+string(filepath.ListSeparator)+"/etc/xdg")
+	if dirs := configurationDirectories(); !reflect.DeepEqual(dirs, []string{home, system, "/etc/xdg"}) {
+		t.Errorf("Wrong directories: %v", dirs)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+// Gesture triggers
+const (
+	TriggerTap       = "tap"       // Press and release, delayed by the double tap time if the button has a double tap
+	TriggerLongPress = "longPress" // Hold the button for the long press time
+	TriggerDoubleTap = "doubleTap" // Press the button again within the double tap time after a tap
+	TriggerRelease   = "release"   // Release the button
+	TriggerChord     = "chord"     // Hold all buttons of the gesture at the same time
+)
+
+// Default times of gestures
+const (
+	defaultLongPress = 500 * time.Millisecond
+	defaultDoubleTap = 300 * time.Millisecond
+)
+
+// gestureInterval is the time between checks for long presses and taps that waited for a double tap
+const gestureInterval = 20 * time.Millisecond
+
+// Gesture binds a macro to a trigger of one button or to a chord of several buttons
+type Gesture struct {
+	Trigger string   `json:"trigger"`
+	Buttons []string `json:"buttons"`
+	Macro   Macro    `json:"macro"`
+
+	buttons []byte
+}
+
+// buttonState is the progress of the gestures of a single button
+type buttonState struct {
+	pressed   bool
+	pressTime time.Time
+	long      bool // The long press of the current press was triggered
+	used      bool // The current press was part of a chord or the second press of a double tap
+
+	tapPending bool // A tap waits for a possible double tap
+	tapTime    time.Time
+}
+
+// Recognizer detects gestures in button events. It does not use timers itself, Tick has to be called regularly
+// to trigger long presses and taps that waited for a double tap.
+type Recognizer struct {
+	longPress time.Duration
+	doubleTap time.Duration
+
+	all      []*Gesture
+	gestures map[byte]map[string]*Gesture // Gestures of single buttons by trigger
+	chords   []*Gesture
+	states   map[byte]*buttonState
+}
+
+// NewRecognizer creates the recognizer for the gestures of the configuration. The global macros must already be
+// converted, buttons with gestures must not have a macro or be a page or shift button.
+func NewRecognizer(c *Configuration) (*Recognizer, error) {
+	r := &Recognizer{
+		longPress: defaultLongPress,
+		doubleTap: defaultDoubleTap,
+		gestures:  make(map[byte]map[string]*Gesture),
+		states:    make(map[byte]*buttonState),
+	}
+	if c.LongPress > 0 {
+		r.longPress = time.Duration(c.LongPress) * time.Millisecond
+	}
+	if c.DoubleTap > 0 {
+		r.doubleTap = time.Duration(c.DoubleTap) * time.Millisecond
+	}
+
+	reserved := make(map[string]string)
+	for name := range c.PageButtons {
+		reserved[name] = "a page button"
+	}
+	for name := range c.ShiftButtons {
+		reserved[name] = "a shift button"
+	}
+	for button := range c.Macros {
+		reserved[lm.ButtonNames[button]] = "a macro"
+	}
+
+	for i := range c.Gestures {
+		g := &c.Gestures[i]
+		if err := g.compile(reserved); err != nil {
+			return nil, fmt.Errorf("gesture %d: %s", i+1, err.Error())
+		}
+		r.all = append(r.all, g)
+
+		if g.Trigger == TriggerChord {
+			r.chords = append(r.chords, g)
+			continue
+		}
+
+		button := g.buttons[0]
+		if r.gestures[button] == nil {
+			r.gestures[button] = make(map[string]*Gesture)
+		}
+		if _, ok := r.gestures[button][g.Trigger]; ok {
+			return nil, fmt.Errorf("gesture %d: %s of %s is already used", i+1, g.Trigger, g.Buttons[0])
+		}
+		r.gestures[button][g.Trigger] = g
+	}
+
+	return r, nil
+}
+
+// compile converts the button names and checks the trigger and macro of the gesture
+func (g *Gesture) compile(reserved map[string]string) error {
+	switch g.Trigger {
+	case TriggerTap, TriggerLongPress, TriggerDoubleTap, TriggerRelease:
+		if len(g.Buttons) != 1 {
+			return fmt.Errorf("%s needs one button", g.Trigger)
+		}
+	case TriggerChord:
+		if len(g.Buttons) < 2 {
+			return fmt.Errorf("%s needs at least two buttons", g.Trigger)
+		}
+	default:
+		return fmt.Errorf("unknown trigger \"%s\"", g.Trigger)
+	}
+
+	g.buttons = make([]byte, len(g.Buttons))
+	for i, name := range g.Buttons {
+		button, ok := lm.ButtonValues[name]
+		if !ok {
+			return fmt.Errorf("unknown button %s", name)
+		}
+		if use, ok := reserved[name]; ok {
+			return fmt.Errorf("button %s also has %s", name, use)
+		}
+		g.buttons[i] = button
+	}
+
+	if err := g.Macro.Validate(); err != nil {
+		return err
+	}
+	if g.Macro.Toggle {
+		return fmt.Errorf("toggle macros can not be used in gestures")
+	}
+
+	return nil
+}
+
+// Name returns the trigger and buttons of the gesture like "chord ButtonA1+ButtonA2"
+func (g *Gesture) Name() string {
+	return g.Trigger + " " + strings.Join(g.Buttons, "+")
+}
+
+// Button returns the button that shows the state of the gesture, which is the last button of chords
+func (g *Gesture) Button() byte {
+	return g.buttons[len(g.buttons)-1]
+}
+
+// LEDs returns the colors of all buttons with gestures, which is the color of the macro of their first gesture
+func (r *Recognizer) LEDs() map[byte]byte {
+	leds := make(map[byte]byte)
+	for _, g := range r.all {
+		for _, button := range g.buttons {
+			if _, ok := leds[button]; !ok {
+				leds[button] = g.Macro.color
+			}
+		}
+	}
+	return leds
+}
+
+// Handles returns whether the button is used by any gesture
+func (r *Recognizer) Handles(button byte) bool {
+	if _, ok := r.gestures[button]; ok {
+		return true
+	}
+	for _, chord := range r.chords {
+		for _, b := range chord.buttons {
+			if b == button {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *Recognizer) state(button byte) *buttonState {
+	state, ok := r.states[button]
+	if !ok {
+		state = &buttonState{}
+		r.states[button] = state
+	}
+	return state
+}
+
+// gesture returns the gesture of the button with the trigger, nil if there is none
+func (r *Recognizer) gesture(button byte, trigger string) *Gesture {
+	return r.gestures[button][trigger]
+}
+
+// Event processes a button event at the given time and returns the triggered gestures
+func (r *Recognizer) Event(event lm.ButtonEvent, now time.Time) []*Gesture {
+	triggered := r.expire(now)
+	state := r.state(event.Button)
+
+	if !event.Pressed {
+		if !state.pressed {
+			return triggered
+		}
+		state.pressed = false
+		if state.used {
+			return triggered
+		}
+
+		long := r.gesture(event.Button, TriggerLongPress)
+		if long != nil && !state.long && now.Sub(state.pressTime) >= r.longPress {
+			state.long = true
+			triggered = append(triggered, long)
+		}
+		if release := r.gesture(event.Button, TriggerRelease); release != nil {
+			triggered = append(triggered, release)
+		}
+		if state.long {
+			return triggered
+		}
+
+		if r.gesture(event.Button, TriggerDoubleTap) != nil {
+			state.tapPending, state.tapTime = true, now
+		} else if tap := r.gesture(event.Button, TriggerTap); tap != nil {
+			triggered = append(triggered, tap)
+		}
+		return triggered
+	}
+
+	doubleTap := state.tapPending
+	*state = buttonState{pressed: true, pressTime: now}
+
+	if doubleTap {
+		// The second press does not start a new tap
+		state.used = true
+		return append(triggered, r.gesture(event.Button, TriggerDoubleTap))
+	}
+
+	for _, chord := range r.chords {
+		if r.chordHeld(chord, event.Button) {
+			for _, button := range chord.buttons {
+				r.state(button).used = true
+			}
+			return append(triggered, chord)
+		}
+	}
+
+	return triggered
+}
+
+// chordHeld returns whether the chord contains the button and all its buttons are held without being used
+// by another chord
+func (r *Recognizer) chordHeld(chord *Gesture, button byte) bool {
+	contains := false
+	for _, b := range chord.buttons {
+		state := r.state(b)
+		if !state.pressed || state.used {
+			return false
+		}
+		contains = contains || b == button
+	}
+	return contains
+}
+
+// Tick triggers long presses of held buttons and taps whose double tap time is over
+func (r *Recognizer) Tick(now time.Time) []*Gesture {
+	triggered := r.expire(now)
+
+	for _, button := range r.sortedButtons() {
+		state := r.states[button]
+		long := r.gesture(button, TriggerLongPress)
+		if long != nil && state.pressed && !state.long && !state.used && now.Sub(state.pressTime) >= r.longPress {
+			state.long = true
+			triggered = append(triggered, long)
+		}
+	}
+
+	return triggered
+}
+
+// expire triggers the taps that waited for a double tap for too long
+func (r *Recognizer) expire(now time.Time) []*Gesture {
+	triggered := []*Gesture{}
+	for _, button := range r.sortedButtons() {
+		state := r.states[button]
+		if state.tapPending && now.Sub(state.tapTime) > r.doubleTap {
+			state.tapPending = false
+			if tap := r.gesture(button, TriggerTap); tap != nil {
+				triggered = append(triggered, tap)
+			}
+		}
+	}
+	return triggered
+}
+
+// sortedButtons returns the buttons with a state in ascending order, so gestures are triggered in a fixed order
+func (r *Recognizer) sortedButtons() []byte {
+	buttons := make([]byte, 0, len(r.states))
+	for button := range r.states {
+		buttons = append(buttons, button)
+	}
+	sort.Slice(buttons, func(i, j int) bool {
+		return buttons[i] < buttons[j]
+	})
+	return buttons
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+const gesturesConfiguration = `{
+	"keyMacros": {"ButtonH1": {"key": "h"}},
+	"gestures": [
+		{"trigger": "tap", "buttons": ["ButtonA1"], "macro": {"key": "t"}},
+		{"trigger": "longPress", "buttons": ["ButtonA1"], "macro": {"key": "l"}},
+		{"trigger": "tap", "buttons": ["ButtonA2"], "macro": {"key": "t"}},
+		{"trigger": "doubleTap", "buttons": ["ButtonA2"], "macro": {"key": "d"}},
+		{"trigger": "release", "buttons": ["ButtonA3"], "macro": {"key": "r"}},
+		{"trigger": "tap", "buttons": ["ButtonB1"], "macro": {"key": "t"}},
+		{"trigger": "chord", "buttons": ["ButtonB1", "ButtonB2"], "macro": {"key": "c", "color": "ColorRedLow"}}
+	],
+	"longPress": 400,
+	"doubleTap": 200
+}`
+
+func testRecognizer(t *testing.T, configuration string) (*Recognizer, error) {
+	config, err := testConfiguration(t, configuration)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecognizer(config)
+}
+
+// timelineStep is a button event (pressed or released) or a tick at a time in ms
+type timelineStep struct {
+	ms      int
+	button  byte
+	pressed bool
+	tick    bool
+}
+
+func pressAt(ms int, button byte) timelineStep {
+	return timelineStep{ms: ms, button: button, pressed: true}
+}
+
+func releaseAt(ms int, button byte) timelineStep {
+	return timelineStep{ms: ms, button: button}
+}
+
+func tickAt(ms int) timelineStep {
+	return timelineStep{ms: ms, tick: true}
+}
+
+// runTimeline returns the triggered gestures with the time they were triggered at like "t@100ms"
+func runTimeline(r *Recognizer, timeline ...timelineStep) string {
+	start := time.Now()
+	triggered := []string{}
+	for _, step := range timeline {
+		now := start.Add(time.Duration(step.ms) * time.Millisecond)
+		var gestures []*Gesture
+		if step.tick {
+			gestures = r.Tick(now)
+		} else {
+			gestures = r.Event(lm.ButtonEvent{Button: step.button, Pressed: step.pressed}, now)
+		}
+		for _, g := range gestures {
+			triggered = append(triggered, g.Macro.Steps[0].Key+"@"+now.Sub(start).String())
+		}
+	}
+	return strings.Join(triggered, " ")
+}
+
+func TestGestures(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeline []timelineStep
+		expected string
+	}{
+		{"tap", []timelineStep{pressAt(0, lm.ButtonA1), releaseAt(100, lm.ButtonA1)}, "t@100ms"},
+		{"long press while held", []timelineStep{pressAt(0, lm.ButtonA1), tickAt(300), tickAt(420), releaseAt(900, lm.ButtonA1)}, "l@420ms"},
+		{"long press on release", []timelineStep{pressAt(0, lm.ButtonA1), releaseAt(500, lm.ButtonA1)}, "l@500ms"},
+		{"tap waits for double tap", []timelineStep{pressAt(0, lm.ButtonA2), releaseAt(50, lm.ButtonA2), tickAt(200), tickAt(260)}, "t@260ms"},
+		{"double tap", []timelineStep{pressAt(0, lm.ButtonA2), releaseAt(50, lm.ButtonA2), pressAt(150, lm.ButtonA2), releaseAt(200, lm.ButtonA2), tickAt(500)}, "d@150ms"},
+		{"slow taps", []timelineStep{pressAt(0, lm.ButtonA2), releaseAt(50, lm.ButtonA2), pressAt(300, lm.ButtonA2), releaseAt(350, lm.ButtonA2), tickAt(600)}, "t@300ms t@600ms"},
+		{"release", []timelineStep{pressAt(0, lm.ButtonA3), tickAt(1000), releaseAt(1200, lm.ButtonA3)}, "r@1.2s"},
+		{"chord", []timelineStep{pressAt(0, lm.ButtonB1), pressAt(30, lm.ButtonB2), releaseAt(100, lm.ButtonB1), releaseAt(110, lm.ButtonB2)}, "c@30ms"},
+		{"chord in any order", []timelineStep{pressAt(0, lm.ButtonB2), pressAt(30, lm.ButtonB1), releaseAt(100, lm.ButtonB1), releaseAt(110, lm.ButtonB2)}, "c@30ms"},
+		{"no chord", []timelineStep{pressAt(0, lm.ButtonB1), releaseAt(50, lm.ButtonB1), pressAt(60, lm.ButtonB2), releaseAt(100, lm.ButtonB2)}, "t@50ms"},
+		{"release without press", []timelineStep{releaseAt(0, lm.ButtonA1)}, ""},
+	}
+
+	for _, test := range tests {
+		r, err := testRecognizer(t, gesturesConfiguration)
+		if err != nil {
+			t.Fatalf("Valid configuration not accepted: %s", err.Error())
+		}
+		if triggered := runTimeline(r, test.timeline...); triggered != test.expected {
+			t.Errorf("%s: Got \"%s\", expected \"%s\"", test.name, triggered, test.expected)
+		}
+	}
+
+	r, _ := testRecognizer(t, gesturesConfiguration)
+	if !r.Handles(lm.ButtonB2) || r.Handles(lm.ButtonH1) {
+		t.Errorf("Wrong buttons handled")
+	}
+	if leds := r.LEDs(); len(leds) != 5 || leds[lm.ButtonB1] != defaultColor || leds[lm.ButtonB2] != lm.ColorRedLow {
+		t.Errorf("Wrong LEDs: %v", leds)
+	}
+}
+
+func TestInvalidGestures(t *testing.T) {
+	invalid := []string{
+		`{"gestures": [{"trigger": "hold", "buttons": ["ButtonA1"], "macro": {"key": "a"}}]}`,
+		`{"gestures": [{"trigger": "tap", "buttons": ["ButtonA1", "ButtonA2"], "macro": {"key": "a"}}]}`,
+		`{"gestures": [{"trigger": "chord", "buttons": ["ButtonA1"], "macro": {"key": "a"}}]}`,
+		`{"gestures": [{"trigger": "tap", "buttons": ["ButtonZ1"], "macro": {"key": "a"}}]}`,
+		`{"gestures": [{"trigger": "tap", "buttons": ["ButtonA1"], "macro": {}}]}`,
+		`{"gestures": [{"trigger": "tap", "buttons": ["ButtonA1"], "macro": {"toggle": true, "key": "a"}}]}`,
+		`{"gestures": [{"trigger": "tap", "buttons": ["ButtonA1"], "macro": {"key": "a"}}, {"trigger": "tap", "buttons": ["ButtonA1"], "macro": {"key": "b"}}]}`,
+		`{"keyMacros": {"ButtonA1": {"key": "a"}}, "gestures": [{"trigger": "tap", "buttons": ["ButtonA1"], "macro": {"key": "a"}}]}`,
+		`{"pageButtons": {"ButtonA": "a"}, "gestures": [{"trigger": "chord", "buttons": ["ButtonA", "ButtonB"], "macro": {"key": "a"}}]}`,
+	}
+
+	for i, configuration := range invalid {
+		if _, err := testRecognizer(t, configuration); err == nil {
+			t.Errorf("Invalid configuration %d accepted", i)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+// keyNames are the names of keys that the backends can press besides single characters
+var keyNames = map[string]bool{
+	"backspace": true, "delete": true, "enter": true, "tab": true, "esc": true, "escape": true,
+	"up": true, "down": true, "right": true, "left": true,
+	"home": true, "end": true, "pageup": true, "pagedown": true,
+
+	"f1": true, "f2": true, "f3": true, "f4": true, "f5": true, "f6": true, "f7": true, "f8": true,
+	"f9": true, "f10": true, "f11": true, "f12": true, "f13": true, "f14": true, "f15": true, "f16": true,
+	"f17": true, "f18": true, "f19": true, "f20": true, "f21": true, "f22": true, "f23": true, "f24": true,
+
+	"cmd": true, "lcmd": true, "rcmd": true, "command": true,
+	"alt": true, "lalt": true, "ralt": true,
+	"ctrl": true, "lctrl": true, "rctrl": true, "control": true,
+	"shift": true, "lshift": true, "rshift": true, "right_shift": true,
+	"capslock": true, "space": true, "print": true, "printscreen": true, "insert": true, "menu": true,
+
+	"audio_mute": true, "audio_vol_down": true, "audio_vol_up": true, "audio_play": true,
+	"audio_stop": true, "audio_pause": true, "audio_prev": true, "audio_next": true,
+	"audio_rewind": true, "audio_forward": true, "audio_repeat": true, "audio_random": true,
+
+	"num0": true, "num1": true, "num2": true, "num3": true, "num4": true,
+	"num5": true, "num6": true, "num7": true, "num8": true, "num9": true, "num_lock": true,
+	"num.": true, "num+": true, "num-": true, "num*": true, "num/": true,
+	"num_clear": true, "num_enter": true, "num_equal": true,
+	"numpad_0": true, "numpad_1": true, "numpad_2": true, "numpad_3": true, "numpad_4": true,
+	"numpad_5": true, "numpad_6": true, "numpad_7": true, "numpad_8": true, "numpad_9": true, "numpad_lock": true,
+
+	"lights_mon_up": true, "lights_mon_down": true,
+	"lights_kbd_toggle": true, "lights_kbd_up": true, "lights_kbd_down": true,
+}
+
+// validKey returns whether the key is a single character or a known key name
+func validKey(key string) bool {
+	return len(key) == 1 || keyNames[key]
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+// Colors of the buttons in learn mode, assigned buttons have the color of their macro
+const (
+	colorLearnFree     = lm.ColorAmberLow
+	colorLearnSelected = lm.ColorAmberFull
+)
+
+// parseCombination reads a key combination like "ctrl+shift+t", the last key is pressed with the others as modifiers
+func parseCombination(text string) (KeyCombination, error) {
+	keys := strings.Split(strings.ToLower(strings.Replace(text, " ", "", -1)), "+")
+	for _, key := range keys {
+		if key == "" {
+			return KeyCombination{}, fmt.Errorf("empty key in \"%s\"", text)
+		}
+		if !validKey(key) {
+			return KeyCombination{}, fmt.Errorf("unknown key \"%s\"", key)
+		}
+	}
+
+	return KeyCombination{Key: keys[len(keys)-1], Modifiers: keys[:len(keys)-1]}, nil
+}
+
+// describeMacro returns a short description of a macro like "ctrl+shift+t" or "3 steps"
+func describeMacro(macro Macro) string {
+	if len(macro.Steps) == 1 && macro.Steps[0].Type == StepTap {
+		return strings.Join(append(append([]string{}, macro.Steps[0].Modifiers...), macro.Steps[0].Key), "+")
+	}
+	return fmt.Sprintf("%d steps", len(macro.Steps))
+}
+
+// learnLEDs returns the colors of all buttons in learn mode
+func learnLEDs(c *Configuration) map[byte]byte {
+	leds := make(map[byte]byte, len(lm.ButtonValues))
+	for _, button := range lm.ButtonValues {
+		leds[button] = colorLearnFree
+		if macro, ok := c.Macros[button]; ok {
+			leds[button] = macro.color
+		}
+	}
+	return leds
+}
+
+// learn assigns key combinations to the pressed buttons. After each press the key combination is read from
+// input and the configuration is saved. It ends when input ends.
+func learn(c *Configuration, events <-chan lm.ButtonEvent, input io.Reader, out io.Writer, update func(map[byte]byte)) error {
+	lines := bufio.NewScanner(input)
+	update(learnLEDs(c))
+
+	for {
+		fmt.Fprintf(out, "Press a button to assign a key combination (Ctrl+D to finish)\n")
+		event, ok := <-events
+		if !ok {
+			return fmt.Errorf("launchpad disconnected")
+		}
+		if !event.Pressed {
+			continue
+		}
+
+		button, name := event.Button, lm.ButtonNames[event.Button]
+		leds := learnLEDs(c)
+		leds[button] = colorLearnSelected
+		update(leds)
+
+		if macro, ok := c.Macros[button]; ok {
+			fmt.Fprintf(out, "%s: %s\n", name, describeMacro(macro))
+		}
+		fmt.Fprintf(out, "Key combination for %s like ctrl+shift+t (empty to keep, \"-\" to remove): ", name)
+		if !lines.Scan() {
+			fmt.Fprintln(out)
+			update(learnLEDs(c))
+			return lines.Err()
+		}
+
+		if err := assign(c, button, strings.TrimSpace(lines.Text())); err != nil {
+			fmt.Fprintf(out, "%s not changed: %s\n", name, err.Error())
+		}
+		update(learnLEDs(c))
+	}
+}
+
+// assign changes the macro of the button to the key combination in text and saves the configuration.
+// An empty text keeps the macro, "-" removes it.
+func assign(c *Configuration, button byte, text string) error {
+	if text == "" {
+		return nil
+	}
+
+	previous, assigned := c.Macros[button]
+	if text == "-" {
+		delete(c.Macros, button)
+	} else {
+		combination, err := parseCombination(text)
+		if err != nil {
+			return err
+		}
+		macro := Macro{KeyCombination: combination}
+		if err := macro.Validate(); err != nil {
+			return err
+		}
+		c.Macros[button] = macro
+	}
+
+	// Page, shift and gesture buttons can not have macros
+	_, err := NewSetup(c)
+	if err == nil {
+		err = c.Save()
+	}
+	if err != nil {
+		delete(c.Macros, button)
+		if assigned {
+			c.Macros[button] = previous
+		}
+	}
+	return err
+}
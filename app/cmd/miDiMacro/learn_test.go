@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+func TestParseCombination(t *testing.T) {
+	combination, err := parseCombination("Ctrl + Shift+t")
+	if err != nil || combination.Key != "t" || strings.Join(combination.Modifiers, ",") != "ctrl,shift" {
+		t.Errorf("Wrong combination: %v, %v", combination, err)
+	}
+
+	for _, text := range []string{"ctrl+", "strg+t", "enter+ctrl+"} {
+		if _, err := parseCombination(text); err == nil {
+			t.Errorf("Invalid combination %s parsed", text)
+		}
+	}
+}
+
+func TestLearn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "midimacro")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(path, []byte("keyMacros:\n  ButtonA1: {key: a}\npageButtons:\n  ButtonH1: main\npages:\n  main: {}\n"), 0644)
+
+	config := &Configuration{}
+	if err := config.Load(path); err != nil {
+		t.Fatalf("Configuration not loaded: %s", err.Error())
+	}
+
+	// Learning ends when the input ends after the last press
+	events := make(chan lm.ButtonEvent, 12)
+	for _, button := range []byte{lm.ButtonB1, lm.ButtonB2, lm.ButtonH1, lm.ButtonA1, lm.ButtonB1, lm.ButtonC1} {
+		events <- lm.ButtonEvent{Button: button, Pressed: true}
+		events <- lm.ButtonEvent{Button: button, Pressed: false}
+	}
+	input := strings.NewReader("ctrl+shift+t\nctrl+nokey\nx\n-\n\n")
+	out := &bytes.Buffer{}
+
+	var updates []map[byte]byte
+	update := func(leds map[byte]byte) {
+		updates = append(updates, leds)
+	}
+
+	if err := learn(config, events, input, out, update); err != nil {
+		t.Fatalf("Learning failed: %s", err.Error())
+	}
+
+	// ButtonB1 is pressed again at the end but keeps its macro
+	if macro, ok := config.Macros[lm.ButtonB1]; !ok || describeMacro(macro) != "ctrl+shift+t" {
+		t.Errorf("Wrong macro for ButtonB1: %v", macro)
+	}
+	if _, ok := config.Macros[lm.ButtonB2]; ok {
+		t.Errorf("Invalid combination assigned to ButtonB2")
+	}
+	if _, ok := config.Macros[lm.ButtonH1]; ok {
+		t.Errorf("Macro assigned to page button ButtonH1")
+	}
+	if _, ok := config.Macros[lm.ButtonA1]; ok {
+		t.Errorf("Macro of ButtonA1 not removed")
+	}
+	if !strings.Contains(out.String(), "ButtonB2 not changed: unknown key \"nokey\"") {
+		t.Errorf("Error not shown:\n%s", out.String())
+	}
+
+	// The pressed button is selected and assigned buttons are shown with their color
+	if len(updates) != 13 || updates[1][lm.ButtonB1] != colorLearnSelected || updates[0][lm.ButtonA1] != defaultColor {
+		t.Errorf("Wrong lights: %v", updates)
+	}
+	if last := updates[len(updates)-1]; last[lm.ButtonB1] != defaultColor || last[lm.ButtonA1] != colorLearnFree {
+		t.Errorf("Wrong lights at the end: %v", last)
+	}
+
+	saved := Configuration{}
+	if err := saved.Load(path); err != nil {
+		t.Fatalf("Saved configuration not loaded: %s", err.Error())
+	}
+	if len(saved.Macros) != 1 || describeMacro(saved.Macros[lm.ButtonB1]) != "ctrl+shift+t" || len(saved.PageButtons) != 1 {
+		t.Errorf("Wrong saved configuration: %v", saved)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Syslog priorities of log messages
+const (
+	priorityError   = 3
+	priorityWarning = 4
+	priorityInfo    = 6
+)
+
+var levelNames = map[int]string{priorityError: "error", priorityWarning: "warning", priorityInfo: "info"}
+
+// Logger writes messages with key value pairs as single lines in logfmt like
+//
+//	time=2020-01-02T15:04:05+01:00 level=error msg="macro failed" macro=ButtonA1 error="exit status 1"
+//
+// When stderr is read by journald (JOURNAL_STREAM is set) the lines start with the priority like "<3>", which
+// journald uses as the priority of the message, and have no time, which journald adds itself.
+type Logger struct {
+	mutex   sync.Mutex
+	out     io.Writer
+	journal bool
+	now     func() time.Time
+}
+
+// logger is used for all messages while miDiMacro runs
+var logger = NewLogger(os.Stderr, os.Getenv("JOURNAL_STREAM") != "")
+
+// NewLogger creates a logger writing to out, with journald priority prefixes if journal is set
+func NewLogger(out io.Writer, journal bool) *Logger {
+	return &Logger{out: out, journal: journal, now: time.Now}
+}
+
+// Error logs an error message with pairs of keys and values
+func (l *Logger) Error(message string, fields ...interface{}) {
+	l.log(priorityError, message, fields)
+}
+
+// Warning logs a warning message with pairs of keys and values
+func (l *Logger) Warning(message string, fields ...interface{}) {
+	l.log(priorityWarning, message, fields)
+}
+
+// Info logs an informational message with pairs of keys and values
+func (l *Logger) Info(message string, fields ...interface{}) {
+	l.log(priorityInfo, message, fields)
+}
+
+func (l *Logger) log(priority int, message string, fields []interface{}) {
+	line := &bytes.Buffer{}
+	if l.journal {
+		fmt.Fprintf(line, "<%d>", priority)
+	} else {
+		fmt.Fprintf(line, "time=%s ", l.now().Format(time.RFC3339))
+	}
+	fmt.Fprintf(line, "level=%s msg=%s", levelNames[priority], logValue(message))
+
+	for i := 0; i < len(fields); i += 2 {
+		key := fmt.Sprint(fields[i])
+		var value interface{} = "MISSING"
+		if i+1 < len(fields) {
+			value = fields[i+1]
+		}
+		fmt.Fprintf(line, " %s=%s", key, logValue(value))
+	}
+	line.WriteByte('\n')
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.out.Write(line.Bytes())
+}
+
+// logValue formats a value, which is quoted if it is empty or contains spaces, quotes or equal signs.
+// Messages that span several lines are kept in one line.
+func logValue(value interface{}) string {
+	var text string
+	switch v := value.(type) {
+	case error:
+		text = v.Error()
+	case string:
+		text = v
+	default:
+		text = fmt.Sprint(v)
+	}
+
+	if text == "" || strings.ContainsAny(text, " \"=\n\t") {
+		return strconv.Quote(text)
+	}
+	return text
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := NewLogger(out, false)
+	l.now = func() time.Time {
+		return time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)
+	}
+
+	l.Error("macro failed", "macro", "ButtonA1", "error", fmt.Errorf("command ls failed:\n\"x\""))
+	l.Info("started", "value", 3, "empty", "", "odd")
+	expected := "time=2020-01-02T15:04:05Z level=error msg=\"macro failed\" macro=ButtonA1 error=\"command ls failed:\\n\\\"x\\\"\"\n" +
+		"time=2020-01-02T15:04:05Z level=info msg=started value=3 empty=\"\" odd=MISSING\n"
+	if out.String() != expected {
+		t.Errorf("Wrong log:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+
+	// journald gets the priority and adds the time itself
+	out.Reset()
+	l = NewLogger(out, true)
+	l.Warning("state", "on", true)
+	if out.String() != "<4>level=warning msg=state on=true\n" {
+		t.Errorf("Wrong journal log: %s", out.String())
+	}
+}
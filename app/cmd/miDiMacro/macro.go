@@ -0,0 +1,378 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+// Step types
+const (
+	StepTap    = "tap"    // Press and release a key with optional modifiers
+	StepDown   = "down"   // Press modifiers and a key and keep them pressed
+	StepUp     = "up"     // Release a key and modifiers
+	StepType   = "type"   // Type a text
+	StepWait   = "wait"   // Wait for a number of milliseconds
+	StepRepeat = "repeat" // Execute the nested steps a number of times
+
+	StepMove        = "move"        // Move the mouse cursor to a position or by an offset (relative)
+	StepClick       = "click"       // Click a mouse button
+	StepDoubleClick = "doubleclick" // Double click a mouse button
+	StepDrag        = "drag"        // Press a mouse button, move to a position or by an offset (relative) and release it
+	StepScroll      = "scroll"      // Scroll horizontally (x) and vertically (y)
+
+	StepCommand = "command" // Execute a program
+	StepHTTP    = "http"    // Send an HTTP request (POST by default) with a templated body
+
+	StepScript = "script" // Run a Starlark script from the configuration directory
+
+	StepNoteOn        = "noteOn"        // Send a note on message to the midiOutput
+	StepNoteOff       = "noteOff"       // Send a note off message
+	StepNote          = "note"          // Send note on and after a number of milliseconds (default 100) note off
+	StepController    = "controller"    // Send a controller value
+	StepProgramChange = "programChange" // Send a program change
+	StepSysEx         = "sysex"         // Send a system exclusive message
+)
+
+var mouseButtons = map[string]bool{"left": true, "center": true, "right": true}
+
+// Macro is a list of steps that are executed in order when its button is pressed
+type Macro struct {
+	Steps          []Step `json:"steps"`
+	KeyCombination        // Single key combination without steps (old configuration format)
+
+	Color        string `json:"color,omitempty"`        // Color of the button like "ColorGreenLow"
+	PressedColor string `json:"pressedColor,omitempty"` // Color of the button while the macro runs
+
+	// Toggle macros run their steps when switched on and their offSteps when switched off. Switching on a
+	// macro of a radio group switches off the other macros of the group. Macros with a group are toggles.
+	Toggle   bool   `json:"toggle,omitempty"`
+	OffSteps []Step `json:"offSteps,omitempty"`
+	OnColor  string `json:"onColor,omitempty"` // Color of the button while the toggle is on
+	Group    string `json:"group,omitempty"`
+
+	id           string // Button name, prefixed with the page name for macros of pages
+	color        byte
+	pressedColor byte
+	onColor      byte
+}
+
+// Default colors of buttons with macros
+const (
+	defaultColor        = lm.ColorGreenLow
+	defaultPressedColor = lm.ColorAmberFull
+	defaultOnColor      = lm.ColorGreenFull
+)
+
+// Step is a single action of a macro
+type Step struct {
+	Type      string   `json:"type"`
+	Key       string   `json:"key,omitempty"`
+	Modifiers []string `json:"modifiers,omitempty"`
+	Text      string   `json:"text,omitempty"`
+	Duration  int      `json:"ms,omitempty"`
+	Count     int      `json:"count,omitempty"`
+	Steps     []Step   `json:"steps,omitempty"`
+
+	X        int    `json:"x,omitempty"`
+	Y        int    `json:"y,omitempty"`
+	Relative bool   `json:"relative,omitempty"`
+	Button   string `json:"button,omitempty"` // left (default), center or right
+
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`     // Added to the environment of miDiMacro
+	Dir     string            `json:"dir,omitempty"`     // Working directory
+	Shell   bool              `json:"shell,omitempty"`   // Run the command with "sh -c" instead of directly
+	Timeout int               `json:"timeout,omitempty"` // Milliseconds until commands, requests and scripts are aborted (default 30s)
+
+	URL     string            `json:"url,omitempty"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"` // Template (text/template) that gets the Event as data
+
+	Script string `json:"script,omitempty"` // Script file, relative to the configuration file
+
+	Channel    int    `json:"channel,omitempty"` // Midi channel from 1 (default) to 16
+	Note       string `json:"note,omitempty"`    // Note name like "C4" or number like "60"
+	Velocity   int    `json:"velocity,omitempty"`
+	Controller int    `json:"controller,omitempty"`
+	Value      int    `json:"value,omitempty"`
+	Program    int    `json:"program,omitempty"`
+	Data       string `json:"data,omitempty"` // SysEx data in hex like "F0 7E 7F 06 01 F7"
+
+	body  *template.Template
+	note  byte
+	sysex []byte
+}
+
+// KeyCombination describes the macro key combination and consists of one key and optional modifiers.
+// It is the configuration format of macros before steps were introduced and is converted into a single tap step.
+type KeyCombination struct {
+	Key       string   `json:"key,omitempty"`
+	Modifiers []string `json:"modifiers,omitempty"`
+}
+
+// Validate checks the colors and all steps and returns the first error. A key combination and steps without a type
+// but with a key become taps.
+func (m *Macro) Validate() error {
+	if len(m.Steps) == 0 && m.Key != "" {
+		m.Steps = []Step{{Type: StepTap, Key: m.Key, Modifiers: m.Modifiers}}
+		m.KeyCombination = KeyCombination{}
+	}
+	if m.Group != "" {
+		m.Toggle = true
+	}
+	if len(m.Steps) == 0 && (!m.Toggle || len(m.OffSteps) == 0) {
+		return fmt.Errorf("macro without steps")
+	}
+	if !m.Toggle && len(m.OffSteps) > 0 {
+		return fmt.Errorf("offSteps are only used by toggle macros")
+	}
+
+	var err error
+	if m.color, err = colorValue(m.Color, defaultColor); err != nil {
+		return err
+	}
+	if m.pressedColor, err = colorValue(m.PressedColor, defaultPressedColor); err != nil {
+		return err
+	}
+	if m.onColor, err = colorValue(m.OnColor, defaultOnColor); err != nil {
+		return err
+	}
+
+	if err := validateSteps(m.OffSteps); err != nil {
+		return fmt.Errorf("off %s", err.Error())
+	}
+	return validateSteps(m.Steps)
+}
+
+// colorValue returns the value of the color name or the default value for an empty name
+func colorValue(name string, defaultValue byte) (byte, error) {
+	if name == "" {
+		return defaultValue, nil
+	}
+	color, ok := lm.ColorNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown color %s", name)
+	}
+	return color, nil
+}
+
+// Run executes all steps of the macro and stops at the first error
+func (m *Macro) Run(event Event) error {
+	return runSteps(m.Steps, event)
+}
+
+// Reports returns whether the macro contains steps that can fail, like commands and requests,
+// whose result should be shown on the button
+func (m *Macro) Reports() bool {
+	return reportingSteps(m.Steps) || reportingSteps(m.OffSteps)
+}
+
+func reportingSteps(steps []Step) bool {
+	for _, step := range steps {
+		if step.Type == StepCommand || step.Type == StepHTTP || reportingSteps(step.Steps) {
+			return true
+		}
+	}
+	return false
+}
+
+// Blocks returns whether the macro contains steps that take a while, like waits, held notes, repeats, scripts,
+// commands and requests. Such macros are not run by the main loop, which would stop handling buttons meanwhile.
+func (m *Macro) Blocks() bool {
+	return m.Reports() || blockingSteps(m.Steps) || blockingSteps(m.OffSteps)
+}
+
+func blockingSteps(steps []Step) bool {
+	for _, step := range steps {
+		switch step.Type {
+		case StepWait, StepNote, StepRepeat, StepScript:
+			return true
+		}
+		if blockingSteps(step.Steps) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateSteps(steps []Step) error {
+	for i := range steps {
+		if err := steps[i].validate(); err != nil {
+			return fmt.Errorf("step %d: %s", i+1, err.Error())
+		}
+	}
+	return nil
+}
+
+func runSteps(steps []Step, event Event) error {
+	for i := range steps {
+		if err := steps[i].run(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Step) validate() error {
+	// Steps with only a key are taps
+	if s.Type == "" && s.Key != "" {
+		s.Type = StepTap
+	}
+
+	switch s.Type {
+	case StepTap, StepDown, StepUp:
+		if s.Key == "" {
+			return fmt.Errorf("%s needs a key", s.Type)
+		}
+		for _, key := range append([]string{s.Key}, s.Modifiers...) {
+			if !validKey(key) {
+				return fmt.Errorf("unknown key \"%s\"", key)
+			}
+		}
+	case StepType:
+		if s.Text == "" {
+			return fmt.Errorf("%s needs a text", s.Type)
+		}
+	case StepWait:
+		if s.Duration <= 0 {
+			return fmt.Errorf("%s needs a duration in ms", s.Type)
+		}
+	case StepRepeat:
+		if s.Count <= 0 || len(s.Steps) == 0 {
+			return fmt.Errorf("%s needs a count and steps", s.Type)
+		}
+		return validateSteps(s.Steps)
+	case StepMove:
+	case StepClick, StepDoubleClick, StepDrag:
+		if s.Button == "" {
+			s.Button = "left"
+		}
+		if !mouseButtons[s.Button] {
+			return fmt.Errorf("unknown mouse button \"%s\"", s.Button)
+		}
+	case StepScroll:
+		if s.X == 0 && s.Y == 0 {
+			return fmt.Errorf("%s needs an x or y amount", s.Type)
+		}
+	case StepCommand:
+		if s.Command == "" {
+			return fmt.Errorf("%s needs a command", s.Type)
+		}
+		if s.Shell && len(s.Args) > 0 {
+			return fmt.Errorf("%s with shell takes the arguments in the command", s.Type)
+		}
+	case StepHTTP:
+		if !strings.HasPrefix(s.URL, "http://") && !strings.HasPrefix(s.URL, "https://") {
+			return fmt.Errorf("%s needs an http or https url", s.Type)
+		}
+		if err := s.compileBody(); err != nil {
+			return fmt.Errorf("error in body template: %s", err.Error())
+		}
+	case StepScript:
+		if s.Script == "" {
+			return fmt.Errorf("%s needs a script file", s.Type)
+		}
+	case StepNoteOn, StepNoteOff, StepNote, StepController, StepProgramChange, StepSysEx:
+		return s.validateMidi()
+	default:
+		return fmt.Errorf("unknown step type \"%s\"", s.Type)
+	}
+
+	return nil
+}
+
+func (s *Step) run(event Event) error {
+	backend := event.input()
+
+	switch s.Type {
+	case StepTap:
+		// robotgo.KeyTap does not work with more than one modifier in a slice, so the modifiers are
+		// pressed separately, which allows any number of them
+		if err := keysDown(backend, s.Modifiers); err != nil {
+			return err
+		}
+		err := backend.KeyTap(s.Key)
+		if upErr := keysUp(backend, s.Modifiers); err == nil {
+			err = upErr
+		}
+		return err
+	case StepDown:
+		if err := keysDown(backend, s.Modifiers); err != nil {
+			return err
+		}
+		return backend.KeyDown(s.Key)
+	case StepUp:
+		if err := backend.KeyUp(s.Key); err != nil {
+			return err
+		}
+		return keysUp(backend, s.Modifiers)
+	case StepType:
+		return backend.Type(s.Text)
+	case StepWait:
+		time.Sleep(time.Duration(s.Duration) * time.Millisecond)
+	case StepRepeat:
+		for i := 0; i < s.Count; i++ {
+			if err := runSteps(s.Steps, event); err != nil {
+				return err
+			}
+		}
+	case StepMove:
+		return backend.Move(s.X, s.Y, s.Relative)
+	case StepClick:
+		return backend.Click(s.Button, false)
+	case StepDoubleClick:
+		return backend.Click(s.Button, true)
+	case StepDrag:
+		if err := backend.MouseDown(s.Button); err != nil {
+			return err
+		}
+		err := backend.Move(s.X, s.Y, s.Relative)
+		if upErr := backend.MouseUp(s.Button); err == nil {
+			err = upErr
+		}
+		return err
+	case StepScroll:
+		return backend.Scroll(s.X, s.Y)
+	case StepCommand:
+		return s.runCommand()
+	case StepHTTP:
+		return s.runRequest(event)
+	case StepScript:
+		if event.scripts == nil {
+			return fmt.Errorf("script %s is not loaded", s.Script)
+		}
+		return event.scripts.Run(s.Script, event, s.timeout())
+	case StepNoteOn, StepNoteOff, StepNote, StepController, StepProgramChange, StepSysEx:
+		return s.runMidi(event)
+	default:
+		return fmt.Errorf("unknown step type \"%s\"", s.Type)
+	}
+
+	return nil
+}
+
+func keysDown(backend Backend, keys []string) error {
+	for _, key := range keys {
+		if err := backend.KeyDown(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keysUp releases the keys in reverse order. All keys are released even if one fails.
+func keysUp(backend Backend, keys []string) error {
+	var err error
+	for i := len(keys) - 1; i >= 0; i-- {
+		if keyErr := backend.KeyUp(keys[i]); err == nil {
+			err = keyErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+func TestMacroCompatibility(t *testing.T) {
+	macro := Macro{}
+	err := json.Unmarshal([]byte(`{"key": "9", "modifiers": ["ctrl", "cmd"]}`), &macro)
+	if err != nil {
+		t.Fatalf("Key combination not loaded: %s", err.Error())
+	}
+
+	if err := macro.Validate(); err != nil || macro.color != defaultColor || macro.pressedColor != defaultPressedColor {
+		t.Errorf("Macro without colors does not use the default colors")
+	}
+	if len(macro.Steps) != 1 {
+		t.Fatalf("Wrong number of steps: Got %d, expected 1", len(macro.Steps))
+	}
+	step := macro.Steps[0]
+	if step.Type != StepTap || step.Key != "9" || len(step.Modifiers) != 2 || step.Modifiers[1] != "cmd" {
+		t.Errorf("Wrong step: %v", step)
+	}
+}
+
+func TestMacroSteps(t *testing.T) {
+	macro := Macro{}
+	err := json.Unmarshal([]byte(`{"color": "ColorRedLow", "pressedColor": "ColorRedFlashing", "steps": [
+		{"key": "a", "modifiers": ["ctrl", "alt", "shift", "cmd", "rctrl", "space"]},
+		{"type": "repeat", "count": 2, "steps": [{"type": "type", "text": "hello"}, {"type": "wait", "ms": 10}]}
+	]}`), &macro)
+	if err != nil {
+		t.Fatalf("Macro not loaded: %s", err.Error())
+	}
+
+	if err := macro.Validate(); err != nil {
+		t.Fatalf("Valid macro not accepted: %s", err.Error())
+	}
+	if macro.color != lm.ColorRedLow || macro.pressedColor != lm.ColorRedFlashing {
+		t.Errorf("Wrong colors: %d, %d", macro.color, macro.pressedColor)
+	}
+	if macro.Steps[0].Type != StepTap || len(macro.Steps[0].Modifiers) != 6 {
+		t.Errorf("Step with key not loaded as tap: %v", macro.Steps[0])
+	}
+	if len(macro.Steps[1].Steps) != 2 || macro.Steps[1].Steps[1].Duration != 10 {
+		t.Errorf("Wrong nested steps: %v", macro.Steps[1])
+	}
+	if !macro.Blocks() || macro.Reports() {
+		t.Errorf("Macro with waits must block without reporting")
+	}
+
+	mouse := Macro{Steps: []Step{{Type: StepMove, X: -10, Relative: true}, {Type: StepDoubleClick}, {Type: StepDrag, X: 5, Button: "right"}}}
+	if err := mouse.Validate(); err != nil || mouse.Steps[1].Button != "left" {
+		t.Errorf("Valid mouse steps not accepted: %v", err)
+	}
+	if mouse.Blocks() {
+		t.Errorf("Mouse steps must not block")
+	}
+
+	invalid := []Macro{
+		{},
+		{Steps: []Step{{Type: StepTap}}},
+		{Steps: []Step{{Type: StepType}}},
+		{Steps: []Step{{Type: StepWait}}},
+		{Steps: []Step{{Type: StepRepeat, Count: 2}}},
+		{Steps: []Step{{Type: StepRepeat, Count: 2, Steps: []Step{{Type: "jump"}}}}},
+		{Steps: []Step{{Type: "jump"}}},
+		{Steps: []Step{{Type: StepClick, Button: "middle"}}},
+		{Steps: []Step{{Type: StepScroll}}},
+		{Steps: []Step{{Key: "a"}}, Color: "ColorBlue"},
+		{Steps: []Step{{Key: "a"}}, PressedColor: "Red"},
+	}
+	for i, macro := range invalid {
+		if err := macro.Validate(); err == nil {
+			t.Errorf("Invalid macro %d accepted", i)
+		}
+	}
+}
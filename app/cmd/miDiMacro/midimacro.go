@@ -7,7 +7,6 @@ package main
  *
  * TODOs:
  *
- *	- Support more than just Keyboard shortcuts (maybe mouse macros?)
  *  - Port to Windows :-/
  *  - Port to / test on Mac :-/
  *
@@ -17,28 +16,52 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
+	"os/signal"
 	"os/user"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	lm "github.com/sirion/gomidi/lib/launchpadmini"
-
-	"github.com/go-vgo/robotgo"
+	"github.com/sirion/gomidi/lib/midiport"
 )
 
-// KeyCombination describes the macro key combination and consists of one key and optional modifiers
-type KeyCombination struct {
-	Key       string   `json:"key"`
-	Modifiers []string `json:"modifiers,omitempty"`
-}
-
 type Configuration struct {
-	Macros map[byte]KeyCombination `json:"-"`
+	Macros map[byte]Macro `json:"-"`
+	path   string
+
+	Device    string           `json:"device"`
+	KeyMacros map[string]Macro `json:"keyMacros"`
+
+	// Pages with their own macros, switched by page buttons or active while a shift button is held.
+	// The global keyMacros are used on all pages for buttons without a macro on the page.
+	Pages        map[string]*Page  `json:"pages,omitempty"`
+	PageButtons  map[string]string `json:"pageButtons,omitempty"`  // Button name to page name
+	ShiftButtons map[string]string `json:"shiftButtons,omitempty"` // Button name to page name
+	StartPage    string            `json:"startPage,omitempty"`
 
-	Device    string                    `json:"device"`
-	KeyMacros map[string]KeyCombination `json:"keyMacros"`
+	// Profiles switch pages when the active window changes
+	Profiles []Profile `json:"profiles,omitempty"`
+
+	// Gestures bind macros to taps, long presses, double taps, releases and chords of buttons without macros
+	Gestures  []Gesture `json:"gestures,omitempty"`
+	LongPress int       `json:"longPress,omitempty"` // Milliseconds a button has to be held for a long press (default 500)
+	DoubleTap int       `json:"doubleTap,omitempty"` // Milliseconds between the taps of a double tap (default 300)
+
+	// Faders and knobs of a second midi device (see package midiport for valid values) that move the mouse
+	ControlDevice string    `json:"controlDevice,omitempty"`
+	Controls      []Control `json:"controls,omitempty"`
+
+	// Port that midi steps send to (see package midiport for valid values)
+	MidiOutput string `json:"midiOutput,omitempty"`
+
+	// Backend that presses keys and moves the mouse, robotgo (default) or uinput
+	Backend string `json:"backend,omitempty"`
 }
 
 func getUserDir() string {
@@ -50,102 +73,558 @@ func getUserDir() string {
 	return user.HomeDir
 }
 
-func (c *Configuration) Save() {
-	directory := filepath.Join(getUserDir(), ".config", "midimacro")
-	err := os.MkdirAll(directory, os.ModePerm)
+// Save writes the configuration with the global macros to its file or the default configuration file. The format
+// is selected by the file extension. Only the global macros of existing YAML files are changed, so their comments
+// and the order of their keys are kept. TOML files are not saved, because they would lose their comments.
+func (c *Configuration) Save() error {
+	path := c.path
+	if path == "" {
+		path = filepath.Join(configurationDirectories()[0], "midimacro", configurationFiles[0])
+	}
+	format := fileFormat(path)
+	if format == FormatTOML {
+		return fmt.Errorf("%s would lose its comments, convert it to JSON or YAML with \"miDiMacro convert\" to change it", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	c.KeyMacros = make(map[string]Macro, len(c.Macros))
+	for key, macro := range c.Macros {
+		c.KeyMacros[lm.ButtonNames[key]] = macro
+	}
+
+	if format == FormatYAML {
+		if data, err := ioutil.ReadFile(path); err == nil {
+			out, err := updateYAML(data, c.KeyMacros)
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(path, out, 0644)
+		}
+	}
+
+	out, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	out, err = encodeConfiguration(format, out)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, out, 0644)
+}
+
+// Load reads and checks the configuration from the given JSON, YAML or TOML file. Errors in the file are returned
+// as ConfigErrors, with lines for JSON and YAML files.
+func (c *Configuration) Load(path string) error {
+	c.path = path
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatalf("Error saving configuration file: %s", err.Error())
+		return err
+	}
+
+	data, lines, err := decodeConfiguration(fileFormat(path), data)
+	if err != nil {
+		return err
+	}
+
+	err = parseConfiguration(data, c)
+	if err == nil {
+		if c.Device == "" {
+			c.Device = "auto"
+		}
+		err = c.check(data)
+	}
+
+	if errs, ok := err.(ConfigErrors); ok && !lines {
+		for i := range errs {
+			errs[i].Line = 0
+		}
+	}
+	return err
+}
+
+// convert writes the configuration file from to the file to in the format of its extension. A file name without
+// directory is created next to the source file.
+func convert(from, to string) error {
+	config := Configuration{}
+	if err := config.Load(from); err != nil {
+		return fmt.Errorf("error in configuration file %s:\n%s", from, err.Error())
 	}
 
-	c.KeyMacros = make(map[string]KeyCombination, len(c.Macros))
-	for key, combo := range c.Macros {
-		c.KeyMacros[lm.ButtonNames[key]] = combo
+	if filepath.Base(to) == to {
+		to = filepath.Join(filepath.Dir(from), to)
+	}
+	if _, err := os.Stat(to); err == nil {
+		return fmt.Errorf("%s already exists", to)
 	}
 
-	out, err := json.MarshalIndent(c, "", "  ")
+	data, err := ioutil.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	data, _, err = decodeConfiguration(fileFormat(from), data)
+	if err != nil {
+		return err
+	}
+	out, err := encodeConfiguration(fileFormat(to), data)
 	if err != nil {
-		log.Fatalf("Error formatting configuration file: %s", err.Error())
+		return err
+	}
+
+	return ioutil.WriteFile(to, out, 0644)
+}
+
+// StatePath returns the path of the file with the state of toggle macros, which is next to the configuration file
+func (c *Configuration) StatePath() string {
+	return filepath.Join(filepath.Dir(c.path), "state.json")
+}
+
+// walkSteps calls f for all steps of all macros including the nested steps of repeats. The global macros must
+// already be converted.
+func (c *Configuration) walkSteps(f func(step Step)) {
+	var walk func(steps []Step)
+	walk = func(steps []Step) {
+		for _, step := range steps {
+			f(step)
+			walk(step.Steps)
+		}
 	}
 
-	ioutil.WriteFile(filepath.Join(directory, "config.json"), out, os.ModePerm)
+	macros := []Macro{}
+	for _, macro := range c.Macros {
+		macros = append(macros, macro)
+	}
+	for _, page := range c.Pages {
+		for _, macro := range page.KeyMacros {
+			macros = append(macros, macro)
+		}
+	}
+	for _, gesture := range c.Gestures {
+		macros = append(macros, gesture.Macro)
+	}
+	for _, macro := range macros {
+		walk(macro.Steps)
+		walk(macro.OffSteps)
+	}
 }
 
-func (c *Configuration) Load() {
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		// miDiMacro convert [FROM] TO
+		args := os.Args[2:]
+		if len(args) == 1 {
+			args = append([]string{findConfiguration(configurationDirectories())}, args...)
+		}
+		if len(args) != 2 {
+			log.Fatalf("Usage: miDiMacro convert [FROM] TO")
+		}
+		if err := convert(args[0], args[1]); err != nil {
+			log.Fatalf("Error converting configuration: %s", err.Error())
+		}
+		fmt.Printf("Converted %s to %s\n", args[0], args[1])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		// miDiMacro ctl [--socket PATH] COMMAND
+		flags := flag.NewFlagSet("ctl", flag.ExitOnError)
+		socket := flags.String("socket", defaultSocketPath(), "Path of the control socket")
+		flags.Parse(os.Args[2:])
+
+		if err := runClient(*socket, flags.Args(), os.Stdout); err != nil {
+			log.Fatalf("%s", err.Error())
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "service" {
+		// miDiMacro service [--install] [-- ARGUMENTS]
+		flags := flag.NewFlagSet("service", flag.ExitOnError)
+		install := flags.Bool("install", false, "Write the unit into the systemd user directory instead of printing it")
+		flags.Parse(os.Args[2:])
+
+		program, err := os.Executable()
+		if err != nil {
+			log.Fatalf("Could not find miDiMacro: %s", err.Error())
+		}
+		unit, err := serviceUnit(program, flags.Args())
+		if err != nil {
+			log.Fatalf("Error creating service: %s", err.Error())
+		}
+		if !*install {
+			os.Stdout.Write(unit)
+			return
+		}
+
+		path := servicePath()
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			log.Fatalf("Error creating service: %s", err.Error())
+		}
+		if err := ioutil.WriteFile(path, unit, 0644); err != nil {
+			log.Fatalf("Error creating service: %s", err.Error())
+		}
+		fmt.Printf("Created %s, start it with:\n", path)
+		fmt.Printf("  systemctl --user import-environment DISPLAY XAUTHORITY\n")
+		fmt.Printf("  systemctl --user daemon-reload\n")
+		fmt.Printf("  systemctl --user enable --now midimacro\n")
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "learn" {
+		// miDiMacro learn [--device DEVICE] [--config PATH]
+		flags := flag.NewFlagSet("learn", flag.ExitOnError)
+		device := flags.String("device", "", "Override configured midi device path")
+		configurationPath := flags.String("config", "", "Override configuration file path")
+		flags.Parse(os.Args[2:])
+
+		if *configurationPath == "" {
+			*configurationPath = findConfiguration(configurationDirectories())
+		}
+
+		// A missing configuration file is created
+		config := &Configuration{path: *configurationPath, Device: "auto", Macros: map[byte]Macro{}}
+		if _, err := os.Stat(*configurationPath); err == nil {
+			if err := config.Load(*configurationPath); err != nil {
+				log.Fatalf("Error in configuration file %s:\n%s", *configurationPath, err.Error())
+			}
+		}
+		// The device given on the command line is not saved
+		if *device == "" {
+			*device = config.Device
+		}
+
+		lp := lm.New(*device)
+		err := learn(config, lp.ListenEvents(), os.Stdin, os.Stdout, lp.RapidUpdate)
+		lp.Reset()
+		lp.Close()
+		if err != nil {
+			log.Fatalf("Error learning macros: %s", err.Error())
+		}
+		return
+	}
+
 	device := flag.String("device", "", "Override configured midi device path")
 	configurationPath := flag.String("config", "", "Override configuration file path")
+	page := flag.String("page", "", "Use this page and do not switch pages by the active window")
+	check := flag.Bool("check", false, "Check the configuration file and exit")
+	interval := flag.Duration("interval", time.Second, "Interval to check the configuration file for changes")
+	daemon := flag.Bool("daemon", false, "Accept commands on the control socket")
+	socket := flag.String("socket", defaultSocketPath(), "Path of the control socket")
+	dryRun := flag.Bool("dry-run", false, "Log keys and mouse actions instead of sending them")
 	flag.Parse()
 
 	if *configurationPath == "" {
-		directory := filepath.Join(getUserDir(), ".config", "midimacro")
-		*configurationPath = filepath.Join(directory, "config.json")
+		*configurationPath = findConfiguration(configurationDirectories())
+	}
+
+	load := func() (*Setup, error) {
+		config := &Configuration{}
+		if err := config.Load(*configurationPath); err != nil {
+			return nil, err
+		}
+
+		if *device != "" {
+			config.Device = *device
+		}
+		if *page != "" {
+			config.StartPage = *page
+			config.Profiles = nil
+		}
+		if *dryRun {
+			config.Backend = BackendLog
+		}
+
+		return NewSetup(config)
 	}
 
-	data, err := ioutil.ReadFile(*configurationPath)
+	setup, err := load()
 	if err != nil {
-		log.Fatalf("Error loading configuration file: %s", err.Error())
+		log.Fatalf("Error in configuration file %s:\n%s", *configurationPath, err.Error())
+	}
+	if *check {
+		fmt.Printf("Configuration file %s is valid\n", *configurationPath)
+		return
+	}
+	config := setup.config
+
+	lp := lm.New(config.Device)
+
+	// Buttons whose light has to be restored after showing the result of a macro
+	restore := make(chan byte)
+
+	var output io.Writer
+	if config.MidiOutput != "" {
+		port, err := midiport.Open(config.MidiOutput)
+		if err != nil {
+			log.Fatalf("Could not open midi output %s: %s", config.MidiOutput, err.Error())
+		}
+		defer port.Close()
+		output = &midiOutput{port: port}
 	}
 
-	err = json.Unmarshal(data, c)
+	backend, err := openBackend(config.Backend)
 	if err != nil {
-		log.Fatalf("Error parsing configuration file: %s", err.Error())
+		log.Fatalf("Could not open backend %s: %s", config.Backend, err.Error())
+	}
+	defer backend.Close()
+
+	// use activates a setup, the devices and controls are not changed without a restart
+	use := func(s *Setup) {
+		setup = s
+		setup.output = output
+		setup.backend = backend
+		setup.LoadState()
+		setup.scripts.show = func(button byte) {
+			go func() { restore <- button }()
+		}
+		lp.RapidUpdate(setup.LEDs())
 	}
 
-	if *device != "" {
-		c.Device = *device
+	input := lp.ListenEvents()
+	use(setup)
+
+	if len(config.Controls) > 0 {
+		port, err := midiport.Open(config.ControlDevice)
+		if err != nil {
+			log.Fatalf("Could not open control device %s: %s", config.ControlDevice, err.Error())
+		}
+		go func() {
+			err := NewControls(config.Controls, backend).Listen(port)
+			logger.Error("reading from control device failed", "device", config.ControlDevice, "error", err)
+		}()
 	}
 
-	if c.Device == "" {
-		c.Device = "auto"
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	// Long presses and taps that wait for a double tap are triggered by time
+	gestureTicker := time.NewTicker(gestureInterval)
+	defer gestureTicker.Stop()
+	profileTicker := time.NewTicker(profileInterval)
+	defer profileTicker.Stop()
+
+	reloads := make(chan *Setup)
+	go watch(*configurationPath, *interval, load, reloads)
+
+	// Calls of the control socket, which stays nil without --daemon
+	var calls chan func()
+	var listener net.Listener
+	if *daemon {
+		listener, err = listenSocket(*socket)
+		if err != nil {
+			log.Fatalf("Could not create control socket: %s", err.Error())
+		}
+		server := &Daemon{
+			calls: make(chan func()),
+			setup: func() *Setup { return setup },
+			update: func() {
+				lp.RapidUpdate(setup.LEDs())
+			},
+			run: func(button byte, macro *Macro, event Event) {
+				handleMacro(lp, setup, button, macro, event, restore)
+			},
+			reload: func() error {
+				reloaded, err := load()
+				if err != nil {
+					return err
+				}
+				use(reloaded)
+				return nil
+			},
+		}
+		calls = server.calls
+		go server.Serve(listener)
 	}
 
-	c.Macros = make(map[byte]KeyCombination, len(c.KeyMacros))
-	for key, combo := range c.KeyMacros {
-		c.Macros[lm.ButtonValues[key]] = combo
+	// The watchdog of systemd is notified by the main loop, so a blocked loop gets miDiMacro restarted. Nothing that
+	// takes a while runs on the loop: Macros with waits, notes, scripts, commands or requests run in the background
+	// (see Macro.Blocks), so only a hanging launchpad or system stops the notifications.
+	var watchdog <-chan time.Time
+	if interval := watchdogInterval(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		watchdog = ticker.C
+	}
+	if err := notify("READY=1"); err != nil {
+		logger.Warning("notifying systemd failed", "error", err)
+	}
+	logger.Info("started", "config", *configurationPath, "device", config.Device)
+
+	for {
+		select {
+		case received := <-signals:
+			if received == syscall.SIGHUP {
+				reloaded, err := load()
+				if err != nil {
+					logger.Error("configuration not reloaded", "config", *configurationPath, "error", err)
+					continue
+				}
+				use(reloaded)
+				logger.Info("configuration reloaded", "config", *configurationPath)
+				continue
+			}
+
+			// Turn off all lights and close the device
+			logger.Info("stopping", "signal", received)
+			notify("STOPPING=1")
+			if listener != nil {
+				listener.Close()
+			}
+			lp.Reset()
+			lp.Close()
+			return
+
+		case <-watchdog:
+			notify("WATCHDOG=1")
+
+		case reloaded := <-reloads:
+			use(reloaded)
+			logger.Info("configuration reloaded", "config", *configurationPath)
+
+		case call := <-calls:
+			call()
+
+		case button := <-restore:
+			lp.Button(button, setup.LEDs()[button])
+
+		case <-profileTicker.C:
+			if page, ok := setup.profiles.Update(); ok && setup.layers.SetPage(page) {
+				lp.RapidUpdate(setup.LEDs())
+			}
+
+		case now := <-gestureTicker.C:
+			handleTick(lp, setup, now, restore)
+
+		case buttonEvent, ok := <-input:
+			if !ok {
+				// Exit with an error, so systemd restarts miDiMacro when the launchpad is connected again
+				logger.Error("launchpad disconnected", "device", config.Device)
+				if listener != nil {
+					listener.Close()
+				}
+				os.Exit(1)
+			}
+			handleButton(lp, setup, buttonEvent, time.Now(), restore)
+		}
 	}
 }
 
-func main() {
-	config := Configuration{}
-	config.Load()
+// lights shows the state of macros on the buttons of the launchpad
+type lights interface {
+	Button(button, color byte)
+	RapidUpdate(buttons map[byte]byte)
+}
 
-	lp := lm.New(config.Device)
+// handleButton runs the gestures or the macro of a pressed or released button and switches pages
+func handleButton(lp lights, setup *Setup, buttonEvent lm.ButtonEvent, now time.Time, restore chan byte) {
+	setup.scripts.Track(buttonEvent)
 
-	input := lp.Listen()
+	if setup.recognizer.Handles(buttonEvent.Button) {
+		for _, gesture := range setup.recognizer.Event(buttonEvent, now) {
+			runMacro(lp, gesture.Button(), gesture.Macro, setup.Event(gesture.Name(), now), restore)
+		}
+		return
+	}
 
-	for {
-		press := <-input
-
-		keys, ok := config.Macros[press]
-		if ok {
-			pressKey(keys)
-		}
-	}
-
-}
-
-// pressKey only exists because robotgo.KeyTap(string, []string...) does not work
-func pressKey(kc KeyCombination) {
-	switch len(kc.Modifiers) {
-	case 0:
-		robotgo.KeyTap(kc.Key)
-		break
-	case 1:
-		robotgo.KeyTap(kc.Key, kc.Modifiers[0])
-		break
-	case 2:
-		robotgo.KeyTap(kc.Key, kc.Modifiers[0], kc.Modifiers[1])
-		break
-	case 3:
-		robotgo.KeyTap(kc.Key, kc.Modifiers[0], kc.Modifiers[1], kc.Modifiers[2])
-		break
-	case 4:
-		robotgo.KeyTap(kc.Key, kc.Modifiers[0], kc.Modifiers[1], kc.Modifiers[2], kc.Modifiers[3])
-		break
-	case 5:
-		robotgo.KeyTap(kc.Key, kc.Modifiers[0], kc.Modifiers[1], kc.Modifiers[2], kc.Modifiers[3], kc.Modifiers[4])
-		break
+	macro, changed := setup.layers.Handle(buttonEvent)
+	if changed {
+		lp.RapidUpdate(setup.LEDs())
+	}
+	if macro != nil {
+		handleMacro(lp, setup, buttonEvent.Button, macro, setup.Event(lm.ButtonNames[buttonEvent.Button], now), restore)
+	}
+}
+
+// handleTick runs the gestures that are recognized by time, like long presses
+func handleTick(lp lights, setup *Setup, now time.Time, restore chan byte) {
+	for _, gesture := range setup.recognizer.Tick(now) {
+		runMacro(lp, gesture.Button(), gesture.Macro, setup.Event(gesture.Name(), now), restore)
+	}
+}
+
+// handleMacro runs the macro of a pressed button. Toggle macros are switched and their state is saved.
+func handleMacro(lp lights, setup *Setup, button byte, macro *Macro, event Event, restore chan byte) {
+	if !macro.Toggle {
+		runMacro(lp, button, *macro, event, restore)
+		return
+	}
+
+	switches := setup.layers.toggles.Press(macro)
+	lp.RapidUpdate(setup.LEDs())
+	if err := setup.layers.toggles.Save(setup.config.StatePath()); err != nil {
+		logger.Error("saving toggle state failed", "error", err)
+	}
+	// Waits, scripts, commands and requests of any switched macro may take a while
+	blocks := false
+	for _, s := range switches {
+		blocks = blocks || s.Macro.Blocks()
+	}
+	if blocks {
+		go runSwitches(switches, event)
+	} else {
+		runSwitches(switches, event)
+	}
+}
+
+// runMacro runs the macro and shows it on the button. Waits, scripts, commands and requests may take a while, so
+// macros with them run in the background and do not block other buttons.
+func runMacro(lp lights, button byte, macro Macro, event Event, restore chan byte) {
+	switch {
+	case macro.Reports():
+		go runReporting(lp, button, macro, event, restore)
+	case macro.Blocks():
+		go runFlashing(lp, button, macro, event, restore)
 	default:
-		fmt.Print("More than 5 modifiers are not supported")
+		runFlashing(lp, button, macro, event, restore)
+	}
+}
+
+// runFlashing runs a macro and shows the pressed color on the button while it runs and at least for a flash
+func runFlashing(lp lights, button byte, macro Macro, event Event, restore chan byte) {
+	lp.Button(button, macro.pressedColor)
+	if err := macro.Run(event); err != nil {
+		logger.Error("macro failed", "macro", event.Button, "error", err)
+	}
+	go restoreAfter(button, flashDuration, restore)
+}
+
+// Times the state of a macro is shown on its button
+const (
+	flashDuration  = 150 * time.Millisecond
+	resultDuration = 2 * time.Second
+)
+
+// restoreAfter sends the button to the restore channel after the given time
+func restoreAfter(button byte, duration time.Duration, restore chan byte) {
+	time.Sleep(duration)
+	restore <- button
+}
+
+// runReporting runs a macro and shows its state on the button: the pressed color while running, then green on
+// success or red on failure. Afterwards the button is sent to the restore channel.
+func runReporting(lp lights, button byte, macro Macro, event Event, restore chan byte) {
+	lp.Button(button, macro.pressedColor)
+
+	err := macro.Run(event)
+	if err != nil {
+		logger.Error("macro failed", "macro", event.Button, "error", err)
+		lp.Button(button, lm.ColorRedFull)
+	} else {
+		lp.Button(button, lm.ColorGreenFull)
+	}
+
+	restoreAfter(button, resultDuration, restore)
+}
+
+// runSwitches runs the steps of the changed toggle macros
+func runSwitches(switches []Switch, event Event) {
+	for i := range switches {
+		if err := switches[i].Run(event); err != nil {
+			logger.Error("switching macro failed", "macro", switches[i].Macro.id, "on", switches[i].On, "error", err)
+		}
 	}
 }
 
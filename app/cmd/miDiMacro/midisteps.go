@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirion/gomidi/lib/midi"
+)
+
+// Defaults of midi steps
+const (
+	defaultVelocity     = 100
+	defaultNoteDuration = 100 * time.Millisecond
+)
+
+// isMidiStep returns whether steps of the type send midi messages to the midiOutput
+func isMidiStep(stepType string) bool {
+	switch stepType {
+	case StepNoteOn, StepNoteOff, StepNote, StepController, StepProgramChange, StepSysEx:
+		return true
+	}
+	return false
+}
+
+// validateMidi checks the values of a midi step and converts the note name and SysEx data
+func (s *Step) validateMidi() error {
+	if s.Channel < 0 || s.Channel > 16 {
+		return fmt.Errorf("invalid channel %d, must be between 1 and 16", s.Channel)
+	}
+	for name, value := range map[string]int{"velocity": s.Velocity, "controller": s.Controller, "value": s.Value, "program": s.Program} {
+		if value < 0 || value > 127 {
+			return fmt.Errorf("invalid %s %d, must be between 0 and 127", name, value)
+		}
+	}
+
+	switch s.Type {
+	case StepNoteOn, StepNoteOff, StepNote:
+		note, ok := midi.ParseNoteName(s.Note)
+		if !ok {
+			return fmt.Errorf("%s needs a note like \"C4\" or 60, not \"%s\"", s.Type, s.Note)
+		}
+		s.note = note
+	case StepSysEx:
+		data, err := parseSysEx(s.Data)
+		if err != nil {
+			return err
+		}
+		s.sysex = data
+	}
+	return nil
+}
+
+// parseSysEx reads SysEx data in hex like "F0 7E 7F 06 01 F7". The start and end bytes are added if missing.
+func parseSysEx(text string) ([]byte, error) {
+	data, err := hex.DecodeString(strings.Replace(text, " ", "", -1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SysEx data \"%s\": %s", text, err.Error())
+	}
+	if len(data) > 0 && data[0] == byte(midi.TypeSysEx) {
+		data = data[1:]
+	}
+	if len(data) > 0 && data[len(data)-1] == 0xf7 {
+		data = data[:len(data)-1]
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("sysex needs data")
+	}
+	for _, b := range data {
+		if b > 0x7f {
+			return nil, fmt.Errorf("invalid SysEx data \"%s\": byte %02X is larger than 7F", text, b)
+		}
+	}
+
+	return append(append([]byte{byte(midi.TypeSysEx)}, data...), 0xf7), nil
+}
+
+// channel returns the channel of the step from 0 to 15
+func (s *Step) channel() byte {
+	if s.Channel == 0 {
+		return 0
+	}
+	return byte(s.Channel - 1)
+}
+
+// runMidi sends the messages of a midi step to the midi output of the event
+func (s *Step) runMidi(event Event) error {
+	if event.output == nil {
+		return fmt.Errorf("%s needs a midiOutput", s.Type)
+	}
+
+	velocity := byte(defaultVelocity)
+	if s.Velocity > 0 {
+		velocity = byte(s.Velocity)
+	}
+
+	switch s.Type {
+	case StepNoteOn:
+		return send(event.output, midi.NoteOn(s.channel(), s.note, velocity))
+	case StepNoteOff:
+		return send(event.output, midi.NoteOff(s.channel(), s.note, byte(s.Velocity)))
+	case StepNote:
+		if err := send(event.output, midi.NoteOn(s.channel(), s.note, velocity)); err != nil {
+			return err
+		}
+		duration := defaultNoteDuration
+		if s.Duration > 0 {
+			duration = time.Duration(s.Duration) * time.Millisecond
+		}
+		time.Sleep(duration)
+		return send(event.output, midi.NoteOff(s.channel(), s.note, 0))
+	case StepController:
+		return send(event.output, midi.Controller(s.channel(), byte(s.Controller), byte(s.Value)))
+	case StepProgramChange:
+		return send(event.output, midi.ProgramChange(s.channel(), byte(s.Program)))
+	case StepSysEx:
+		return send(event.output, s.sysex)
+	}
+	return fmt.Errorf("unknown step type \"%s\"", s.Type)
+}
+
+func send(output io.Writer, message []byte) error {
+	if _, err := output.Write(message); err != nil {
+		return fmt.Errorf("sending to midiOutput failed: %s", err.Error())
+	}
+	return nil
+}
+
+// midiOutput writes whole messages to the port, so messages of macros running at the same time are not mixed
+type midiOutput struct {
+	mutex sync.Mutex
+	port  io.Writer
+}
+
+func (o *midiOutput) Write(message []byte) (int, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.port.Write(message)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+func TestMidiSteps(t *testing.T) {
+	steps := []Step{
+		{Type: StepNoteOn, Note: "C4"},
+		{Type: StepNoteOff, Note: "60", Channel: 2},
+		{Type: StepNote, Note: "a#3", Velocity: 127, Channel: 16, Duration: 1},
+		{Type: StepController, Controller: 7, Value: 64, Channel: 10},
+		{Type: StepProgramChange, Program: 5},
+		{Type: StepSysEx, Data: "F0 7E 7F 06 01 F7"},
+		{Type: StepSysEx, Data: "7e7f0601"},
+	}
+	expected := []byte{
+		0x90, 60, 100,
+		0x81, 60, 0,
+		0x9f, 58, 127, 0x8f, 58, 0,
+		0xb9, 7, 64,
+		0xc0, 5,
+		0xf0, 0x7e, 0x7f, 0x06, 0x01, 0xf7,
+		0xf0, 0x7e, 0x7f, 0x06, 0x01, 0xf7,
+	}
+
+	if err := validateSteps(steps); err != nil {
+		t.Fatalf("Steps not valid: %s", err.Error())
+	}
+	if macro := (Macro{Steps: steps}); !macro.Blocks() {
+		t.Errorf("Macro holding a note must not run on the main loop")
+	}
+	out := &bytes.Buffer{}
+	if err := runSteps(steps, Event{output: out}); err != nil {
+		t.Fatalf("Steps failed: %s", err.Error())
+	}
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("Wrong messages:\n% x\nexpected:\n% x", out.Bytes(), expected)
+	}
+
+	if err := runSteps(steps, Event{}); err == nil {
+		t.Errorf("Steps without midi output did not fail")
+	}
+
+	invalid := map[string]Step{
+		"no note":      {Type: StepNoteOn},
+		"unknown note": {Type: StepNote, Note: "H4"},
+		"channel":      {Type: StepNoteOn, Note: "C4", Channel: 17},
+		"velocity":     {Type: StepNoteOn, Note: "C4", Velocity: 128},
+		"controller":   {Type: StepController, Controller: 200},
+		"program":      {Type: StepProgramChange, Program: -1},
+		"no data":      {Type: StepSysEx, Data: "F0 F7"},
+		"hex":          {Type: StepSysEx, Data: "F0 7X F7"},
+		"data byte":    {Type: StepSysEx, Data: "F0 80 F7"},
+	}
+	for name, step := range invalid {
+		if err := step.validate(); err == nil {
+			t.Errorf("Invalid step (%s) is valid", name)
+		}
+	}
+}
+
+func TestMidiOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "midimacro")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+
+	ioutil.WriteFile(path, []byte("{\n\"keyMacros\": {\n\"ButtonA1\": {\"key\": \"a\"},\n\"ButtonA2\": {\"steps\": [{\"type\": \"controller\", \"controller\": 1}]}\n}\n}"), 0644)
+	config := &Configuration{}
+	if err := config.Load(path); err == nil || err.Error() != "line 4: midi steps need a midiOutput" {
+		t.Errorf("Wrong error: %v", err)
+	}
+
+	ioutil.WriteFile(path, []byte(`{"midiOutput": "virtual:Launchpad Out", "keyMacros": {"ButtonA2": {"steps": [{"type": "controller", "controller": 1, "value": 2}]}}}`), 0644)
+	config = &Configuration{}
+	if err := config.Load(path); err != nil {
+		t.Fatalf("Configuration not loaded: %s", err.Error())
+	}
+	setup, err := NewSetup(config)
+	if err != nil {
+		t.Fatalf("Setup failed: %s", err.Error())
+	}
+
+	out := &bytes.Buffer{}
+	setup.output = &midiOutput{port: out}
+	macro := setup.config.Macros[lm.ButtonA2]
+	if err := macro.Run(setup.Event("ButtonA2", time.Now())); err != nil || !bytes.Equal(out.Bytes(), []byte{0xb0, 1, 2}) {
+		t.Errorf("Wrong message: % x, %v", out.Bytes(), err)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+// Colors of the page and shift buttons
+const (
+	colorPageActive   = lm.ColorGreenFull
+	colorPageInactive = lm.ColorGreenLow
+	colorShiftActive  = lm.ColorAmberFull
+	colorShiftIdle    = lm.ColorAmberLow
+)
+
+// Page is a named set of macros and button lights. Pages are switched with page buttons or used as
+// shift layers that are active while their button is held.
+type Page struct {
+	KeyMacros map[string]Macro  `json:"keyMacros"`
+	LEDs      map[string]string `json:"leds,omitempty"` // Button name to color name like "ColorGreenLow", overrides macro colors
+
+	macros map[byte]Macro
+	leds   map[byte]byte
+}
+
+// Layers keeps track of the active page and shift layer and finds the macro for a pressed button.
+// Macros of a held shift layer take precedence over the ones of the active page, which take precedence
+// over the global macros.
+type Layers struct {
+	global       map[byte]Macro
+	pages        map[string]*Page
+	pageButtons  map[byte]string
+	shiftButtons map[byte]string
+
+	page        string
+	shift       string // Active shift layer, empty if no shift button is held
+	shiftButton byte
+
+	toggles *Toggles
+}
+
+// compile converts the button and color names of the page
+func (p *Page) compile(pageName string) error {
+	p.macros = make(map[byte]Macro, len(p.KeyMacros))
+	for name, macro := range p.KeyMacros {
+		button, ok := lm.ButtonValues[name]
+		if !ok {
+			return fmt.Errorf("unknown button %s", name)
+		}
+		if err := macro.Validate(); err != nil {
+			return fmt.Errorf("macro %s: %s", name, err.Error())
+		}
+		macro.id = pageName + "/" + name
+		p.macros[button] = macro
+	}
+
+	p.leds = make(map[byte]byte, len(p.LEDs))
+	for name, colorName := range p.LEDs {
+		button, ok := lm.ButtonValues[name]
+		if !ok {
+			return fmt.Errorf("unknown button %s", name)
+		}
+		color, ok := lm.ColorNames[colorName]
+		if !ok {
+			return fmt.Errorf("unknown color %s", colorName)
+		}
+		p.leds[button] = color
+	}
+
+	return nil
+}
+
+// NewLayers creates the layers from the configuration. The global macros must already be converted.
+func NewLayers(c *Configuration) (*Layers, error) {
+	l := &Layers{
+		global:       make(map[byte]Macro, len(c.Macros)),
+		pages:        c.Pages,
+		pageButtons:  make(map[byte]string, len(c.PageButtons)),
+		shiftButtons: make(map[byte]string, len(c.ShiftButtons)),
+		page:         c.StartPage,
+		toggles:      newToggles(),
+	}
+
+	for button, macro := range c.Macros {
+		macro.id = lm.ButtonNames[button]
+		l.global[button] = macro
+		l.toggles.add(macro)
+	}
+	for name, page := range c.Pages {
+		if err := page.compile(name); err != nil {
+			return nil, fmt.Errorf("page %s: %s", name, err.Error())
+		}
+		for _, macro := range page.macros {
+			l.toggles.add(macro)
+		}
+	}
+
+	buttons := map[string]map[byte]string{"page": l.pageButtons, "shift": l.shiftButtons}
+	for kind, names := range map[string]map[string]string{"page": c.PageButtons, "shift": c.ShiftButtons} {
+		for buttonName, pageName := range names {
+			button, ok := lm.ButtonValues[buttonName]
+			if !ok {
+				return nil, fmt.Errorf("unknown %s button %s", kind, buttonName)
+			}
+			if _, ok := c.Pages[pageName]; !ok {
+				return nil, fmt.Errorf("%s button %s: unknown page %s", kind, buttonName, pageName)
+			}
+			if _, ok := c.Macros[button]; ok {
+				return nil, fmt.Errorf("%s button %s also has a macro", kind, buttonName)
+			}
+			buttons[kind][button] = pageName
+		}
+	}
+	for button := range l.pageButtons {
+		if _, ok := l.shiftButtons[button]; ok {
+			return nil, fmt.Errorf("button %s is a page and a shift button", lm.ButtonNames[button])
+		}
+	}
+
+	if l.page == "" && len(c.Pages) > 0 {
+		names := make([]string, 0, len(c.Pages))
+		for name := range c.Pages {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		l.page = names[0]
+	}
+	if _, ok := c.Pages[l.page]; !ok && l.page != "" {
+		return nil, fmt.Errorf("unknown start page %s", l.page)
+	}
+
+	return l, nil
+}
+
+// Handle processes a button event. It returns the macro to run (if any) and whether the lights
+// have to be updated because the page or shift layer changed.
+func (l *Layers) Handle(event lm.ButtonEvent) (*Macro, bool) {
+	if page, ok := l.shiftButtons[event.Button]; ok {
+		if event.Pressed {
+			l.shift, l.shiftButton = page, event.Button
+			return nil, true
+		}
+		if l.shift != "" && l.shiftButton == event.Button {
+			l.shift = ""
+			return nil, true
+		}
+		return nil, false
+	}
+
+	if !event.Pressed {
+		return nil, false
+	}
+
+	if page, ok := l.pageButtons[event.Button]; ok {
+		changed := l.page != page
+		l.page = page
+		return nil, changed
+	}
+
+	return l.Macro(event.Button), false
+}
+
+// Macro returns the macro of the button in the active layers or nil
+func (l *Layers) Macro(button byte) *Macro {
+	for _, name := range []string{l.shift, l.page} {
+		if page, ok := l.pages[name]; ok {
+			if macro, ok := page.macros[button]; ok {
+				return &macro
+			}
+		}
+	}
+
+	if macro, ok := l.global[button]; ok {
+		return &macro
+	}
+	return nil
+}
+
+// SetPage activates the page and returns whether the active page changed
+func (l *Layers) SetPage(name string) bool {
+	changed := l.page != name
+	l.page = name
+	return changed
+}
+
+// Page returns the names of the active page and shift layer
+func (l *Layers) Page() (string, string) {
+	return l.page, l.shift
+}
+
+// LEDs returns the colors of all buttons for the active layers including the page and shift buttons.
+// Buttons with macros have the color of their macro unless the page sets the color of the button.
+func (l *Layers) LEDs() map[byte]byte {
+	leds := make(map[byte]byte)
+
+	for button, macro := range l.global {
+		leds[button] = l.color(macro)
+	}
+	for _, name := range []string{l.page, l.shift} {
+		if page, ok := l.pages[name]; ok {
+			for button, macro := range page.macros {
+				leds[button] = l.color(macro)
+			}
+			for button, color := range page.leds {
+				leds[button] = color
+			}
+		}
+	}
+
+	for button, page := range l.pageButtons {
+		leds[button] = colorPageInactive
+		if page == l.page {
+			leds[button] = colorPageActive
+		}
+	}
+	for button := range l.shiftButtons {
+		leds[button] = colorShiftIdle
+		if l.shift != "" && button == l.shiftButton {
+			leds[button] = colorShiftActive
+		}
+	}
+
+	return leds
+}
+
+// color returns the color of the macro, which is the on color for toggles that are switched on
+func (l *Layers) color(macro Macro) byte {
+	if macro.Toggle && l.toggles.On(macro.id) {
+		return macro.onColor
+	}
+	return macro.color
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"testing"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+const pagesConfiguration = `{
+	"keyMacros": {
+		"ButtonH1": {"key": "h"}
+	},
+	"pages": {
+		"main": {
+			"keyMacros": {"ButtonA1": {"key": "1"}},
+			"leds": {"ButtonA1": "ColorGreenLow"}
+		},
+		"media": {
+			"keyMacros": {"ButtonA1": {"key": "audio_play"}, "ButtonH1": {"key": "audio_mute"}},
+			"leds": {"ButtonA1": "ColorRedLow"}
+		},
+		"shift": {
+			"keyMacros": {"ButtonA2": {"key": "2", "modifiers": ["shift"]}},
+			"leds": {"ButtonA2": "ColorAmberLow"}
+		}
+	},
+	"pageButtons": {"ButtonA": "main", "ButtonB": "media"},
+	"shiftButtons": {"ButtonH": "shift"},
+	"startPage": "main"
+}`
+
+func testLayers(t *testing.T, configuration string) (*Layers, error) {
+	config, err := testConfiguration(t, configuration)
+	if err != nil {
+		return nil, err
+	}
+	return NewLayers(config)
+}
+
+func macroKey(macro *Macro) string {
+	if macro == nil {
+		return ""
+	}
+	return macro.Steps[0].Key
+}
+
+func TestLayers(t *testing.T) {
+	layers, err := testLayers(t, pagesConfiguration)
+	if err != nil {
+		t.Fatalf("Valid configuration not accepted: %s", err.Error())
+	}
+
+	press := func(button byte) (string, bool) {
+		macro, changed := layers.Handle(lm.ButtonEvent{Button: button, Pressed: true})
+		return macroKey(macro), changed
+	}
+	release := func(button byte) bool {
+		_, changed := layers.Handle(lm.ButtonEvent{Button: button, Pressed: false})
+		return changed
+	}
+
+	check := func(key string, changed bool, expectedKey string, expectedChanged bool) {
+		t.Helper()
+		if key != expectedKey || changed != expectedChanged {
+			t.Errorf("Got macro \"%s\" (changed %t), expected \"%s\" (changed %t)", key, changed, expectedKey, expectedChanged)
+		}
+	}
+
+	key, changed := press(lm.ButtonA1)
+	check(key, changed, "1", false)
+	key, changed = press(lm.ButtonH1) // Global macro
+	check(key, changed, "h", false)
+
+	key, changed = press(lm.ButtonB)
+	check(key, changed, "", true)
+	key, changed = press(lm.ButtonA1)
+	check(key, changed, "audio_play", false)
+	key, changed = press(lm.ButtonH1) // The page overrides the global macro
+	check(key, changed, "audio_mute", false)
+	if page, shift := layers.Page(); page != "media" || shift != "" {
+		t.Errorf("Wrong page: %s (%s)", page, shift)
+	}
+
+	// Shift layer while the button is held, other buttons use the page
+	key, changed = press(lm.ButtonH)
+	check(key, changed, "", true)
+	key, changed = press(lm.ButtonA2)
+	check(key, changed, "2", false)
+	key, changed = press(lm.ButtonA1)
+	check(key, changed, "audio_play", false)
+
+	leds := layers.LEDs()
+	expected := map[byte]byte{
+		lm.ButtonA1: lm.ColorRedLow,
+		lm.ButtonA2: lm.ColorAmberLow,
+		lm.ButtonH1: defaultColor,
+		lm.ButtonA:  colorPageInactive,
+		lm.ButtonB:  colorPageActive,
+		lm.ButtonH:  colorShiftActive,
+	}
+	if len(leds) != len(expected) {
+		t.Errorf("Wrong LEDs: %v", leds)
+	}
+	for button, color := range expected {
+		if leds[button] != color {
+			t.Errorf("Wrong color of %s: Got %d, expected %d", lm.ButtonNames[button], leds[button], color)
+		}
+	}
+
+	if !release(lm.ButtonH) {
+		t.Errorf("Releasing the shift button did not change the layers")
+	}
+	key, _ = press(lm.ButtonA2)
+	check(key, false, "", false)
+	if release(lm.ButtonA1) {
+		t.Errorf("Releasing a macro button changed the layers")
+	}
+	if leds := layers.LEDs(); leds[lm.ButtonH] != colorShiftIdle || leds[lm.ButtonA2] != 0 {
+		t.Errorf("Shift layer still shown: %v", leds)
+	}
+}
+
+func TestInvalidLayers(t *testing.T) {
+	invalid := []string{
+		`{"pages": {"a": {"keyMacros": {"ButtonZ9": {"key": "1"}}}}}`,
+		`{"pages": {"a": {"leds": {"ButtonA1": "ColorBlue"}}}}`,
+		`{"pages": {"a": {"keyMacros": {"ButtonA1": {}}}}}`,
+		`{"pages": {"a": {}}, "pageButtons": {"ButtonA": "b"}}`,
+		`{"pages": {"a": {}}, "pageButtons": {"ButtonZ": "a"}}`,
+		`{"pages": {"a": {}}, "pageButtons": {"ButtonA": "a"}, "shiftButtons": {"ButtonA": "a"}}`,
+		`{"pages": {"a": {}}, "pageButtons": {"ButtonA": "a"}, "keyMacros": {"ButtonA": {"key": "a"}}}`,
+		`{"pages": {"a": {}}, "startPage": "b"}`,
+	}
+
+	for i, configuration := range invalid {
+		if _, err := testLayers(t, configuration); err == nil {
+			t.Errorf("Invalid configuration %d accepted", i)
+		}
+	}
+
+	// Without pages only the global macros are used
+	layers, err := testLayers(t, `{"keyMacros": {"ButtonA1": {"key": "1"}}}`)
+	if err != nil || macroKey(layers.Macro(lm.ButtonA1)) != "1" {
+		t.Errorf("Configuration without pages not accepted: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+const pipelineConfiguration = `{
+	"keyMacros": {
+		"ButtonA1": {"key": "c", "modifiers": ["ctrl"]},
+		"ButtonA2": {"steps": [{"type": "type", "text": "Hello"}, {"type": "tap", "key": "enter"}]},
+		"ButtonB1": {"toggle": true, "steps": [{"type": "down", "key": "shift"}], "offSteps": [{"type": "up", "key": "shift"}]},
+		"ButtonB2": {"steps": [{"type": "drag", "x": 10, "y": 20}, {"type": "click", "button": "right"}]},
+		"ButtonB3": {"steps": [{"type": "wait", "ms": 200}, {"type": "tap", "key": "x"}]}
+	},
+	"pages": {
+		"main": {"keyMacros": {}},
+		"nav": {"keyMacros": {"ButtonA1": {"key": "pageup"}}}
+	},
+	"startPage": "main",
+	"shiftButtons": {"ButtonH": "nav"},
+	"gestures": [
+		{"trigger": "longPress", "buttons": ["ButtonC1"], "macro": {"steps": [{"type": "scroll", "y": -3}]}},
+		{"trigger": "chord", "buttons": ["ButtonC2", "ButtonC3"], "macro": {"key": "z", "modifiers": ["cmd", "shift"]}}
+	]
+}`
+
+type fakeLights struct {
+	mutex   sync.Mutex
+	buttons map[byte]byte
+}
+
+func (l *fakeLights) Button(button, color byte) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.buttons[button] = color
+}
+
+func (l *fakeLights) RapidUpdate(buttons map[byte]byte) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.buttons = buttons
+}
+
+func TestPipeline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "midimacro")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	ioutil.WriteFile(path, []byte(pipelineConfiguration), 0644)
+
+	config := &Configuration{}
+	if err := config.Load(path); err != nil {
+		t.Fatalf("Configuration not loaded: %s", err.Error())
+	}
+	setup, err := NewSetup(config)
+	if err != nil {
+		t.Fatalf("Setup failed: %s", err.Error())
+	}
+	recorder := &Recorder{}
+	setup.backend = recorder
+
+	lp := &fakeLights{buttons: setup.LEDs()}
+	restore := make(chan byte, 100)
+	now := time.Now()
+
+	// Presses and releases of buttons like "+ButtonA1" and "-ButtonA1", or "600ms" to let time pass
+	run := func(events ...string) []string {
+		for _, event := range events {
+			if duration, err := time.ParseDuration(event); err == nil {
+				now = now.Add(duration)
+				handleTick(lp, setup, now, restore)
+				continue
+			}
+			button, ok := lm.ButtonValues[event[1:]]
+			if !ok {
+				t.Fatalf("Unknown button %s", event)
+			}
+			now = now.Add(10 * time.Millisecond)
+			handleButton(lp, setup, lm.ButtonEvent{Button: button, Pressed: event[0] == '+'}, now, restore)
+		}
+		return recorder.Actions()
+	}
+
+	tests := []struct {
+		name     string
+		events   []string
+		expected []string
+	}{
+		{"key", []string{"+ButtonA1", "-ButtonA1"}, []string{"down ctrl", "tap c", "up ctrl"}},
+		{"steps", []string{"+ButtonA2", "-ButtonA2"}, []string{"type Hello", "tap enter"}},
+		{"shift page", []string{"+ButtonH", "+ButtonA1", "-ButtonA1", "-ButtonH", "+ButtonA1"}, []string{"tap pageup", "down ctrl", "tap c", "up ctrl"}},
+		{"toggle", []string{"+ButtonB1", "-ButtonB1", "+ButtonB1"}, []string{"down shift", "up shift"}},
+		{"mouse", []string{"+ButtonB2"}, []string{"mousedown left", "move 10 20", "mouseup left", "click right"}},
+		{"short press", []string{"+ButtonC1", "100ms", "-ButtonC1", "600ms"}, nil},
+		{"long press", []string{"+ButtonC1", "600ms", "-ButtonC1"}, []string{"scroll 0 -3"}},
+		{"chord", []string{"+ButtonC2", "+ButtonC3", "-ButtonC2", "-ButtonC3"}, []string{"down cmd", "down shift", "tap z", "up shift", "up cmd"}},
+		{"no macro", []string{"+ButtonD1", "-ButtonD1"}, nil},
+	}
+	for _, test := range tests {
+		actions := run(test.events...)
+		if strings.Join(actions, ", ") != strings.Join(test.expected, ", ") {
+			t.Errorf("Wrong actions for %s: Got %v, expected %v", test.name, actions, test.expected)
+		}
+	}
+
+	if lp.buttons[lm.ButtonB1] != lm.ColorGreenLow {
+		t.Errorf("Toggle still shown as on: %d", lp.buttons[lm.ButtonB1])
+	}
+
+	// Macros that wait run in the background, so the main loop keeps handling buttons and notifying the watchdog
+	start := time.Now()
+	if actions := run("+ButtonB3"); len(actions) != 0 || time.Since(start) > 100*time.Millisecond {
+		t.Errorf("Waiting macro blocked for %s: %v", time.Since(start), actions)
+	}
+	time.Sleep(400 * time.Millisecond)
+	if actions := run(); strings.Join(actions, ", ") != "tap x" {
+		t.Errorf("Wrong actions after waiting: %v", actions)
+	}
+}
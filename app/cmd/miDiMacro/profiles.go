@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// profileInterval is the time between checks of the active window
+const profileInterval = 500 * time.Millisecond
+
+// Profile switches to a page while a matching window is active. A profile without title, class and process is the
+// default profile that is used when no other profile matches.
+type Profile struct {
+	Page    string `json:"page"`
+	Title   string `json:"title,omitempty"`   // Regular expression for the title of the window
+	Class   string `json:"class,omitempty"`   // Regular expression for the instance or class name of WM_CLASS, like "firefox"
+	Process string `json:"process,omitempty"` // Regular expression for the name of the process of the window
+
+	title   *regexp.Regexp
+	class   *regexp.Regexp
+	process *regexp.Regexp
+}
+
+// Profiles finds the page for the active window. Pages are only switched when the active window changes,
+// so pages selected with page buttons stay active until another window is focused.
+type Profiles struct {
+	profiles    []Profile
+	defaultPage string
+	window      func() (Window, error)
+
+	active  Window // Last active window
+	failure string // Last error reading the active window, which is only logged once
+}
+
+// NewProfiles creates the profiles of the configuration that check the active window of the X server
+func NewProfiles(c *Configuration) (*Profiles, error) {
+	p := &Profiles{window: x11.Active}
+
+	for i := range c.Profiles {
+		profile := c.Profiles[i]
+		if _, ok := c.Pages[profile.Page]; !ok {
+			return nil, fmt.Errorf("profile %d: unknown page %s", i+1, profile.Page)
+		}
+
+		if profile.Title == "" && profile.Class == "" && profile.Process == "" {
+			if p.defaultPage != "" {
+				return nil, fmt.Errorf("profile %d: only one default profile without title, class and process is allowed", i+1)
+			}
+			p.defaultPage = profile.Page
+			continue
+		}
+
+		var err error
+		if profile.Title != "" {
+			if profile.title, err = regexp.Compile(profile.Title); err != nil {
+				return nil, fmt.Errorf("profile %d: invalid title: %s", i+1, err.Error())
+			}
+		}
+		if profile.Class != "" {
+			if profile.class, err = regexp.Compile(profile.Class); err != nil {
+				return nil, fmt.Errorf("profile %d: invalid class: %s", i+1, err.Error())
+			}
+		}
+		if profile.Process != "" {
+			if profile.process, err = regexp.Compile(profile.Process); err != nil {
+				return nil, fmt.Errorf("profile %d: invalid process: %s", i+1, err.Error())
+			}
+		}
+		p.profiles = append(p.profiles, profile)
+	}
+
+	return p, nil
+}
+
+// Match returns the page of the first profile that matches the window, the default page if none matches
+func (p *Profiles) Match(window Window) string {
+	for _, profile := range p.profiles {
+		if profile.title != nil && !profile.title.MatchString(window.Title) {
+			continue
+		}
+		if profile.class != nil && !profile.class.MatchString(window.Instance) && !profile.class.MatchString(window.Class) {
+			continue
+		}
+		if profile.process != nil && !profile.process.MatchString(window.Process) {
+			continue
+		}
+		return profile.Page
+	}
+	return p.defaultPage
+}
+
+// Update checks the active window and returns its page if the window changed since the last update and
+// a profile matches. The page is kept while the active window can not be read.
+func (p *Profiles) Update() (string, bool) {
+	if len(p.profiles) == 0 && p.defaultPage == "" {
+		return "", false
+	}
+
+	window, err := p.window()
+	if err != nil {
+		if err.Error() != p.failure {
+			logger.Warning("reading the active window failed", "error", err)
+			p.failure = err.Error()
+		}
+		return "", false
+	}
+	p.failure = ""
+	if window == p.active {
+		return "", false
+	}
+	p.active = window
+
+	page := p.Match(window)
+	return page, page != ""
+}
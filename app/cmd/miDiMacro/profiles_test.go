@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+const profilesConfiguration = `{
+	"pages": {
+		"general": {"keyMacros": {"ButtonA1": {"key": "g"}}},
+		"code": {"keyMacros": {"ButtonA1": {"key": "c"}}},
+		"browser": {"keyMacros": {"ButtonA1": {"key": "b"}}}
+	},
+	"pageButtons": {"ButtonA": "general"},
+	"profiles": [
+		{"page": "code", "title": "\\.go - ", "class": "^[Cc]ode$"},
+		{"page": "browser", "class": "^firefox$"},
+		{"page": "browser", "process": "chrom"},
+		{"page": "general"}
+	]
+}`
+
+func testProfiles(t *testing.T, configuration string) (*Profiles, error) {
+	config, err := testConfiguration(t, configuration)
+	if err != nil {
+		return nil, err
+	}
+	return NewProfiles(config)
+}
+
+func TestProfiles(t *testing.T) {
+	profiles, err := testProfiles(t, profilesConfiguration)
+	if err != nil {
+		t.Fatalf("Valid configuration not accepted: %s", err.Error())
+	}
+
+	code := Window{Title: "main.go - gomidi - Visual Studio Code", Instance: "code", Class: "Code", Process: "code"}
+	firefox := Window{Title: "Mozilla Firefox", Instance: "Navigator", Class: "firefox", Process: "firefox"}
+	matches := []struct {
+		window Window
+		page   string
+	}{
+		{code, "code"},
+		{Window{Title: "README.md - gomidi - Visual Studio Code", Instance: "code", Class: "Code"}, "general"},
+		{Window{Title: "main.go - Mozilla Firefox", Instance: "Navigator", Class: "firefox"}, "browser"},
+		{Window{Title: "Chromium", Class: "Chromium-browser", Process: "chromium"}, "browser"},
+		{Window{Title: "main.go - Terminal", Class: "Gnome-terminal", Process: "code"}, "general"},
+		{Window{}, "general"},
+	}
+	for _, match := range matches {
+		if page := profiles.Match(match.window); page != match.page {
+			t.Errorf("Wrong page for %v: Got %s, expected %s", match.window, page, match.page)
+		}
+	}
+
+	// Pages are only switched when the active window changes
+	layers, _ := testLayers(t, profilesConfiguration)
+	active := code
+	var failure error
+	profiles.window = func() (Window, error) {
+		return active, failure
+	}
+	update := func() {
+		if page, ok := profiles.Update(); ok {
+			layers.SetPage(page)
+		}
+	}
+
+	update()
+	if macroKey(layers.Macro(lm.ButtonA1)) != "c" {
+		t.Errorf("Page of the profile not activated")
+	}
+	layers.Handle(lm.ButtonEvent{Button: lm.ButtonA, Pressed: true})
+	update()
+	if page, _ := layers.Page(); page != "general" {
+		t.Errorf("Page selected with a page button not kept: %s", page)
+	}
+	active = firefox
+	update()
+	if page, _ := layers.Page(); page != "browser" {
+		t.Errorf("Page not switched after the window changed: %s", page)
+	}
+
+	// The page is kept while the active window can not be read
+	failure = fmt.Errorf("connection closed")
+	if page, ok := profiles.Update(); ok {
+		t.Errorf("Page switched without active window: %s", page)
+	}
+	failure = nil
+	if page, ok := profiles.Update(); ok {
+		t.Errorf("Page switched after reading the same window again: %s", page)
+	}
+
+	// Without a default profile the page is kept
+	profiles, _ = testProfiles(t, `{"pages": {"code": {}}, "profiles": [{"page": "code", "class": "code"}]}`)
+	if page := profiles.Match(Window{Title: "Terminal", Class: "xterm"}); page != "" {
+		t.Errorf("Page without default profile: %s", page)
+	}
+}
+
+func TestInvalidProfiles(t *testing.T) {
+	invalid := []string{
+		`{"pages": {"a": {}}, "profiles": [{"page": "b", "class": "code"}]}`,
+		`{"pages": {"a": {}}, "profiles": [{"page": "a", "title": "("}]}`,
+		`{"pages": {"a": {}}, "profiles": [{"page": "a", "class": "[a"}]}`,
+		`{"pages": {"a": {}}, "profiles": [{"page": "a", "process": "*"}]}`,
+		`{"pages": {"a": {}}, "profiles": [{"page": "a"}, {"page": "a"}]}`,
+	}
+
+	for i, configuration := range invalid {
+		if _, err := testProfiles(t, configuration); err == nil {
+			t.Errorf("Invalid configuration %d accepted", i)
+		}
+	}
+}
+
+func TestActiveWindow(t *testing.T) {
+	instance, class := parseClass([]byte("Navigator\x00firefox\x00"))
+	if instance != "Navigator" || class != "firefox" {
+		t.Errorf("Wrong WM_CLASS: %s, %s", instance, class)
+	}
+	if instance, class := parseClass(nil); instance != "" || class != "" {
+		t.Errorf("Wrong empty WM_CLASS: %s, %s", instance, class)
+	}
+
+	// Without an X server no connection is kept and the next call tries again
+	windows := &activeWindows{display: ":4711"}
+	for i := 0; i < 2; i++ {
+		window, err := windows.Active()
+		if err == nil || !strings.Contains(err.Error(), "could not connect to the X server") || window != (Window{}) {
+			t.Errorf("Wrong result without X server: %v, %v", window, err)
+		}
+		if windows.conn != nil {
+			t.Errorf("Failed connection kept")
+		}
+	}
+}
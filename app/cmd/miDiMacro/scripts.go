@@ -0,0 +1,341 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+)
+
+func init() {
+	// Scripts are run from top to bottom on every press, so they need if statements and loops outside of functions
+	resolve.AllowGlobalReassign = true
+}
+
+// maxScriptSteps limits the computation of a single script run, so endless loops do not block miDiMacro
+const maxScriptSteps = 1000000
+
+// Scripts runs the Starlark scripts of script steps. Scripts have no access to files or the network, they can only
+// use the functions of scriptBuiltins. Variables set by scripts are kept until the configuration is reloaded.
+type Scripts struct {
+	dir      string
+	programs map[string]*starlark.Program
+
+	running sync.Mutex // Scripts run one at a time
+	mutex   sync.Mutex
+	vars    map[string]starlark.Value
+	pressed map[byte]bool
+	leds    map[byte]byte // Colors set by scripts and the control socket, which override the colors of the layers
+
+	runStep func(step Step, event Event) error // Runs the key and mouse steps of scripts
+	toggled func(id string) bool
+	show    func(button byte)          // Shows the changed light of the button
+	print   func(name, message string) // Output of print in scripts
+}
+
+// NewScripts loads the scripts of all script steps in the configuration. Relative script paths are relative to the
+// directory of the configuration file.
+func NewScripts(c *Configuration) (*Scripts, error) {
+	s := &Scripts{
+		dir:      filepath.Dir(c.path),
+		programs: make(map[string]*starlark.Program),
+		vars:     make(map[string]starlark.Value),
+		pressed:  make(map[byte]bool),
+		leds:     make(map[byte]byte),
+		runStep: func(step Step, event Event) error {
+			return step.run(event)
+		},
+		toggled: func(id string) bool { return false },
+		show:    func(button byte) {},
+		print: func(name, message string) {
+			logger.Info("script output", "script", name, "output", message)
+		},
+	}
+
+	names := map[string]bool{}
+	c.walkSteps(func(step Step) {
+		if step.Type == StepScript {
+			names[step.Script] = true
+		}
+	})
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		if err := s.load(name); err != nil {
+			return nil, fmt.Errorf("script %s: %s", name, err.Error())
+		}
+	}
+
+	return s, nil
+}
+
+// load reads and compiles a script
+func (s *Scripts) load(name string) error {
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.dir, path)
+	}
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	_, program, err := starlark.SourceProgram(name, source, func(name string) bool {
+		_, ok := scriptBuiltins[name]
+		return ok || name == "button"
+	})
+	if err != nil {
+		return err
+	}
+	s.programs[name] = program
+	return nil
+}
+
+// Run executes a script for the event. Scripts are stopped after the timeout or when they take too many steps.
+func (s *Scripts) Run(name string, event Event, timeout time.Duration) error {
+	program, ok := s.programs[name]
+	if !ok {
+		return fmt.Errorf("script %s is not loaded", name)
+	}
+
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(thread *starlark.Thread, message string) {
+			s.print(name, message)
+		},
+	}
+	thread.SetMaxExecutionSteps(maxScriptSteps)
+	thread.SetLocal("scripts", s)
+	thread.SetLocal("event", event)
+	// Cancel only stops the script between steps, waits end when cancelled is closed
+	cancelled := make(chan struct{})
+	thread.SetLocal("cancelled", cancelled)
+	timer := time.AfterFunc(timeout, func() {
+		thread.Cancel(fmt.Sprintf("timed out after %s", timeout))
+		close(cancelled)
+	})
+	defer timer.Stop()
+
+	predeclared := starlark.StringDict{"button": starlark.String(event.Button)}
+	for builtin, function := range scriptBuiltins {
+		predeclared[builtin] = starlark.NewBuiltin(builtin, function)
+	}
+
+	if _, err := program.Init(thread, predeclared); err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return fmt.Errorf("script %s failed: %s", name, evalErr.Backtrace())
+		}
+		return fmt.Errorf("script %s failed: %s", name, err.Error())
+	}
+	return nil
+}
+
+// Track keeps the state of the buttons for scripts
+func (s *Scripts) Track(event lm.ButtonEvent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pressed[event.Button] = event.Pressed
+}
+
+// SetLED sets the color of a button, which overrides the colors of the layers
+func (s *Scripts) SetLED(button, color byte) {
+	s.mutex.Lock()
+	s.leds[button] = color
+	s.mutex.Unlock()
+	s.show(button)
+}
+
+// ClearLED removes the color set for the button
+func (s *Scripts) ClearLED(button byte) {
+	s.mutex.Lock()
+	delete(s.leds, button)
+	s.mutex.Unlock()
+	s.show(button)
+}
+
+// LEDs returns the colors of buttons set by scripts
+func (s *Scripts) LEDs() map[byte]byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	leds := make(map[byte]byte, len(s.leds))
+	for button, color := range s.leds {
+		leds[button] = color
+	}
+	return leds
+}
+
+type scriptFunction func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error)
+
+// scriptBuiltins are the functions available in scripts
+var scriptBuiltins = map[string]scriptFunction{
+	"tap":     keyFunction(StepTap),  // tap(key, *modifiers)
+	"down":    keyFunction(StepDown), // down(key, *modifiers)
+	"up":      keyFunction(StepUp),   // up(key, *modifiers)
+	"write":   scriptWrite,           // write(text)
+	"wait":    scriptWait,            // wait(ms)
+	"led":     scriptLED,             // led(button, color = None), None removes the color set by scripts
+	"pressed": scriptPressed,         // pressed(button)
+	"toggled": scriptToggled,         // toggled(id), the id is the button name or "page/button" on pages
+	"get":     scriptGet,             // get(name, default = None)
+	"set":     scriptSet,             // set(name, value)
+}
+
+// scriptsOf returns the scripts and the event of the running script
+func scriptsOf(thread *starlark.Thread) (*Scripts, Event) {
+	return thread.Local("scripts").(*Scripts), thread.Local("event").(Event)
+}
+
+// runScriptStep validates and runs a step created by a script
+func runScriptStep(thread *starlark.Thread, step Step) (starlark.Value, error) {
+	if err := step.validate(); err != nil {
+		return nil, err
+	}
+	s, event := scriptsOf(thread)
+	return starlark.None, s.runStep(step, event)
+}
+
+func keyFunction(stepType string) scriptFunction {
+	return func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if len(args) == 0 || len(kwargs) > 0 {
+			return nil, fmt.Errorf("%s: needs a key and optional modifiers", fn.Name())
+		}
+		keys := make([]string, len(args))
+		for i, arg := range args {
+			key, ok := starlark.AsString(arg)
+			if !ok {
+				return nil, fmt.Errorf("%s: keys must be strings, not %s", fn.Name(), arg.Type())
+			}
+			keys[i] = key
+		}
+		return runScriptStep(thread, Step{Type: stepType, Key: keys[0], Modifiers: keys[1:]})
+	}
+}
+
+func scriptWrite(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var text string
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &text); err != nil {
+		return nil, err
+	}
+	return runScriptStep(thread, Step{Type: StepType, Text: text})
+}
+
+func scriptWait(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var ms int
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &ms); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(time.Duration(ms) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return starlark.None, nil
+	case <-thread.Local("cancelled").(chan struct{}):
+		return nil, fmt.Errorf("%s: script cancelled", fn.Name())
+	}
+}
+
+// scriptButton returns the value of a button name argument
+func scriptButton(fn *starlark.Builtin, name string) (byte, error) {
+	button, ok := lm.ButtonValues[name]
+	if !ok {
+		return 0, fmt.Errorf("%s: unknown button %s", fn.Name(), name)
+	}
+	return button, nil
+}
+
+func scriptLED(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	var colorName starlark.Value = starlark.None
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "button", &name, "color?", &colorName); err != nil {
+		return nil, err
+	}
+	button, err := scriptButton(fn, name)
+	if err != nil {
+		return nil, err
+	}
+
+	s, _ := scriptsOf(thread)
+	if colorName == starlark.None {
+		s.ClearLED(button)
+		return starlark.None, nil
+	}
+	colorString, _ := starlark.AsString(colorName)
+	color, ok := lm.ColorNames[colorString]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown color %s", fn.Name(), colorName.String())
+	}
+	s.SetLED(button, color)
+	return starlark.None, nil
+}
+
+func scriptPressed(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &name); err != nil {
+		return nil, err
+	}
+	button, err := scriptButton(fn, name)
+	if err != nil {
+		return nil, err
+	}
+
+	s, _ := scriptsOf(thread)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return starlark.Bool(s.pressed[button]), nil
+}
+
+func scriptToggled(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var id string
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &id); err != nil {
+		return nil, err
+	}
+	s, _ := scriptsOf(thread)
+	return starlark.Bool(s.toggled(id)), nil
+}
+
+func scriptGet(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	var value starlark.Value = starlark.None
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "name", &name, "default?", &value); err != nil {
+		return nil, err
+	}
+
+	s, _ := scriptsOf(thread)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if stored, ok := s.vars[name]; ok {
+		return stored, nil
+	}
+	return value, nil
+}
+
+func scriptSet(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	var value starlark.Value
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 2, &name, &value); err != nil {
+		return nil, err
+	}
+
+	// Stored values can not be changed, so they can be shared by all scripts
+	value.Freeze()
+	s, _ := scriptsOf(thread)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vars[name] = value
+	return starlark.None, nil
+}
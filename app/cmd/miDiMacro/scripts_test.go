@@ -0,0 +1,163 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+const scriptConfiguration = `{
+	"keyMacros": {
+		"ButtonA1": {"toggle": true, "steps": [{"type": "wait", "ms": 1}]},
+		"ButtonA2": {"steps": [{"type": "script", "script": "counter.star"}]},
+		"ButtonA3": {"steps": [{"type": "script", "script": "scripts/light.star"}]},
+		"ButtonA4": {"steps": [{"type": "script", "script": "loop.star"}]}
+	}
+}`
+
+var testScripts = map[string]string{
+	"counter.star": `
+count = get("count", 0) + 1
+set("count", count)
+if toggled("ButtonA1"):
+    tap("x", "ctrl", "shift")
+else:
+    write("%s %d" % (button, count))
+`,
+	"scripts/light.star": `
+if pressed("ButtonB1"):
+    led(button, "ColorRedFull")
+else:
+    led(button)
+`,
+	"loop.star": `
+def loop():
+    for i in range(100000000):
+        pass
+loop()
+`,
+}
+
+// testScriptSetup writes the configuration and scripts into a directory and returns its setup and the steps run by
+// scripts
+func testScriptSetup(t *testing.T, dir string) (*Setup, *[]Step) {
+	path := filepath.Join(dir, "config.json")
+	ioutil.WriteFile(path, []byte(scriptConfiguration), 0644)
+	for name, script := range testScripts {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0644)
+	}
+
+	config := &Configuration{}
+	if err := config.Load(path); err != nil {
+		t.Fatalf("Configuration not loaded: %s", err.Error())
+	}
+	setup, err := NewSetup(config)
+	if err != nil {
+		t.Fatalf("Setup failed: %s", err.Error())
+	}
+
+	steps := []Step{}
+	setup.scripts.runStep = func(step Step, event Event) error {
+		steps = append(steps, step)
+		return nil
+	}
+	return setup, &steps
+}
+
+func TestScripts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "midimacro")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	setup, steps := testScriptSetup(t, dir)
+	run := func(button byte) error {
+		macro := setup.config.Macros[button]
+		return macro.Run(setup.Event(lm.ButtonNames[button], time.Now()))
+	}
+
+	// Variables are kept between runs and the toggle state is read
+	run(lm.ButtonA2)
+	run(lm.ButtonA2)
+	toggle := setup.layers.global[lm.ButtonA1]
+	setup.layers.toggles.Press(&toggle)
+	run(lm.ButtonA2)
+	if len(*steps) != 3 || (*steps)[0].Text != "ButtonA2 1" || (*steps)[1].Text != "ButtonA2 2" {
+		t.Fatalf("Wrong steps: %v", *steps)
+	}
+	if tap := (*steps)[2]; tap.Type != StepTap || tap.Key != "x" || strings.Join(tap.Modifiers, "+") != "ctrl+shift" {
+		t.Errorf("Wrong tap: %v", tap)
+	}
+
+	// Lights set by scripts override the configured ones until they are removed
+	shown := map[byte]bool{}
+	setup.scripts.show = func(button byte) {
+		shown[button] = true
+	}
+	setup.scripts.Track(lm.ButtonEvent{Button: lm.ButtonB1, Pressed: true})
+	run(lm.ButtonA3)
+	if setup.LEDs()[lm.ButtonA3] != lm.ColorRedFull || !shown[lm.ButtonA3] {
+		t.Errorf("Light not set: %v", setup.LEDs())
+	}
+	setup.scripts.Track(lm.ButtonEvent{Button: lm.ButtonB1, Pressed: false})
+	run(lm.ButtonA3)
+	if setup.LEDs()[lm.ButtonA3] != defaultColor {
+		t.Errorf("Light not removed: %v", setup.LEDs())
+	}
+
+	// Endless scripts are stopped
+	if err := run(lm.ButtonA4); err == nil || !strings.Contains(err.Error(), "too many steps") {
+		t.Errorf("Wrong error for endless script: %v", err)
+	}
+}
+
+func TestScriptErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "midimacro")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	setup, _ := testScriptSetup(t, dir)
+
+	invalid := map[string]string{
+		`tap("enterr")`:                  `unknown key "enterr"`,
+		`led("ButtonZ1", "ColorRedLow")`: "unknown button ButtonZ1",
+		`load("other.star", "x")`:        "load not implemented",
+		`open("/etc/passwd")`:            "undefined: open",
+	}
+	for script, message := range invalid {
+		ioutil.WriteFile(filepath.Join(dir, "counter.star"), []byte(script), 0644)
+		scripts, err := NewScripts(setup.config)
+		if err == nil {
+			err = scripts.Run("counter.star", setup.Event("ButtonA2", time.Now()), time.Second)
+		}
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Wrong error for %s: %v", script, err)
+		}
+	}
+
+	// Waits end when the script times out
+	ioutil.WriteFile(filepath.Join(dir, "counter.star"), []byte("wait(60000)"), 0644)
+	scripts, err := NewScripts(setup.config)
+	if err != nil {
+		t.Fatalf("Scripts not loaded: %s", err.Error())
+	}
+	start := time.Now()
+	err = scripts.Run("counter.star", setup.Event("ButtonA2", start), 10*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "script cancelled") || time.Since(start) > time.Second {
+		t.Errorf("Wrong error for waiting script after %s: %v", time.Since(start), err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "counter.star"), []byte(testScripts["counter.star"]), 0644)
+	os.Remove(filepath.Join(dir, "loop.star"))
+	if _, err := NewSetup(setup.config); err == nil || !strings.HasPrefix(err.Error(), "script loop.star: ") {
+		t.Errorf("Wrong error for missing script: %v", err)
+	}
+}
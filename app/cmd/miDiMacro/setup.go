@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// Setup contains everything created from a configuration. It is replaced as a whole when the configuration
+// file changes, so events are always handled by one consistent configuration.
+type Setup struct {
+	config     *Configuration
+	layers     *Layers
+	recognizer *Recognizer
+	profiles   *Profiles
+	scripts    *Scripts
+	output     io.Writer // Midi output, which is opened once and set for every setup
+	backend    Backend   // Key backend, which is opened once and set for every setup
+}
+
+// NewSetup creates the layers, gestures, profiles and scripts of a loaded configuration
+func NewSetup(c *Configuration) (*Setup, error) {
+	layers, err := NewLayers(c)
+	if err != nil {
+		return nil, err
+	}
+
+	recognizer, err := NewRecognizer(c)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := NewProfiles(c)
+	if err != nil {
+		return nil, err
+	}
+
+	scripts, err := NewScripts(c)
+	if err != nil {
+		return nil, err
+	}
+	scripts.toggled = layers.toggles.On
+
+	return &Setup{config: c, layers: layers, recognizer: recognizer, profiles: profiles, scripts: scripts}, nil
+}
+
+// Event returns the event for a macro started by the button or gesture with the given name
+func (s *Setup) Event(name string, now time.Time) Event {
+	return Event{Button: name, Time: now, scripts: s.scripts, output: s.output, backend: s.backend}
+}
+
+// LoadState restores the state of the toggle macros saved next to the configuration file
+func (s *Setup) LoadState() {
+	if err := s.layers.toggles.Load(s.config.StatePath()); err != nil {
+		logger.Error("loading toggle state failed", "path", s.config.StatePath(), "error", err)
+	}
+}
+
+// LEDs returns the colors of all buttons of the active layers and the buttons with gestures. Colors set by scripts
+// override both.
+func (s *Setup) LEDs() map[byte]byte {
+	leds := s.layers.LEDs()
+	for button, color := range s.recognizer.LEDs() {
+		leds[button] = color
+	}
+	for button, color := range s.scripts.LEDs() {
+		leds[button] = color
+	}
+	return leds
+}
+
+// watch checks the configuration file for changes and sends the setups of valid configurations.
+// Invalid configurations are reported and the current one is kept.
+func watch(path string, interval time.Duration, load func() (*Setup, error), setups chan *Setup) {
+	var modified time.Time
+	if info, err := os.Stat(path); err == nil {
+		modified = info.ModTime()
+	}
+
+	for range time.Tick(interval) {
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().Equal(modified) {
+			continue
+		}
+		modified = info.ModTime()
+
+		setup, err := load()
+		if err != nil {
+			logger.Error("configuration not reloaded, keeping the current one", "config", path, "error", err)
+			continue
+		}
+
+		setups <- setup
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// notify sends a state like "READY=1" to systemd when miDiMacro runs as a service of Type=notify. Without
+// $NOTIFY_SOCKET it does nothing.
+func notify(state string) error {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil
+	}
+	// Abstract sockets start with a null byte
+	if path[0] == '@' {
+		path = "\x00" + path[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// watchdogInterval returns how often systemd expects "WATCHDOG=1", which is half of the watchdog time of the service.
+// It is 0 if the service has no watchdog.
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// serviceTemplate is the systemd user unit of miDiMacro. It runs after the graphical session started, so the
+// environment of the session (like DISPLAY) has to be imported into systemd.
+var serviceTemplate = template.Must(template.New("service").Parse(`[Unit]
+Description=miDiMacro - macro keyboard for the Launchpad Mini
+Documentation=https://github.com/sirion/gomidi
+After=graphical-session.target
+PartOf=graphical-session.target
+
+[Service]
+Type=notify
+NotifyAccess=main
+ExecStart={{.Command}}
+ExecReload=/bin/kill -HUP $MAINPID
+WatchdogSec=30
+Restart=on-failure
+RestartSec=2
+
+[Install]
+WantedBy=graphical-session.target
+`))
+
+// serviceUnit returns the systemd user unit starting the program with the arguments in daemon mode
+func serviceUnit(program string, args []string) ([]byte, error) {
+	command := []string{program, "--daemon"}
+	for _, arg := range args {
+		if strings.ContainsAny(arg, " \"'\\") {
+			arg = strconv.Quote(arg)
+		}
+		command = append(command, strings.Replace(arg, "%", "%%", -1))
+	}
+
+	out := &bytes.Buffer{}
+	err := serviceTemplate.Execute(out, map[string]string{"Command": strings.Join(command, " ")})
+	return out.Bytes(), err
+}
+
+// servicePath returns the path of the user unit in the systemd directory of the user configuration
+func servicePath() string {
+	return filepath.Join(configurationDirectories()[0], "systemd", "user", "midimacro.service")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "midimacro")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := notify("READY=1"); err != nil {
+		t.Errorf("Error without systemd: %s", err.Error())
+	}
+
+	path := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Could not create socket: %s", err.Error())
+	}
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", path)
+	if err := notify("READY=1"); err != nil {
+		t.Fatalf("Not notified: %s", err.Error())
+	}
+	buffer := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buffer)
+	if err != nil || string(buffer[:n]) != "READY=1" {
+		t.Errorf("Wrong notification: %s, %v", buffer[:n], err)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "")
+	t.Setenv("WATCHDOG_PID", "")
+	if interval := watchdogInterval(); interval != 0 {
+		t.Errorf("Watchdog without systemd: %s", interval)
+	}
+
+	os.Setenv("WATCHDOG_USEC", "30000000")
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	if interval := watchdogInterval(); interval != 15*time.Second {
+		t.Errorf("Wrong interval: %s", interval)
+	}
+
+	// The watchdog is meant for another process
+	os.Setenv("WATCHDOG_PID", "1")
+	if interval := watchdogInterval(); interval != 0 {
+		t.Errorf("Watchdog of another process: %s", interval)
+	}
+}
+
+func TestServiceUnit(t *testing.T) {
+	unit, err := serviceUnit("/usr/local/bin/miDiMacro", []string{"--config", "/home/me/my config.yaml", "--page", "100%"})
+	if err != nil {
+		t.Fatalf("No unit: %s", err.Error())
+	}
+
+	for _, line := range []string{
+		"Type=notify",
+		`ExecStart=/usr/local/bin/miDiMacro --daemon --config "/home/me/my config.yaml" --page 100%%`,
+		"ExecReload=/bin/kill -HUP $MAINPID",
+		"WatchdogSec=30",
+		"WantedBy=graphical-session.target",
+	} {
+		if !strings.Contains(string(unit), line+"\n") {
+			t.Errorf("%s missing in unit:\n%s", line, unit)
+		}
+	}
+}
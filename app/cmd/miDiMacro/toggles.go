@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Switch is a change of the state of a toggle macro
+type Switch struct {
+	Macro Macro
+	On    bool
+}
+
+// Run executes the steps of the macro when it was switched on or its offSteps when it was switched off
+func (s *Switch) Run(event Event) error {
+	if s.On {
+		return runSteps(s.Macro.Steps, event)
+	}
+	return runSteps(s.Macro.OffSteps, event)
+}
+
+// Toggles keeps the state of all toggle macros by their id and switches radio groups
+type Toggles struct {
+	macros map[string]Macro
+	groups map[string][]string // Sorted ids of the macros of each group
+	state  map[string]bool
+}
+
+func newToggles() *Toggles {
+	return &Toggles{
+		macros: make(map[string]Macro),
+		groups: make(map[string][]string),
+		state:  make(map[string]bool),
+	}
+}
+
+// add registers a toggle macro, other macros are ignored
+func (t *Toggles) add(macro Macro) {
+	if !macro.Toggle {
+		return
+	}
+
+	t.macros[macro.id] = macro
+	if macro.Group != "" {
+		t.groups[macro.Group] = append(t.groups[macro.Group], macro.id)
+		sort.Strings(t.groups[macro.Group])
+	}
+}
+
+// On returns whether the toggle macro with the given id is switched on
+func (t *Toggles) On(id string) bool {
+	return t.state[id]
+}
+
+// Press switches the toggle macro and returns the changes in the order their steps have to be run.
+// A macro of a radio group stays on when it is pressed again, otherwise the other macros of its group
+// are switched off before it is switched on.
+func (t *Toggles) Press(macro *Macro) []Switch {
+	if macro.Group == "" {
+		on := !t.state[macro.id]
+		t.state[macro.id] = on
+		return []Switch{{Macro: *macro, On: on}}
+	}
+
+	if t.state[macro.id] {
+		return nil
+	}
+
+	switches := []Switch{}
+	for _, id := range t.groups[macro.Group] {
+		if t.state[id] {
+			t.state[id] = false
+			switches = append(switches, Switch{Macro: t.macros[id], On: false})
+		}
+	}
+	t.state[macro.id] = true
+
+	return append(switches, Switch{Macro: *macro, On: true})
+}
+
+// Load reads the state saved by Save. A missing file is not an error, unknown macros are ignored and only the first
+// macro of a radio group that is on stays on.
+func (t *Toggles) Load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	state := map[string]bool{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	for id, on := range state {
+		if _, ok := t.macros[id]; ok {
+			t.state[id] = on
+		}
+	}
+	for _, ids := range t.groups {
+		on := false
+		for _, id := range ids {
+			t.state[id] = t.state[id] && !on
+			on = on || t.state[id]
+		}
+	}
+
+	return nil
+}
+
+// Save writes the state of all toggle macros to the file
+func (t *Toggles) Save(path string) error {
+	state := make(map[string]bool, len(t.macros))
+	for id := range t.macros {
+		state[id] = t.state[id]
+	}
+
+	out, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, out, 0644)
+}
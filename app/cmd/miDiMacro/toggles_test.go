@@ -0,0 +1,146 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+const togglesConfiguration = `{
+	"keyMacros": {
+		"ButtonA1": {"toggle": true, "steps": [{"key": "m"}], "offSteps": [{"key": "u"}], "onColor": "ColorRedFull"},
+		"ButtonB1": {"group": "scene", "steps": [{"key": "1"}], "offSteps": [{"key": "0"}]},
+		"ButtonB2": {"group": "scene", "steps": [{"key": "2"}]},
+		"ButtonB3": {"group": "scene", "steps": [{"key": "3"}]}
+	},
+	"pages": {
+		"main": {},
+		"media": {"keyMacros": {"ButtonA1": {"toggle": true, "offSteps": [{"key": "p"}]}}}
+	},
+	"startPage": "main"
+}`
+
+func switchKeys(switches []Switch) []string {
+	keys := []string{}
+	for _, s := range switches {
+		steps := s.Macro.OffSteps
+		if s.On {
+			steps = s.Macro.Steps
+		}
+		key := "-"
+		if len(steps) > 0 {
+			key = steps[0].Key
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestToggles(t *testing.T) {
+	layers, err := testLayers(t, togglesConfiguration)
+	if err != nil {
+		t.Fatalf("Valid configuration not accepted: %s", err.Error())
+	}
+
+	press := func(button byte, expected ...string) {
+		t.Helper()
+		macro, _ := layers.Handle(lm.ButtonEvent{Button: button, Pressed: true})
+		if macro == nil || !macro.Toggle {
+			t.Fatalf("No toggle macro for %s", lm.ButtonNames[button])
+		}
+		keys := switchKeys(layers.toggles.Press(macro))
+		if len(keys) != len(expected) {
+			t.Fatalf("Wrong steps for %s: Got %v, expected %v", lm.ButtonNames[button], keys, expected)
+		}
+		for i := range keys {
+			if keys[i] != expected[i] {
+				t.Errorf("Wrong steps for %s: Got %v, expected %v", lm.ButtonNames[button], keys, expected)
+			}
+		}
+	}
+
+	press(lm.ButtonA1, "m")
+	if leds := layers.LEDs(); leds[lm.ButtonA1] != lm.ColorRedFull {
+		t.Errorf("Toggle that is on has the wrong color: %d", leds[lm.ButtonA1])
+	}
+	press(lm.ButtonA1, "u")
+	if leds := layers.LEDs(); leds[lm.ButtonA1] != defaultColor {
+		t.Errorf("Toggle that is off has the wrong color: %d", leds[lm.ButtonA1])
+	}
+	press(lm.ButtonA1, "m")
+
+	// Radio group: the active macro stays on, the others are switched off first
+	press(lm.ButtonB1, "1")
+	press(lm.ButtonB1)
+	press(lm.ButtonB2, "0", "2")
+	press(lm.ButtonB3, "-", "3")
+	if leds := layers.LEDs(); leds[lm.ButtonB2] != defaultColor || leds[lm.ButtonB3] != defaultOnColor {
+		t.Errorf("Wrong colors of the radio group: %v", leds)
+	}
+
+	// Toggles on pages have their own state
+	layers.page = "media"
+	press(lm.ButtonA1, "-")
+	press(lm.ButtonA1, "p")
+
+	dir, err := ioutil.TempDir("", "midimacro")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := (&Configuration{path: filepath.Join(dir, "config.json")}).StatePath()
+	if path != filepath.Join(dir, "state.json") {
+		t.Errorf("Wrong state path: %s", path)
+	}
+
+	if err := layers.toggles.Save(path); err != nil {
+		t.Fatalf("State not saved: %s", err.Error())
+	}
+
+	restored, _ := testLayers(t, togglesConfiguration)
+	if err := restored.toggles.Load(path); err != nil {
+		t.Fatalf("State not loaded: %s", err.Error())
+	}
+	expected := map[string]bool{"ButtonA1": true, "ButtonB1": false, "ButtonB2": false, "ButtonB3": true, "media/ButtonA1": false}
+	for id, on := range expected {
+		if restored.toggles.On(id) != on {
+			t.Errorf("Wrong restored state of %s: Got %t, expected %t", id, !on, on)
+		}
+	}
+
+	// Only one macro of a group stays on and unknown macros are ignored
+	ioutil.WriteFile(path, []byte(`{"ButtonB1": true, "ButtonB2": true, "ButtonC1": true}`), 0644)
+	restored, _ = testLayers(t, togglesConfiguration)
+	if err := restored.toggles.Load(path); err != nil {
+		t.Fatalf("State not loaded: %s", err.Error())
+	}
+	if !restored.toggles.On("ButtonB1") || restored.toggles.On("ButtonB2") || restored.toggles.On("ButtonC1") {
+		t.Errorf("Wrong restored state: %v", restored.toggles.state)
+	}
+
+	if err := restored.toggles.Load(filepath.Join(dir, "missing.json")); err != nil {
+		t.Errorf("Missing state file is an error: %s", err.Error())
+	}
+}
+
+func TestInvalidToggles(t *testing.T) {
+	invalid := []Macro{
+		{OffSteps: []Step{{Key: "a"}}},
+		{Toggle: true},
+		{Toggle: true, Steps: []Step{{Key: "a"}}, OnColor: "ColorBlue"},
+		{Group: "a", Steps: []Step{{Key: "a"}}, OffSteps: []Step{{Type: StepWait}}},
+	}
+	for i, macro := range invalid {
+		if err := macro.Validate(); err == nil {
+			t.Errorf("Invalid macro %d accepted", i)
+		}
+	}
+
+	macro := Macro{Group: "a", OffSteps: []Step{{Key: "a"}}}
+	if err := macro.Validate(); err != nil || !macro.Toggle {
+		t.Errorf("Macro with a group is no toggle: %v", err)
+	}
+}
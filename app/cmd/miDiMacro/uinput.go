@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// Event types and codes of the Linux input subsystem (linux/input-event-codes.h)
+const (
+	evSyn = 0x00
+	evKey = 0x01
+	evRel = 0x02
+
+	synReport = 0
+
+	relX      = 0x00
+	relY      = 0x01
+	relHWheel = 0x06
+	relWheel  = 0x08
+
+	keyLeftShift = 42
+)
+
+// uinputName is the name of the virtual device
+const uinputName = "miDiMacro"
+
+// cornerDistance moves the cursor to the top left corner of the screen before moving to an absolute position
+const cornerDistance = 1 << 15
+
+// timevalSize is the size of the time of struct input_event, which is filled by the kernel
+var timevalSize = int(unsafe.Sizeof(syscall.Timeval{}))
+
+var nativeEndian binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		nativeEndian = binary.BigEndian
+	}
+
+	// Characters of a US keyboard layout, unshifted and shifted
+	rows := []struct {
+		first             uint16
+		keys, shiftedKeys string
+	}{
+		{2, "1234567890-=", "!@#$%^&*()_+"},
+		{16, "qwertyuiop[]", "QWERTYUIOP{}"},
+		{30, "asdfghjkl;'`", "ASDFGHJKL:\"~"},
+		{43, "\\zxcvbnm,./", "|ZXCVBNM<>?"},
+	}
+	for _, row := range rows {
+		for i, c := range row.keys {
+			uinputChars[c] = uinputKey{row.first + uint16(i), false}
+		}
+		for i, c := range row.shiftedKeys {
+			uinputChars[c] = uinputKey{row.first + uint16(i), true}
+		}
+	}
+}
+
+// uinputKey is the code of a key and whether shift has to be held to get the character
+type uinputKey struct {
+	code  uint16
+	shift bool
+}
+
+var uinputChars = map[rune]uinputKey{
+	' ':  {57, false},
+	'\n': {28, false},
+	'\t': {15, false},
+}
+
+// uinputKeys are the codes of the keyNames
+var uinputKeys = map[string]uint16{
+	"backspace": 14, "delete": 111, "enter": 28, "tab": 15, "esc": 1, "escape": 1,
+	"up": 103, "down": 108, "right": 106, "left": 105,
+	"home": 102, "end": 107, "pageup": 104, "pagedown": 109,
+
+	"f1": 59, "f2": 60, "f3": 61, "f4": 62, "f5": 63, "f6": 64, "f7": 65, "f8": 66,
+	"f9": 67, "f10": 68, "f11": 87, "f12": 88, "f13": 183, "f14": 184, "f15": 185, "f16": 186,
+	"f17": 187, "f18": 188, "f19": 189, "f20": 190, "f21": 191, "f22": 192, "f23": 193, "f24": 194,
+
+	"cmd": 125, "lcmd": 125, "rcmd": 126, "command": 125,
+	"alt": 56, "lalt": 56, "ralt": 100,
+	"ctrl": 29, "lctrl": 29, "rctrl": 97, "control": 29,
+	"shift": 42, "lshift": 42, "rshift": 54, "right_shift": 54,
+	"capslock": 58, "space": 57, "print": 99, "printscreen": 99, "insert": 110, "menu": 127,
+
+	"audio_mute": 113, "audio_vol_down": 114, "audio_vol_up": 115, "audio_play": 164,
+	"audio_stop": 166, "audio_pause": 201, "audio_prev": 165, "audio_next": 163,
+	"audio_rewind": 168, "audio_forward": 208, "audio_repeat": 439, "audio_random": 410,
+
+	"num0": 82, "num1": 79, "num2": 80, "num3": 81, "num4": 75,
+	"num5": 76, "num6": 77, "num7": 71, "num8": 72, "num9": 73, "num_lock": 69,
+	"num.": 83, "num+": 78, "num-": 74, "num*": 55, "num/": 98,
+	"num_clear": 355, "num_enter": 96, "num_equal": 117,
+	"numpad_0": 82, "numpad_1": 79, "numpad_2": 80, "numpad_3": 81, "numpad_4": 75,
+	"numpad_5": 76, "numpad_6": 77, "numpad_7": 71, "numpad_8": 72, "numpad_9": 73, "numpad_lock": 69,
+
+	"lights_mon_up": 225, "lights_mon_down": 224,
+	"lights_kbd_toggle": 228, "lights_kbd_up": 230, "lights_kbd_down": 229,
+}
+
+// uinputButtons are the codes of the mouse buttons
+var uinputButtons = map[string]uint16{"left": 0x110, "right": 0x111, "center": 0x112}
+
+// inputEvent is a struct input_event without its time
+type inputEvent struct {
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// marshal returns the binary representation of the event as struct input_event
+func (e inputEvent) marshal() []byte {
+	buffer := make([]byte, timevalSize+8)
+	nativeEndian.PutUint16(buffer[timevalSize:], e.Type)
+	nativeEndian.PutUint16(buffer[timevalSize+2:], e.Code)
+	nativeEndian.PutUint32(buffer[timevalSize+4:], uint32(e.Value))
+	return buffer
+}
+
+// uinputBackend writes key and mouse events to a virtual input device created with uinput
+type uinputBackend struct {
+	mutex  sync.Mutex
+	device io.WriteCloser
+}
+
+// emit writes the events followed by a report, so they are handled at once
+func (u *uinputBackend) emit(events ...inputEvent) error {
+	data := []byte{}
+	for _, event := range append(events, inputEvent{evSyn, synReport, 0}) {
+		data = append(data, event.marshal()...)
+	}
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if _, err := u.device.Write(data); err != nil {
+		return fmt.Errorf("writing to %s failed: %s", uinputPath, err.Error())
+	}
+	return nil
+}
+
+// key returns the code of a key name or single character and whether shift is needed for the character
+func (u *uinputBackend) key(key string) (uinputKey, error) {
+	if code, ok := uinputKeys[key]; ok {
+		return uinputKey{code, false}, nil
+	}
+	if runes := []rune(key); len(runes) == 1 {
+		if k, ok := uinputChars[runes[0]]; ok {
+			return k, nil
+		}
+	}
+	return uinputKey{}, fmt.Errorf("key \"%s\" is not on a US keyboard", key)
+}
+
+// toggle presses (value 1) or releases (value 0) a key, with shift for shifted characters
+func (u *uinputBackend) toggle(key string, value int32) error {
+	k, err := u.key(key)
+	if err != nil {
+		return err
+	}
+	if k.shift && value == 1 {
+		if err := u.emit(inputEvent{evKey, keyLeftShift, 1}); err != nil {
+			return err
+		}
+	}
+	if err := u.emit(inputEvent{evKey, k.code, value}); err != nil {
+		return err
+	}
+	if k.shift && value == 0 {
+		return u.emit(inputEvent{evKey, keyLeftShift, 0})
+	}
+	return nil
+}
+
+func (u *uinputBackend) KeyTap(key string) error {
+	if err := u.KeyDown(key); err != nil {
+		return err
+	}
+	return u.KeyUp(key)
+}
+
+func (u *uinputBackend) KeyDown(key string) error {
+	return u.toggle(key, 1)
+}
+
+func (u *uinputBackend) KeyUp(key string) error {
+	return u.toggle(key, 0)
+}
+
+func (u *uinputBackend) Type(text string) error {
+	for _, c := range text {
+		if _, ok := uinputChars[c]; !ok {
+			return fmt.Errorf("character '%c' is not on a US keyboard", c)
+		}
+	}
+	for _, c := range text {
+		if err := u.KeyTap(string(c)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// button presses (value 1) or releases (value 0) a mouse button
+func (u *uinputBackend) button(button string, value int32) error {
+	code, ok := uinputButtons[button]
+	if !ok {
+		return fmt.Errorf("unknown mouse button \"%s\"", button)
+	}
+	return u.emit(inputEvent{evKey, code, value})
+}
+
+func (u *uinputBackend) Click(button string, double bool) error {
+	clicks := 1
+	if double {
+		clicks = 2
+	}
+	for i := 0; i < clicks; i++ {
+		if err := u.MouseDown(button); err != nil {
+			return err
+		}
+		if err := u.MouseUp(button); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *uinputBackend) MouseDown(button string) error {
+	return u.button(button, 1)
+}
+
+func (u *uinputBackend) MouseUp(button string) error {
+	return u.button(button, 0)
+}
+
+// Move moves by the offset. The position of the cursor is not known, so absolute positions are reached by moving
+// to the top left corner first, which needs a flat pointer acceleration profile to be exact.
+func (u *uinputBackend) Move(x, y int, relative bool) error {
+	if !relative {
+		if err := u.emit(inputEvent{evRel, relX, -cornerDistance}, inputEvent{evRel, relY, -cornerDistance}); err != nil {
+			return err
+		}
+	}
+	return u.emit(inputEvent{evRel, relX, int32(x)}, inputEvent{evRel, relY, int32(y)})
+}
+
+func (u *uinputBackend) Scroll(x, y int) error {
+	return u.emit(inputEvent{evRel, relHWheel, int32(x)}, inputEvent{evRel, relWheel, int32(y)})
+}
+
+func (u *uinputBackend) Close() error {
+	return u.device.Close()
+}
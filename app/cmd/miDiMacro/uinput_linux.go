@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// ioctl request numbers of uinput: direction << 30 | size << 16 | 'U' << 8 | number
+const (
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+	uiSetRelBit  = 0x40045566
+)
+
+// Size of struct uinput_user_dev, which is written to set up the device
+const (
+	uinputNameSize = 80
+	uinputDevSize  = uinputNameSize + 8 + 4 + 4*64*4
+	busVirtual     = 0x06
+)
+
+// uinputSettle is the time the desktop needs to find a new device, events before are lost
+const uinputSettle = 200 * time.Millisecond
+
+// uinputDevice is a virtual device that is removed when it is closed
+type uinputDevice struct {
+	*os.File
+}
+
+func (d uinputDevice) ioctl(request, value uintptr) error {
+	conn, err := d.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, request, value)
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (d uinputDevice) Close() error {
+	d.ioctl(uiDevDestroy, 0)
+	return d.File.Close()
+}
+
+// openUinput creates a virtual keyboard and mouse with all keys of uinputKeys and uinputChars
+func openUinput(path string) (Backend, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s, it needs write access: %s", path, err.Error())
+	}
+	device := uinputDevice{file}
+
+	codes := map[uint16]bool{keyLeftShift: true}
+	for _, code := range uinputKeys {
+		codes[code] = true
+	}
+	for _, k := range uinputChars {
+		codes[k.code] = true
+	}
+	for _, code := range uinputButtons {
+		codes[code] = true
+	}
+
+	bits := [][2]uintptr{{uiSetEvBit, evKey}, {uiSetEvBit, evRel}}
+	for code := range codes {
+		bits = append(bits, [2]uintptr{uiSetKeyBit, uintptr(code)})
+	}
+	for _, code := range []uintptr{relX, relY, relHWheel, relWheel} {
+		bits = append(bits, [2]uintptr{uiSetRelBit, code})
+	}
+	for _, bit := range bits {
+		if err := device.ioctl(bit[0], bit[1]); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("could not set up %s: %s", path, err.Error())
+		}
+	}
+
+	setup := make([]byte, uinputDevSize)
+	copy(setup, uinputName)
+	nativeEndian.PutUint16(setup[uinputNameSize:], busVirtual)
+	if _, err := file.Write(setup); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("could not set up %s: %s", path, err.Error())
+	}
+	if err := device.ioctl(uiDevCreate, 0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("could not create device with %s: %s", path, err.Error())
+	}
+
+	time.Sleep(uinputSettle)
+	return &uinputBackend{device: device}, nil
+}
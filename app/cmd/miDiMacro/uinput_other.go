@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import "errors"
+
+func openUinput(path string) (Backend, error) {
+	return nil, errors.New("the uinput backend is only supported on Linux")
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+type fakeDevice struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (d *fakeDevice) Close() error {
+	d.closed = true
+	return nil
+}
+
+// events decodes the input_event structs written to the device, reports are left out
+func (d *fakeDevice) events(t *testing.T) []inputEvent {
+	t.Helper()
+	size := timevalSize + 8
+	data := d.Next(d.Len())
+	if len(data)%size != 0 {
+		t.Fatalf("Wrong size %d of written events", len(data))
+	}
+
+	events := []inputEvent{}
+	for i := 0; i < len(data); i += size {
+		if !bytes.Equal(data[i:i+timevalSize], make([]byte, timevalSize)) {
+			t.Errorf("Time of event %d set", i/size)
+		}
+		event := inputEvent{
+			Type:  nativeEndian.Uint16(data[i+timevalSize:]),
+			Code:  nativeEndian.Uint16(data[i+timevalSize+2:]),
+			Value: int32(nativeEndian.Uint32(data[i+timevalSize+4:])),
+		}
+		if event != (inputEvent{evSyn, synReport, 0}) {
+			events = append(events, event)
+		} else if i == 0 {
+			t.Errorf("Report without events")
+		}
+	}
+	if len(data) > 0 && nativeEndian.Uint16(data[len(data)-size+timevalSize:]) != evSyn {
+		t.Errorf("Events not followed by a report")
+	}
+	return events
+}
+
+func compareEvents(t *testing.T, name string, got []inputEvent, expected ...inputEvent) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Errorf("Wrong events of %s: Got %v, expected %v", name, got, expected)
+		return
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Errorf("Wrong event %d of %s: Got %v, expected %v", i, name, got[i], expected[i])
+		}
+	}
+}
+
+func TestUinput(t *testing.T) {
+	device := &fakeDevice{}
+	u := &uinputBackend{device: device}
+
+	u.KeyTap("a")
+	compareEvents(t, "a", device.events(t), inputEvent{evKey, 30, 1}, inputEvent{evKey, 30, 0})
+
+	u.KeyDown("ctrl")
+	u.KeyUp("ctrl")
+	compareEvents(t, "ctrl", device.events(t), inputEvent{evKey, 29, 1}, inputEvent{evKey, 29, 0})
+
+	u.Type("A?\n")
+	compareEvents(t, "text", device.events(t),
+		inputEvent{evKey, keyLeftShift, 1}, inputEvent{evKey, 30, 1}, inputEvent{evKey, 30, 0}, inputEvent{evKey, keyLeftShift, 0},
+		inputEvent{evKey, keyLeftShift, 1}, inputEvent{evKey, 53, 1}, inputEvent{evKey, 53, 0}, inputEvent{evKey, keyLeftShift, 0},
+		inputEvent{evKey, 28, 1}, inputEvent{evKey, 28, 0},
+	)
+
+	if err := u.Type("aé"); err == nil {
+		t.Errorf("Unknown character typed")
+	}
+	if err := u.KeyTap("hyper"); err == nil {
+		t.Errorf("Unknown key pressed")
+	}
+	compareEvents(t, "unknown keys", device.events(t))
+
+	u.Click("right", true)
+	compareEvents(t, "click", device.events(t),
+		inputEvent{evKey, 0x111, 1}, inputEvent{evKey, 0x111, 0}, inputEvent{evKey, 0x111, 1}, inputEvent{evKey, 0x111, 0},
+	)
+
+	u.Move(10, -5, true)
+	u.Move(100, 200, false)
+	compareEvents(t, "move", device.events(t),
+		inputEvent{evRel, relX, 10}, inputEvent{evRel, relY, -5},
+		inputEvent{evRel, relX, -cornerDistance}, inputEvent{evRel, relY, -cornerDistance},
+		inputEvent{evRel, relX, 100}, inputEvent{evRel, relY, 200},
+	)
+
+	u.Scroll(0, -3)
+	compareEvents(t, "scroll", device.events(t), inputEvent{evRel, relHWheel, 0}, inputEvent{evRel, relWheel, -3})
+
+	u.Close()
+	if !device.closed {
+		t.Errorf("Device not closed")
+	}
+}
+
+func TestUinputKeys(t *testing.T) {
+	for name := range keyNames {
+		if _, ok := uinputKeys[name]; !ok {
+			t.Errorf("Key %s missing", name)
+		}
+	}
+	for c := ' '; c <= '~'; c++ {
+		if _, ok := uinputChars[c]; !ok {
+			t.Errorf("Character '%c' missing", c)
+		}
+	}
+}
+
+func TestBackendSteps(t *testing.T) {
+	device := &fakeDevice{}
+	event := Event{backend: &uinputBackend{device: device}}
+
+	steps := []Step{
+		{Type: StepTap, Key: "t", Modifiers: []string{"ctrl", "shift"}},
+		{Type: StepDrag, X: 5, Y: 0, Relative: true},
+	}
+	if err := validateSteps(steps); err != nil {
+		t.Fatalf("Steps not valid: %s", err.Error())
+	}
+	if err := runSteps(steps, event); err != nil {
+		t.Fatalf("Steps failed: %s", err.Error())
+	}
+	compareEvents(t, "steps", device.events(t),
+		inputEvent{evKey, 29, 1}, inputEvent{evKey, 42, 1}, inputEvent{evKey, 20, 1},
+		inputEvent{evKey, 20, 0}, inputEvent{evKey, 42, 0}, inputEvent{evKey, 29, 0},
+		inputEvent{evKey, 0x110, 1}, inputEvent{evRel, relX, 5}, inputEvent{evRel, relY, 0}, inputEvent{evKey, 0x110, 0},
+	)
+
+	if _, err := openBackend("wayland"); err == nil {
+		t.Errorf("Unknown backend opened")
+	}
+}
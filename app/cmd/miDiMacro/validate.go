@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+// ConfigError is an error in the configuration file at a line, which is 0 if it is not known
+type ConfigError struct {
+	Line    int
+	Column  int // Only known for syntax errors of TOML files
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	switch {
+	case e.Line == 0:
+		return e.Message
+	case e.Column == 0:
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// ConfigErrors are all errors found in a configuration file
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// lineAt returns the line of the byte offset in data
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// lineOf returns the line of the last of the quoted tokens, which are searched one after another, so
+// lineOf(data, "pages", "media", "ButtonA1") finds the macro of ButtonA1 on the page media. It returns 0
+// if a token is not found.
+func lineOf(data []byte, tokens ...string) int {
+	offset := 0
+	for i, token := range tokens {
+		index := bytes.Index(data[offset:], []byte(`"`+token+`"`))
+		if index < 0 {
+			return 0
+		}
+		offset += index
+		if i < len(tokens)-1 {
+			offset += len(token) + 2
+		}
+	}
+	return lineAt(data, int64(offset))
+}
+
+// nth returns the tokens followed by the token n times to find the n-th element of a list by one of its keys
+func nth(n int, token string, tokens ...string) []string {
+	for i := 0; i < n; i++ {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// parseConfiguration reads the configuration from data. Syntax errors and values of the wrong type are reported
+// with their line.
+func parseConfiguration(data []byte, c *Configuration) error {
+	err := json.Unmarshal(data, c)
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *json.SyntaxError:
+		return ConfigErrors{{Line: lineAt(data, e.Offset), Message: e.Error()}}
+	case *json.UnmarshalTypeError:
+		return ConfigErrors{{Line: lineAt(data, e.Offset), Message: fmt.Sprintf("%s needs a %s, not %s", e.Field, e.Type, e.Value)}}
+	default:
+		return err
+	}
+}
+
+// duplicateKeys finds keys that are used more than once in the same object, of which encoding/json silently
+// uses the last one
+func duplicateKeys(data []byte) ConfigErrors {
+	type object struct {
+		keys      map[string]bool
+		expectKey bool
+	}
+
+	errs := ConfigErrors{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	stack := []*object{} // nil for arrays
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return errs
+		}
+
+		var parent *object
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+		}
+
+		if delim, ok := token.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		if parent != nil {
+			if parent.expectKey {
+				key := token.(string)
+				if parent.keys[key] {
+					errs = append(errs, ConfigError{Line: lineAt(data, decoder.InputOffset()), Message: fmt.Sprintf("duplicate key \"%s\"", key)})
+				}
+				parent.keys[key] = true
+				parent.expectKey = false
+				continue
+			}
+			parent.expectKey = true
+		}
+
+		switch token {
+		case json.Delim('{'):
+			stack = append(stack, &object{keys: make(map[string]bool), expectKey: true})
+		case json.Delim('['):
+			stack = append(stack, nil)
+		}
+	}
+}
+
+// sortedNames returns the keys of a map of button names in ascending order
+func sortedNames(names map[string]Macro) []string {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// check validates the configuration read from data. It finds all duplicate keys, unknown buttons and invalid
+// macros and reports them with their line. Other errors are reported by NewSetup.
+func (c *Configuration) check(data []byte) error {
+	errs := duplicateKeys(data)
+	add := func(message string, tokens ...string) {
+		errs = append(errs, ConfigError{Line: lineOf(data, tokens...), Message: message})
+	}
+	button := func(name string, tokens ...string) {
+		if _, ok := lm.ButtonValues[name]; !ok {
+			add(fmt.Sprintf("unknown button %s", name), tokens...)
+		}
+	}
+
+	c.Macros = make(map[byte]Macro, len(c.KeyMacros))
+	for _, name := range sortedNames(c.KeyMacros) {
+		macro := c.KeyMacros[name]
+		if _, ok := lm.ButtonValues[name]; !ok {
+			add(fmt.Sprintf("unknown button %s", name), "keyMacros", name)
+		} else if err := macro.Validate(); err != nil {
+			add(fmt.Sprintf("macro %s: %s", name, err.Error()), "keyMacros", name)
+		} else {
+			c.Macros[lm.ButtonValues[name]] = macro
+		}
+	}
+
+	pages := make([]string, 0, len(c.Pages))
+	for name := range c.Pages {
+		pages = append(pages, name)
+	}
+	sort.Strings(pages)
+	for _, page := range pages {
+		for _, name := range sortedNames(c.Pages[page].KeyMacros) {
+			macro := c.Pages[page].KeyMacros[name]
+			button(name, "pages", page, name)
+			if err := macro.Validate(); err != nil {
+				add(fmt.Sprintf("page %s: macro %s: %s", page, name, err.Error()), "pages", page, name)
+			}
+		}
+		for name := range c.Pages[page].LEDs {
+			button(name, "pages", page, "leds", name)
+		}
+	}
+
+	for name := range c.PageButtons {
+		button(name, "pageButtons", name)
+	}
+	for name := range c.ShiftButtons {
+		button(name, "shiftButtons", name)
+	}
+
+	for i := range c.Gestures {
+		for _, name := range c.Gestures[i].Buttons {
+			button(name, append(nth(i+1, "trigger", "gestures"), name)...)
+		}
+		macro := c.Gestures[i].Macro
+		if err := macro.Validate(); err != nil {
+			add(fmt.Sprintf("gesture %d: %s", i+1, err.Error()), nth(i+1, "trigger", "gestures")...)
+		}
+	}
+
+	for i := range c.Controls {
+		if err := c.Controls[i].Validate(); err != nil {
+			add(fmt.Sprintf("control %d: %s", i+1, err.Error()), nth(i+1, "action", "controls")...)
+		}
+	}
+	if len(c.Controls) > 0 && c.ControlDevice == "" {
+		add("controls need a controlDevice", "controls")
+	}
+
+	if !validBackend(c.Backend) {
+		add(fmt.Sprintf("unknown backend \"%s\"", c.Backend), "backend")
+	}
+
+	if c.MidiOutput == "" {
+		// Reported at the first midi step in the file
+		found, line := false, 0
+		c.walkSteps(func(step Step) {
+			if l := lineOf(data, step.Type); isMidiStep(step.Type) && (!found || l < line) {
+				found, line = true, l
+			}
+		})
+		if found {
+			errs = append(errs, ConfigError{Line: line, Message: "midi steps need a midiOutput"})
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool {
+			return errs[i].Line < errs[j].Line
+		})
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+// testConfiguration parses and checks a JSON configuration like Load, which converts and validates the macros
+func testConfiguration(t *testing.T, configuration string) (*Configuration, error) {
+	t.Helper()
+	config := &Configuration{}
+	if err := parseConfiguration([]byte(configuration), config); err != nil {
+		t.Fatalf("Configuration not loaded: %s", err.Error())
+	}
+	return config, config.check([]byte(configuration))
+}
+
+func TestExampleConfiguration(t *testing.T) {
+	config := Configuration{}
+	if err := config.Load("config-example/config.json"); err != nil {
+		t.Fatalf("Example configuration not valid:\n%s", err.Error())
+	}
+	if _, err := NewSetup(&config); err != nil {
+		t.Fatalf("Example configuration not valid: %s", err.Error())
+	}
+}
+
+const invalidConfiguration = `{
+	"keyMacros": {
+		"ButtonA1": {"key": "a"},
+		"ButtonZ1": {"key": "b"},
+		"ButtonA2": {"key": "entr"},
+		"ButtonA1": {"key": "c"}
+	},
+	"pages": {
+		"main": {
+			"keyMacros": {"ButtonA1": {"key": "1", "modifiers": ["strg"]}},
+			"leds": {"ButtonQ1": "ColorRedLow"}
+		}
+	},
+	"gestures": [
+		{"trigger": "tap", "buttons": ["ButtonB1"], "macro": {"key": "x"}},
+		{"trigger": "chord", "buttons": ["ButtonB2", "ButtonX3"], "macro": {"key": "y"}}
+	]
+}`
+
+func TestValidation(t *testing.T) {
+	config := Configuration{}
+	if err := parseConfiguration([]byte(invalidConfiguration), &config); err != nil {
+		t.Fatalf("Configuration not loaded: %s", err.Error())
+	}
+	err := config.check([]byte(invalidConfiguration))
+	errs, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatalf("Wrong errors: %v", err)
+	}
+
+	expected := []string{
+		`line 4: unknown button ButtonZ1`,
+		`line 5: macro ButtonA2: step 1: unknown key "entr"`,
+		`line 6: duplicate key "ButtonA1"`,
+		`line 10: page main: macro ButtonA1: step 1: unknown key "strg"`,
+		`line 11: unknown button ButtonQ1`,
+		`line 16: unknown button ButtonX3`,
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Wrong errors:\n%s", errs.Error())
+	}
+	for i := range expected {
+		if errs[i].Error() != expected[i] {
+			t.Errorf("Wrong error %d: Got \"%s\", expected \"%s\"", i, errs[i].Error(), expected[i])
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "midimacro")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+
+	invalid := map[string]string{
+		"{\n\"keyMacros\": {\n\"ButtonA1\": {\"key\": \"a\"},\n}\n}":    "line 4: ",
+		"{\n\"keyMacros\": {\n\"ButtonA1\": {\"key\": 1}\n}\n}":         "line 3: keyMacros.ButtonA1.key needs a string",
+		"{\n\"controls\": [{\"controller\": 1, \"action\": \"move\"}]}": "line 2: control 1: unknown action",
+		"{\n\"keyMacros\": {},\n\"backend\": \"wayland\"\n}":            "line 3: unknown backend \"wayland\"",
+	}
+	for configuration, message := range invalid {
+		ioutil.WriteFile(path, []byte(configuration), 0644)
+		config := Configuration{}
+		if err := config.Load(path); err == nil || !strings.HasPrefix(err.Error(), message) {
+			t.Errorf("Wrong error for %s: Got \"%v\", expected \"%s...\"", configuration, err, message)
+		}
+	}
+
+	config := Configuration{}
+	if err := config.Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("Missing configuration file loaded")
+	}
+
+	// Saved configurations are loaded again
+	ioutil.WriteFile(path, []byte(`{"keyMacros": {"ButtonA1": {"key": "a", "modifiers": ["ctrl"]}}}`), 0644)
+	if err := config.Load(path); err != nil {
+		t.Fatalf("Valid configuration not loaded: %s", err.Error())
+	}
+	config.Macros[lm.ButtonB1] = Macro{Steps: []Step{{Type: StepType, Text: "saved"}}}
+	if err := config.Save(); err != nil {
+		t.Fatalf("Configuration not saved: %s", err.Error())
+	}
+	saved := Configuration{}
+	if err := saved.Load(path); err != nil || len(saved.Macros) != 2 || saved.Device != "auto" {
+		t.Errorf("Saved configuration not loaded: %v (%d macros)", err, len(saved.Macros))
+	}
+
+	os.Chmod(path, 0400)
+	if err := config.Save(); err == nil && os.Getuid() != 0 {
+		t.Errorf("Error writing the configuration file not returned")
+	}
+}
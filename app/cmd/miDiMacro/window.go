@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// Window describes the active window that profiles are matched against
+type Window struct {
+	Title    string
+	Instance string // First part of WM_CLASS, like "Navigator"
+	Class    string // Second part of WM_CLASS, like "firefox"
+	Process  string // Name of the process from _NET_WM_PID, like "firefox"
+}
+
+// activeWindows reads the active window from the X server. All profiles share one connection, which is opened on
+// first use and opened again after it failed.
+type activeWindows struct {
+	mutex   sync.Mutex
+	display string // Name of the display, DISPLAY if empty
+	proc    string // Mount point of the proc file system for the names of processes
+
+	conn  *xgb.Conn
+	root  xproto.Window
+	atoms map[string]xproto.Atom
+}
+
+var x11 = &activeWindows{proc: "/proc"}
+
+// Active returns the active window or an empty window if there is none
+func (w *activeWindows) Active() (Window, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.conn == nil {
+		conn, err := xgb.NewConnDisplay(w.display)
+		if err != nil {
+			return Window{}, fmt.Errorf("could not connect to the X server: %s", err.Error())
+		}
+		w.conn = conn
+		w.root = xproto.Setup(conn).DefaultScreen(conn).Root
+		w.atoms = make(map[string]xproto.Atom)
+	}
+
+	window, err := w.read()
+	if err != nil {
+		// Errors of requests, like windows closed while they are read, leave the connection usable
+		if _, ok := err.(xgb.Error); !ok {
+			w.conn.Close()
+			w.conn = nil
+		}
+		return Window{}, err
+	}
+	return window, nil
+}
+
+func (w *activeWindows) read() (Window, error) {
+	value, err := w.property(w.root, "_NET_ACTIVE_WINDOW")
+	if err != nil || len(value) < 4 || xgb.Get32(value) == 0 {
+		return Window{}, err
+	}
+	id := xproto.Window(xgb.Get32(value))
+
+	window := Window{}
+	title, err := w.property(id, "_NET_WM_NAME")
+	if err == nil && len(title) == 0 {
+		title, err = w.property(id, "WM_NAME")
+	}
+	if err != nil {
+		return Window{}, err
+	}
+	window.Title = string(title)
+
+	class, err := w.property(id, "WM_CLASS")
+	if err != nil {
+		return Window{}, err
+	}
+	window.Instance, window.Class = parseClass(class)
+
+	pid, err := w.property(id, "_NET_WM_PID")
+	if err != nil {
+		return Window{}, err
+	}
+	if len(pid) >= 4 {
+		// Windows of other hosts have no process here, so the name may be missing
+		comm, _ := ioutil.ReadFile(filepath.Join(w.proc, strconv.Itoa(int(xgb.Get32(pid))), "comm"))
+		window.Process = strings.TrimSpace(string(comm))
+	}
+
+	return window, nil
+}
+
+// property returns the value of a property of the window, nil if the window does not have it
+func (w *activeWindows) property(window xproto.Window, name string) ([]byte, error) {
+	atom, ok := w.atoms[name]
+	if !ok {
+		reply, err := xproto.InternAtom(w.conn, true, uint16(len(name)), name).Reply()
+		// Atoms that do not exist yet are looked up again
+		if err != nil || reply == nil || reply.Atom == 0 {
+			return nil, err
+		}
+		atom = reply.Atom
+		w.atoms[name] = atom
+	}
+
+	reply, err := xproto.GetProperty(w.conn, false, window, atom, xproto.GetPropertyTypeAny, 0, 1024).Reply()
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	return reply.Value, nil
+}
+
+// parseClass splits the value of WM_CLASS, two zero terminated strings, into the instance and the class name
+func parseClass(value []byte) (string, string) {
+	parts := strings.SplitN(strings.TrimRight(string(value), "\x00"), "\x00", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
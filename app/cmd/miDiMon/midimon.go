@@ -0,0 +1,58 @@
+package main
+
+/**
+ * miDiMon shows the midi messages a device sends in human readable form, for example
+ * "ch1 NoteOn C4 vel 100", together with the time since the start and since the previous message.
+ *
+ * With --json every message is printed as a JSON object on its own line for use in scripts.
+ */
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/sirion/gomidi/lib/midiport"
+)
+
+func main() {
+	port := flag.String("port", "", "Midi port to monitor, for example /dev/snd/midiC1D0, seq:20:0, seq:Launchpad or virtual:miDiMon")
+	channels := flag.String("channels", "", "Comma separated list of channels (1-16) to show")
+	types := flag.String("types", "", "Comma separated list of message types to show, for example NoteOn,NoteOff,Controller")
+	exclude := flag.String("exclude", "Clock,ActiveSensing", "Comma separated list of message types to hide")
+	jsonOutput := flag.Bool("json", false, "Print one JSON object per message")
+	absolute := flag.Bool("absolute", false, "Print the time of day instead of the time since the start")
+	help := flag.Bool("help", false, "Show this help")
+	flag.Parse()
+
+	if *help || *port == "" {
+		fmt.Fprintf(os.Stderr, "Usage: %s --port PORT [options]\n\n", os.Args[0])
+		flag.PrintDefaults()
+		if *help {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+
+	filter, err := NewFilter(*channels, *types, *exclude)
+	if err != nil {
+		log.Fatalf("Invalid filter: %s", err.Error())
+	}
+
+	rw, err := midiport.Open(*port)
+	if err != nil {
+		log.Fatalf("Could not open port %s: %s", *port, err.Error())
+	}
+	defer rw.Close()
+
+	monitor := NewMonitor(os.Stdout, time.Now)
+	monitor.Filter = filter
+	monitor.JSON = *jsonOutput
+	monitor.Absolute = *absolute
+
+	if err := monitor.Run(rw); err != nil {
+		log.Fatalf("Error reading from port %s: %s", *port, err.Error())
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirion/gomidi/lib/midi"
+)
+
+// Monitor prints the midi messages read from a port
+type Monitor struct {
+	Filter   *Filter
+	JSON     bool // Print one JSON object per line instead of text
+	Absolute bool // Print the time of day instead of the time since the start
+
+	out   io.Writer
+	now   func() time.Time
+	start time.Time
+	last  time.Time
+}
+
+// Filter decides which messages are shown. Empty filters let all messages pass.
+type Filter struct {
+	channels map[byte]bool
+	types    map[midi.MessageType]bool
+	exclude  map[midi.MessageType]bool
+}
+
+// Entry is the JSON form of a message
+type Entry struct {
+	Time     string  `json:"time"`
+	Seconds  float64 `json:"seconds"` // Time since the start of the monitor
+	Delta    float64 `json:"delta"`   // Time since the previous shown message
+	Type     string  `json:"type"`
+	Channel  int     `json:"channel,omitempty"` // From 1 to 16, empty for system messages
+	Note     *byte   `json:"note,omitempty"`
+	NoteName string  `json:"noteName,omitempty"`
+	Velocity *byte   `json:"velocity,omitempty"`
+	Pressure *byte   `json:"pressure,omitempty"`
+	Control  *byte   `json:"controller,omitempty"`
+	Value    *int    `json:"value,omitempty"`
+	Program  *byte   `json:"program,omitempty"`
+	Text     string  `json:"text"`
+	Raw      string  `json:"raw"`
+}
+
+// NewMonitor creates a monitor that writes to the given writer
+func NewMonitor(out io.Writer, now func() time.Time) *Monitor {
+	start := now()
+	return &Monitor{
+		Filter: &Filter{},
+		out:    out,
+		now:    now,
+		start:  start,
+		last:   start,
+	}
+}
+
+// Run reads from the port and prints all messages that pass the filter until the port returns an error
+func (m *Monitor) Run(r io.Reader) error {
+	parser := midi.Parser{}
+	buffer := make([]byte, 1024)
+
+	for {
+		read, err := r.Read(buffer)
+		if read > 0 {
+			received := m.now()
+			for _, message := range parser.Parse(buffer[:read]) {
+				if err := m.Print(received, message); err != nil {
+					return err
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Print writes a single message if it passes the filter
+func (m *Monitor) Print(received time.Time, message []byte) error {
+	if !m.Filter.Match(message) {
+		return nil
+	}
+
+	seconds := received.Sub(m.start).Seconds()
+	delta := received.Sub(m.last).Seconds()
+	m.last = received
+
+	timestamp := fmt.Sprintf("%12.6f", seconds)
+	if m.Absolute {
+		timestamp = received.Format("15:04:05.000000")
+	}
+
+	if !m.JSON {
+		_, err := fmt.Fprintf(m.out, "%s %+10.6f  %s\n", timestamp, delta, midi.Describe(message))
+		return err
+	}
+
+	entry := newEntry(message)
+	entry.Time = received.Format(time.RFC3339Nano)
+	entry.Seconds = seconds
+	entry.Delta = delta
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(m.out, "%s\n", data)
+	return err
+}
+
+// newEntry decodes the message values
+func newEntry(message []byte) *Entry {
+	messageType := midi.Type(message)
+	entry := &Entry{
+		Type: messageType.String(),
+		Text: midi.Describe(message),
+		Raw:  fmt.Sprintf("%x", message),
+	}
+
+	if channel, ok := midi.Channel(message); ok {
+		entry.Channel = int(channel) + 1
+	}
+
+	if len(message) < 2 || (messageType != midi.TypeSysEx && len(message) < expectedLength(messageType)) {
+		return entry
+	}
+
+	switch messageType {
+	case midi.TypeNoteOn, midi.TypeNoteOff:
+		entry.Note, entry.Velocity = &message[1], &message[2]
+		entry.NoteName = midi.NoteName(message[1])
+	case midi.TypePolyPressure:
+		entry.Note, entry.Pressure = &message[1], &message[2]
+		entry.NoteName = midi.NoteName(message[1])
+	case midi.TypeController:
+		value := int(message[2])
+		entry.Control, entry.Value = &message[1], &value
+	case midi.TypeProgramChange:
+		entry.Program = &message[1]
+	case midi.TypeChannelPressure:
+		entry.Pressure = &message[1]
+	case midi.TypePitchBend:
+		value := midi.PitchBendValue(message)
+		entry.Value = &value
+	case midi.TypeSongPosition:
+		value := int(message[1]) | int(message[2])<<7
+		entry.Value = &value
+	case midi.TypeSongSelect:
+		value := int(message[1])
+		entry.Value = &value
+	}
+
+	return entry
+}
+
+func expectedLength(messageType midi.MessageType) int {
+	switch messageType {
+	case midi.TypeProgramChange, midi.TypeChannelPressure, midi.TypeSongSelect, midi.TypeTimeCode:
+		return 2
+	case midi.TypeNoteOn, midi.TypeNoteOff, midi.TypePolyPressure, midi.TypeController, midi.TypePitchBend, midi.TypeSongPosition:
+		return 3
+	}
+	return 1
+}
+
+// NewFilter creates a filter from comma separated lists of channels (1 to 16), message types to show
+// and message types to hide
+func NewFilter(channels, types, exclude string) (*Filter, error) {
+	f := &Filter{}
+
+	for _, value := range splitList(channels) {
+		channel, err := strconv.Atoi(value)
+		if err != nil || channel < 1 || channel > 16 {
+			return nil, fmt.Errorf("invalid channel \"%s\", must be between 1 and 16", value)
+		}
+		if f.channels == nil {
+			f.channels = make(map[byte]bool)
+		}
+		f.channels[byte(channel-1)] = true
+	}
+
+	var err error
+	if f.types, err = parseTypes(types); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = parseTypes(exclude); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Match returns whether the message passes the filter. System messages pass the channel filter.
+func (f *Filter) Match(message []byte) bool {
+	messageType := midi.Type(message)
+	if f.exclude[messageType] {
+		return false
+	}
+	if f.types != nil && !f.types[messageType] {
+		return false
+	}
+	if channel, ok := midi.Channel(message); ok && f.channels != nil && !f.channels[channel] {
+		return false
+	}
+	return true
+}
+
+func parseTypes(list string) (map[midi.MessageType]bool, error) {
+	var types map[midi.MessageType]bool
+	for _, name := range splitList(list) {
+		messageType, ok := midi.ParseMessageType(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown message type \"%s\"", name)
+		}
+		if types == nil {
+			types = make(map[midi.MessageType]bool)
+		}
+		types[messageType] = true
+	}
+	return types, nil
+}
+
+func splitList(list string) []string {
+	values := []string{}
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
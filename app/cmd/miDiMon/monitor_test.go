@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirion/gomidi/lib/midi"
+)
+
+// testClock returns a clock that advances by the given step on every call
+func testClock(step time.Duration) func() time.Time {
+	current := time.Date(2019, 2, 1, 12, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		now := current
+		current = current.Add(step)
+		return now
+	}
+}
+
+func TestMonitorText(t *testing.T) {
+	out := &bytes.Buffer{}
+	monitor := NewMonitor(out, testClock(250*time.Millisecond))
+
+	input := [][]byte{midi.NoteOn(0, 60, 100), {0xf8}, midi.NoteOff(0, 60, 0)}
+	for _, message := range input {
+		monitor.Run(bytes.NewReader(message))
+	}
+
+	expected := "    0.250000  +0.250000  ch1 NoteOn C4 vel 100\n" +
+		"    0.500000  +0.250000  Clock\n" +
+		"    0.750000  +0.250000  ch1 NoteOff C4 vel 0\n"
+	if out.String() != expected {
+		t.Errorf("Wrong output:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+}
+
+func TestMonitorJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	monitor := NewMonitor(out, testClock(time.Second))
+	monitor.JSON = true
+	monitor.Filter, _ = NewFilter("2", "", "")
+
+	data := append(midi.Controller(1, 7, 90), midi.NoteOn(0, 60, 100)...)
+	data = append(data, 0xe1, 0x00, 0x60)
+	monitor.Run(bytes.NewReader(data))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Wrong number of lines: Got %d, expected 2\n%s", len(lines), out.String())
+	}
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Invalid JSON: %s", err.Error())
+	}
+	expected := map[string]interface{}{"type": "Controller", "channel": 2.0, "controller": 7.0, "value": 90.0, "raw": "b1075a", "seconds": 1.0, "delta": 1.0}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("Wrong value for %s: Got %v, expected %v", key, entry[key], value)
+		}
+	}
+
+	entry = map[string]interface{}{}
+	json.Unmarshal([]byte(lines[1]), &entry)
+	if entry["type"] != "PitchBend" || entry["value"] != 4096.0 {
+		t.Errorf("Wrong pitch bend entry: %s", lines[1])
+	}
+}
+
+func TestFilter(t *testing.T) {
+	filter, err := NewFilter("1, 10", "NoteOn,Clock", "")
+	if err != nil {
+		t.Fatalf("Valid filter not accepted: %s", err.Error())
+	}
+
+	if !filter.Match(midi.NoteOn(9, 36, 1)) || !filter.Match([]byte{0xf8}) {
+		t.Errorf("Filter rejected matching messages")
+	}
+	if filter.Match(midi.NoteOn(1, 36, 1)) || filter.Match(midi.NoteOff(0, 36, 0)) {
+		t.Errorf("Filter accepted other messages")
+	}
+
+	filter, _ = NewFilter("", "", "clock")
+	if filter.Match([]byte{0xf8}) || !filter.Match([]byte{0xfa}) {
+		t.Errorf("Excluded types not filtered")
+	}
+
+	for _, invalid := range [][]string{{"0", "", ""}, {"", "Note", ""}, {"", "", "x"}} {
+		if _, err := NewFilter(invalid[0], invalid[1], invalid[2]); err == nil {
+			t.Errorf("Invalid filter %v accepted", invalid)
+		}
+	}
+}
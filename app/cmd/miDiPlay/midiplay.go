@@ -0,0 +1,80 @@
+package main
+
+/**
+ * miDiPlay plays Standard MIDI Files through a midi port.
+ *
+ * Usage: miDiPlay --port seq:Synth song.mid [more.mid...]
+ *
+ * Interrupting the program (Ctrl+C) stops the playback and all playing notes.
+ */
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirion/gomidi/lib/midiport"
+	"github.com/sirion/gomidi/lib/smf"
+)
+
+func main() {
+	port := flag.String("port", "", "Midi port to play to, for example /dev/snd/midiC1D0, seq:20:0, seq:Synth or virtual:miDiPlay")
+	loop := flag.Bool("loop", false, "Repeat the files until interrupted")
+	help := flag.Bool("help", false, "Show this help")
+	flag.Parse()
+
+	if *help || *port == "" || flag.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s --port PORT [options] FILE...\n\n", os.Args[0])
+		flag.PrintDefaults()
+		if *help {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+
+	files := make([]*smf.File, flag.NArg())
+	for i, path := range flag.Args() {
+		f, err := smf.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Could not read midi file %s: %s", path, err.Error())
+		}
+		files[i] = f
+	}
+
+	out, err := midiport.Open(*port)
+	if err != nil {
+		log.Fatalf("Could not open port %s: %s", *port, err.Error())
+	}
+	defer out.Close()
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	player := smf.NewPlayer(out)
+	for {
+		for i, f := range files {
+			fmt.Printf("Playing %s\n", flag.Arg(i))
+			if err := player.Play(f, stop); err != nil {
+				log.Fatalf("Error playing %s: %s", flag.Arg(i), err.Error())
+			}
+
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+
+		if !*loop {
+			return
+		}
+	}
+}
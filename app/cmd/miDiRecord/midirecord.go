@@ -0,0 +1,73 @@
+package main
+
+/**
+ * miDiRecord records the midi messages of a port into a Standard MIDI File.
+ *
+ * Usage: miDiRecord --port seq:Keyboard recording.mid
+ *
+ * The recording starts with the first message and is written when the program is interrupted (Ctrl+C).
+ */
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirion/gomidi/lib/midiport"
+	"github.com/sirion/gomidi/lib/smf"
+)
+
+func main() {
+	port := flag.String("port", "", "Midi port to record from, for example /dev/snd/midiC1D0, seq:20:0, seq:Keyboard or virtual:miDiRecord")
+	bpm := flag.Uint("bpm", 120, "Tempo of the file in beats per minute")
+	division := flag.Uint("division", 480, "Ticks per quarter note")
+	help := flag.Bool("help", false, "Show this help")
+	flag.Parse()
+
+	if *help || *port == "" || flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s --port PORT [options] FILE\n\n", os.Args[0])
+		flag.PrintDefaults()
+		if *help {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+	if *bpm == 0 || *division == 0 || *division > 0x7fff {
+		log.Fatalf("Invalid tempo or division")
+	}
+
+	in, err := midiport.Open(*port)
+	if err != nil {
+		log.Fatalf("Could not open port %s: %s", *port, err.Error())
+	}
+	defer in.Close()
+
+	recorder := smf.NewRecorder()
+	recorder.Division = uint16(*division)
+	recorder.Tempo = uint32(60000000 / *bpm)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- recorder.Record(in)
+	}()
+
+	fmt.Printf("Recording from %s, press Ctrl+C to stop\n", *port)
+	select {
+	case <-signals:
+	case err := <-done:
+		if err != nil {
+			fmt.Printf("Error reading from port %s: %s\n", *port, err.Error())
+		}
+	}
+
+	if err := recorder.File().WriteFile(flag.Arg(0)); err != nil {
+		log.Fatalf("Could not write midi file %s: %s", flag.Arg(0), err.Error())
+	}
+	fmt.Printf("Recording written to %s\n", flag.Arg(0))
+}
@@ -0,0 +1,143 @@
+package main
+
+/**
+ * miDiRoute forwards midi messages between ports, for example from a controller keyboard to
+ * a synthesizer. Routes can filter messages by channel, type and key range and transform them by
+ * changing the channel, transposing notes, changing velocities and remapping controllers.
+ *
+ * The configuration file is watched and changes are applied without restarting.
+ */
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/sirion/gomidi/lib/midiport"
+)
+
+// Configuration contains the named ports and the routes between them
+type Configuration struct {
+	// Ports maps port names used in the routes to midi ports (see package midiport for valid values)
+	Ports  map[string]string `json:"ports"`
+	Routes []Route           `json:"routes"`
+}
+
+// Route forwards messages from one port to one or more other ports. Empty filters let all messages pass.
+type Route struct {
+	From string   `json:"from"`
+	To   []string `json:"to"`
+
+	// Filters
+	Channels []int     `json:"channels,omitempty"` // Input channels from 1 to 16
+	Types    []string  `json:"types,omitempty"`    // Message types like "NoteOn" or "Controller"
+	KeyRange *KeyRange `json:"keyRange,omitempty"`
+
+	// Transformations
+	Channel     int             `json:"channel,omitempty"` // Output channel from 1 to 16
+	Transpose   int             `json:"transpose,omitempty"`
+	Velocity    *VelocityCurve  `json:"velocity,omitempty"`
+	Controllers map[string]byte `json:"controllers,omitempty"` // Input controller number to output controller number
+}
+
+// KeyRange limits a route to the notes between Low and High (including both), for example to split a keyboard
+type KeyRange struct {
+	Low  string `json:"low,omitempty"`  // Lowest note like "C2" or "36"
+	High string `json:"high,omitempty"` // Highest note like "B3" or "59"
+}
+
+// VelocityCurve changes the velocity of note on messages
+type VelocityCurve struct {
+	Curve string  `json:"curve"`           // linear, soft, hard, exponential or fixed
+	Gamma float64 `json:"gamma,omitempty"` // Exponent of the exponential curve, values below 1 make low velocities louder
+	Min   byte    `json:"min,omitempty"`   // Lowest output velocity
+	Max   byte    `json:"max,omitempty"`   // Highest output velocity
+	Value byte    `json:"value,omitempty"` // Output velocity of the fixed curve
+}
+
+func getUserDir() string {
+	user, err := user.Current()
+	if err != nil {
+		log.Fatalf("Could not find user name: %s", err.Error())
+	}
+
+	return user.HomeDir
+}
+
+// Load reads the configuration from the given file
+func (c *Configuration) Load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Error loading configuration file: %s", err.Error())
+	}
+
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return fmt.Errorf("Error parsing configuration file: %s", err.Error())
+	}
+
+	return nil
+}
+
+func main() {
+	configurationPath := flag.String("config", "", "Override configuration file path")
+	interval := flag.Duration("interval", time.Second, "Interval to check the configuration file for changes")
+	flag.Parse()
+
+	if *configurationPath == "" {
+		*configurationPath = filepath.Join(getUserDir(), ".config", "midiroute", "config.json")
+	}
+
+	config := &Configuration{}
+	if err := config.Load(*configurationPath); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	router := NewRouter(midiport.Open)
+	if err := router.Apply(config); err != nil {
+		log.Fatalf("Error in configuration: %s", err.Error())
+	}
+
+	go func() {
+		for err := range midiport.Errors() {
+			fmt.Printf("Error receiving midi: %s\n", err.Error())
+		}
+	}()
+
+	watch(*configurationPath, *interval, router)
+}
+
+// watch checks the configuration file for changes and applies them to the router
+func watch(path string, interval time.Duration, router *Router) {
+	var modified time.Time
+	if info, err := os.Stat(path); err == nil {
+		modified = info.ModTime()
+	}
+
+	for range time.Tick(interval) {
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().Equal(modified) {
+			continue
+		}
+		modified = info.ModTime()
+
+		config := &Configuration{}
+		if err := config.Load(path); err != nil {
+			fmt.Printf("%s, keeping the current configuration\n", err.Error())
+			continue
+		}
+
+		if err := router.Apply(config); err != nil {
+			fmt.Printf("Error in configuration: %s, keeping the current configuration\n", err.Error())
+			continue
+		}
+
+		fmt.Printf("Configuration reloaded\n")
+	}
+}
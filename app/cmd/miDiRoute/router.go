@@ -0,0 +1,388 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/sirion/gomidi/lib/midi"
+)
+
+// Router forwards midi messages between ports according to the configured routes.
+// The configuration can be replaced at any time using Apply.
+type Router struct {
+	open func(device string) (io.ReadWriteCloser, error)
+
+	mutex  sync.Mutex
+	routes []*route
+	ports  map[string]*port
+	notes  map[sentNote]bool
+}
+
+type port struct {
+	device string
+	rw     io.ReadWriteCloser
+}
+
+// sentNote is a note that has been started on an output and not stopped yet
+type sentNote struct {
+	port    string
+	channel byte
+	note    byte
+}
+
+// route is the compiled form of a Route
+type route struct {
+	from string
+	to   []string
+
+	channels    map[byte]bool
+	types       map[midi.MessageType]bool
+	low, high   byte
+	channel     int
+	transpose   int
+	velocity    func(byte) byte
+	controllers map[byte]byte
+}
+
+// NewRouter creates a router that uses the given function to open the configured ports
+func NewRouter(open func(device string) (io.ReadWriteCloser, error)) *Router {
+	return &Router{
+		open:  open,
+		ports: make(map[string]*port),
+		notes: make(map[sentNote]bool),
+	}
+}
+
+// Apply replaces the routes with the ones from the given configuration. Ports that are new or whose
+// device changed are opened, ports that are no longer configured are closed. Notes that are still
+// playing are stopped, since the new routes might not forward their note off messages.
+// If the configuration is invalid, an error is returned and the current routes stay active.
+func (r *Router) Apply(config *Configuration) error {
+	routes := make([]*route, 0, len(config.Routes))
+	for i, rt := range config.Routes {
+		compiled, err := compileRoute(rt, config.Ports)
+		if err != nil {
+			return fmt.Errorf("route %d: %s", i+1, err.Error())
+		}
+		routes = append(routes, compiled)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.stopNotes()
+
+	opened := make(map[string]*port, len(config.Ports))
+	for name, device := range config.Ports {
+		if p, ok := r.ports[name]; ok && p.device == device {
+			opened[name] = p
+			continue
+		}
+
+		rw, err := r.open(device)
+		if err != nil {
+			for newName, p := range opened {
+				if r.ports[newName] != p {
+					p.rw.Close()
+				}
+			}
+			return fmt.Errorf("could not open port \"%s\" (%s): %s", name, device, err.Error())
+		}
+		opened[name] = &port{device, rw}
+	}
+
+	for name, p := range r.ports {
+		if opened[name] != p {
+			p.rw.Close()
+		}
+	}
+	for name, p := range opened {
+		if r.ports[name] != p {
+			go r.listen(name, p)
+		}
+	}
+
+	r.ports = opened
+	r.routes = routes
+	return nil
+}
+
+// Close stops all playing notes and closes all ports
+func (r *Router) Close() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.stopNotes()
+	for _, p := range r.ports {
+		p.rw.Close()
+	}
+	r.ports = make(map[string]*port)
+	r.routes = nil
+}
+
+// Route forwards a complete midi message received on the given port to all matching routes
+func (r *Router) Route(from string, message []byte) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, rt := range r.routes {
+		if rt.from != from {
+			continue
+		}
+
+		out, ok := rt.transform(message)
+		if !ok {
+			continue
+		}
+
+		for _, to := range rt.to {
+			r.send(to, out)
+		}
+	}
+}
+
+// listen reads messages from the port until it is closed
+func (r *Router) listen(name string, p *port) {
+	parser := midi.Parser{}
+	buffer := make([]byte, 1024)
+
+	for {
+		read, err := p.rw.Read(buffer)
+		if err != nil {
+			r.mutex.Lock()
+			current := r.ports[name] == p
+			r.mutex.Unlock()
+
+			if current && err != io.EOF {
+				fmt.Printf("Reading error from port \"%s\": %s\n", name, err.Error())
+			}
+			return
+		}
+
+		for _, message := range parser.Parse(buffer[:read]) {
+			r.Route(name, message)
+		}
+	}
+}
+
+// send writes the message to the given port and keeps track of the playing notes. The mutex must be locked.
+func (r *Router) send(to string, message []byte) {
+	p, ok := r.ports[to]
+	if !ok {
+		return
+	}
+
+	if _, err := p.rw.Write(message); err != nil {
+		fmt.Printf("Writing error to port \"%s\": %s\n", to, err.Error())
+		return
+	}
+
+	switch midi.Type(message) {
+	case midi.TypeNoteOn:
+		note := sentNote{to, message[0] & 0x0f, message[1]}
+		if message[2] > 0 {
+			r.notes[note] = true
+		} else {
+			delete(r.notes, note)
+		}
+	case midi.TypeNoteOff:
+		delete(r.notes, sentNote{to, message[0] & 0x0f, message[1]})
+	}
+}
+
+// stopNotes sends a note off message for every playing note. The mutex must be locked.
+func (r *Router) stopNotes() {
+	for note := range r.notes {
+		if p, ok := r.ports[note.port]; ok {
+			p.rw.Write(midi.NoteOff(note.channel, note.note, 0))
+		}
+	}
+	r.notes = make(map[sentNote]bool)
+}
+
+// transform applies filters and transformations of the route to a copy of the message.
+// It returns false if the message is filtered out.
+func (rt *route) transform(message []byte) ([]byte, bool) {
+	messageType := midi.Type(message)
+	if rt.types != nil && !rt.types[messageType] {
+		return nil, false
+	}
+
+	channel, isChannelMessage := midi.Channel(message)
+	if !isChannelMessage {
+		return message, true
+	}
+	if rt.channels != nil && !rt.channels[channel] {
+		return nil, false
+	}
+
+	out := make([]byte, len(message))
+	copy(out, message)
+
+	switch messageType {
+	case midi.TypeNoteOn, midi.TypeNoteOff, midi.TypePolyPressure:
+		if len(out) < 3 || out[1] < rt.low || out[1] > rt.high {
+			return nil, false
+		}
+
+		note := int(out[1]) + rt.transpose
+		if note < 0 || note > 127 {
+			return nil, false
+		}
+		out[1] = byte(note)
+
+		if messageType == midi.TypeNoteOn && out[2] > 0 && rt.velocity != nil {
+			out[2] = rt.velocity(out[2])
+		}
+
+	case midi.TypeController:
+		if len(out) < 3 {
+			return nil, false
+		}
+		if controller, ok := rt.controllers[out[1]]; ok {
+			out[1] = controller
+		}
+	}
+
+	if rt.channel > 0 {
+		out[0] = out[0]&0xf0 | byte(rt.channel-1)
+	}
+
+	return out, true
+}
+
+// compileRoute validates the route and converts it into its compiled form
+func compileRoute(rt Route, ports map[string]string) (*route, error) {
+	compiled := &route{
+		from:      rt.From,
+		to:        rt.To,
+		low:       0,
+		high:      127,
+		channel:   rt.Channel,
+		transpose: rt.Transpose,
+	}
+
+	if _, ok := ports[rt.From]; !ok {
+		return nil, fmt.Errorf("unknown input port \"%s\"", rt.From)
+	}
+	if len(rt.To) == 0 {
+		return nil, fmt.Errorf("no output ports")
+	}
+	for _, to := range rt.To {
+		if _, ok := ports[to]; !ok {
+			return nil, fmt.Errorf("unknown output port \"%s\"", to)
+		}
+	}
+
+	if rt.Channel < 0 || rt.Channel > 16 {
+		return nil, fmt.Errorf("invalid output channel %d, must be between 1 and 16", rt.Channel)
+	}
+
+	if len(rt.Channels) > 0 {
+		compiled.channels = make(map[byte]bool, len(rt.Channels))
+		for _, channel := range rt.Channels {
+			if channel < 1 || channel > 16 {
+				return nil, fmt.Errorf("invalid input channel %d, must be between 1 and 16", channel)
+			}
+			compiled.channels[byte(channel-1)] = true
+		}
+	}
+
+	if len(rt.Types) > 0 {
+		compiled.types = make(map[midi.MessageType]bool, len(rt.Types))
+		for _, name := range rt.Types {
+			messageType, ok := midi.ParseMessageType(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown message type \"%s\"", name)
+			}
+			compiled.types[messageType] = true
+		}
+	}
+
+	if rt.KeyRange != nil {
+		var ok bool
+		if rt.KeyRange.Low != "" {
+			if compiled.low, ok = midi.ParseNoteName(rt.KeyRange.Low); !ok {
+				return nil, fmt.Errorf("invalid note \"%s\"", rt.KeyRange.Low)
+			}
+		}
+		if rt.KeyRange.High != "" {
+			if compiled.high, ok = midi.ParseNoteName(rt.KeyRange.High); !ok {
+				return nil, fmt.Errorf("invalid note \"%s\"", rt.KeyRange.High)
+			}
+		}
+		if compiled.low > compiled.high {
+			return nil, fmt.Errorf("invalid key range, low key %s is above high key %s", rt.KeyRange.Low, rt.KeyRange.High)
+		}
+	}
+
+	if len(rt.Controllers) > 0 {
+		compiled.controllers = make(map[byte]byte, len(rt.Controllers))
+		for from, to := range rt.Controllers {
+			controller, err := strconv.ParseUint(from, 10, 8)
+			if err != nil || controller > 127 || to > 127 {
+				return nil, fmt.Errorf("invalid controller mapping %s -> %d", from, to)
+			}
+			compiled.controllers[byte(controller)] = byte(to)
+		}
+	}
+
+	if rt.Velocity != nil {
+		velocity, err := rt.Velocity.compile()
+		if err != nil {
+			return nil, err
+		}
+		compiled.velocity = velocity
+	}
+
+	return compiled, nil
+}
+
+// compile returns a function that maps input velocities (1 to 127) to output velocities
+func (c *VelocityCurve) compile() (func(byte) byte, error) {
+	if c.Min > 127 || c.Max > 127 {
+		return nil, fmt.Errorf("invalid velocity range %d to %d, must be between 1 and 127", c.Min, c.Max)
+	}
+	min, max := float64(c.Min), float64(c.Max)
+	if c.Min == 0 {
+		min = 1
+	}
+	if c.Max == 0 {
+		max = 127
+	}
+	if min > max {
+		return nil, fmt.Errorf("invalid velocity range, min %d is above max %d", c.Min, c.Max)
+	}
+
+	gamma := c.Gamma
+	switch c.Curve {
+	case "", "linear":
+		gamma = 1
+	case "soft":
+		// Soft keys: Low velocities become louder
+		gamma = 0.5
+	case "hard":
+		// Hard keys: Low velocities become quieter
+		gamma = 2
+	case "exponential":
+		if gamma <= 0 {
+			return nil, fmt.Errorf("velocity curve \"exponential\" needs a gamma value greater than 0")
+		}
+	case "fixed":
+		if c.Value < 1 || c.Value > 127 {
+			return nil, fmt.Errorf("velocity curve \"fixed\" needs a value between 1 and 127")
+		}
+		value := c.Value
+		return func(byte) byte { return value }, nil
+	default:
+		return nil, fmt.Errorf("unknown velocity curve \"%s\"", c.Curve)
+	}
+
+	return func(velocity byte) byte {
+		x := float64(velocity-1) / 126
+		return byte(math.Round(min + math.Pow(x, gamma)*(max-min)))
+	}, nil
+}
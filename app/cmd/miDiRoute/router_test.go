@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirion/gomidi/lib/midi"
+	"github.com/sirion/gomidi/lib/midiport"
+)
+
+// testPorts creates in-memory ports for the router. The returned map contains the ends used by the test.
+func testPorts(names ...string) (func(string) (io.ReadWriteCloser, error), map[string]io.ReadWriteCloser) {
+	routerEnds := make(map[string]io.ReadWriteCloser, len(names))
+	testEnds := make(map[string]io.ReadWriteCloser, len(names))
+
+	for _, name := range names {
+		routerEnds[name], testEnds[name] = midiport.Pipe()
+	}
+
+	open := func(device string) (io.ReadWriteCloser, error) {
+		rw, ok := routerEnds[device]
+		if !ok {
+			return nil, fmt.Errorf("unknown device %s", device)
+		}
+		delete(routerEnds, device)
+		return rw, nil
+	}
+
+	return open, testEnds
+}
+
+// collect returns a channel with all messages read from the port
+func collect(r io.Reader) chan []byte {
+	messages := make(chan []byte, 100)
+	go func() {
+		parser := midi.Parser{}
+		buffer := make([]byte, 1024)
+		for {
+			read, err := r.Read(buffer)
+			if err != nil {
+				close(messages)
+				return
+			}
+			for _, message := range parser.Parse(buffer[:read]) {
+				messages <- message
+			}
+		}
+	}()
+	return messages
+}
+
+func expect(t *testing.T, messages chan []byte, want ...[]byte) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-messages:
+			if !bytes.Equal(got, w) {
+				t.Errorf("Wrong message: Got %x, expected %x", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout waiting for message %x", w)
+		}
+	}
+}
+
+func TestRouter(t *testing.T) {
+	open, ports := testPorts("in", "low", "high")
+	low := collect(ports["low"])
+	high := collect(ports["high"])
+
+	router := NewRouter(open)
+	defer router.Close()
+
+	err := router.Apply(&Configuration{
+		Ports: map[string]string{"keys": "in", "bass": "low", "piano": "high"},
+		Routes: []Route{
+			{
+				From:      "keys",
+				To:        []string{"bass"},
+				Types:     []string{"noteOn", "noteOff"},
+				KeyRange:  &KeyRange{High: "B2"},
+				Channel:   3,
+				Transpose: -12,
+				Velocity:  &VelocityCurve{Curve: "fixed", Value: 100},
+			},
+			{
+				From:        "keys",
+				To:          []string{"piano"},
+				Channels:    []int{1},
+				KeyRange:    &KeyRange{Low: "C3"},
+				Velocity:    &VelocityCurve{Curve: "linear", Min: 64},
+				Controllers: map[string]byte{"1": 11},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Valid configuration not applied: %s", err.Error())
+	}
+
+	in := ports["in"]
+	in.Write(midi.NoteOn(0, 36, 10))
+	in.Write(midi.NoteOn(0, 60, 1))
+	in.Write(midi.NoteOn(1, 61, 127)) // Channel 2 is filtered
+	in.Write(midi.Controller(0, 1, 50))
+	in.Write(midi.NoteOff(0, 36, 0))
+	in.Write(midi.NoteOn(0, 127, 127))
+
+	expect(t, low, midi.NoteOn(2, 24, 100), midi.NoteOff(2, 24, 0))
+	expect(t, high, midi.NoteOn(0, 60, 64), midi.Controller(0, 11, 50), midi.NoteOn(0, 127, 127))
+
+	// Reloading stops playing notes and applies the new routes
+	err = router.Apply(&Configuration{
+		Ports:  map[string]string{"keys": "in", "piano": "high"},
+		Routes: []Route{{From: "keys", To: []string{"piano"}, Transpose: 1}},
+	})
+	if err != nil {
+		t.Fatalf("Valid configuration not applied: %s", err.Error())
+	}
+
+	stopped := [][]byte{<-high, <-high}
+	if !(bytes.Equal(stopped[0], midi.NoteOff(0, 60, 0)) || bytes.Equal(stopped[1], midi.NoteOff(0, 60, 0))) ||
+		!(bytes.Equal(stopped[0], midi.NoteOff(0, 127, 0)) || bytes.Equal(stopped[1], midi.NoteOff(0, 127, 0))) {
+		t.Errorf("Playing notes not stopped: Got %x", stopped)
+	}
+
+	// The removed port has been closed
+	if _, ok := <-low; ok {
+		t.Errorf("Removed port is still open")
+	}
+
+	in.Write(midi.NoteOn(5, 60, 1))
+	expect(t, high, midi.NoteOn(5, 61, 1))
+}
+
+func TestInvalidRoutes(t *testing.T) {
+	ports := map[string]string{"a": "a", "b": "b"}
+	routes := []Route{
+		{From: "x", To: []string{"b"}},
+		{From: "a", To: []string{"x"}},
+		{From: "a"},
+		{From: "a", To: []string{"b"}, Channel: 17},
+		{From: "a", To: []string{"b"}, Channels: []int{0}},
+		{From: "a", To: []string{"b"}, Types: []string{"NoteOnOff"}},
+		{From: "a", To: []string{"b"}, KeyRange: &KeyRange{Low: "X1"}},
+		{From: "a", To: []string{"b"}, KeyRange: &KeyRange{Low: "C5", High: "C4"}},
+		{From: "a", To: []string{"b"}, Controllers: map[string]byte{"128": 1}},
+		{From: "a", To: []string{"b"}, Velocity: &VelocityCurve{Curve: "fixed"}},
+		{From: "a", To: []string{"b"}, Velocity: &VelocityCurve{Curve: "exponential"}},
+		{From: "a", To: []string{"b"}, Velocity: &VelocityCurve{Curve: "wobbly"}},
+		{From: "a", To: []string{"b"}, Velocity: &VelocityCurve{Min: 128}},
+		{From: "a", To: []string{"b"}, Velocity: &VelocityCurve{Max: 200}},
+		{From: "a", To: []string{"b"}, Velocity: &VelocityCurve{Min: 100, Max: 50}},
+	}
+
+	for i, rt := range routes {
+		if _, err := compileRoute(rt, ports); err == nil {
+			t.Errorf("Invalid route %d was accepted", i)
+		}
+	}
+}
+
+func TestVelocityCurve(t *testing.T) {
+	soft, _ := (&VelocityCurve{Curve: "soft"}).compile()
+	hard, _ := (&VelocityCurve{Curve: "hard"}).compile()
+	linear, _ := (&VelocityCurve{}).compile()
+
+	for _, velocity := range []byte{1, 127} {
+		if soft(velocity) != velocity || hard(velocity) != velocity || linear(velocity) != velocity {
+			t.Errorf("Curves must keep the velocity %d", velocity)
+		}
+	}
+	if !(soft(64) > linear(64) && linear(64) > hard(64)) {
+		t.Errorf("Wrong curves: soft %d, linear %d, hard %d", soft(64), linear(64), hard(64))
+	}
+}
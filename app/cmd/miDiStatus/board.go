@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+// Metrics of meters
+const (
+	MetricCPU     = "cpu"     // Usage of all cores or one core
+	MetricMemory  = "memory"  // Used memory without caches
+	MetricDisk    = "disk"    // Used space of a file system
+	MetricLoad    = "load"    // Load average of the last minute
+	MetricNetDown = "netDown" // Bytes received per second
+	MetricNetUp   = "netUp"   // Bytes sent per second
+)
+
+// Defaults of meters and checks
+const (
+	defaultNetworkMax    = 12500000 // 100 Mbit/s
+	defaultCheckInterval = 10 * time.Second
+)
+
+// Meter shows a metric as a bar in a column of the grid
+type Meter struct {
+	Metric    string  `json:"metric"`
+	Core      int     `json:"core,omitempty"`      // Core of cpu meters from 1, all cores if 0
+	Path      string  `json:"path,omitempty"`      // File system of disk meters, / by default
+	Interface string  `json:"interface,omitempty"` // Network interface of netDown and netUp meters
+	Max       float64 `json:"max,omitempty"`       // Value of a full bar for load (number of cores by default) and network meters (bytes per second, 100 Mbit/s by default)
+}
+
+// Check shows the exit status of a command that runs every interval on a button: green if it succeeds, red if it
+// fails and amber until it ran once
+type Check struct {
+	Button   string   `json:"button"` // Button name like "ButtonA" or "LiveButton1"
+	Command  string   `json:"command"`
+	Args     []string `json:"args,omitempty"`
+	Shell    bool     `json:"shell,omitempty"`    // Run the command with "sh -c" instead of directly
+	Interval int      `json:"interval,omitempty"` // Milliseconds between runs, which is also the timeout (default 10s)
+}
+
+func (c *Check) interval() time.Duration {
+	if c.Interval > 0 {
+		return time.Duration(c.Interval) * time.Millisecond
+	}
+	return defaultCheckInterval
+}
+
+// run executes the command and returns whether it succeeded
+func (c *Check) run() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), c.interval())
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if c.Shell {
+		cmd = exec.CommandContext(ctx, "sh", "-c", c.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, c.Command, c.Args...)
+	}
+	return cmd.Run() == nil
+}
+
+// Board turns metrics and the results of checks into the colors of the launchpad buttons
+type Board struct {
+	proc   Proc
+	meters []Meter
+	checks map[byte]*Check
+	cores  int
+	statfs func(path string) (float64, error)
+
+	// Samples of the previous update for metrics that change by time
+	previous    time.Time
+	previousCPU []cpuTimes
+	previousNet map[string][2]uint64
+
+	mutex   sync.Mutex
+	results map[byte]byte // Colors of the checks
+}
+
+// NewBoard validates the meters and checks of the configuration and creates their board
+func NewBoard(c *Configuration, proc Proc) (*Board, error) {
+	cpus, err := proc.CPUs()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Board{
+		proc:        proc,
+		meters:      c.Meters,
+		checks:      make(map[byte]*Check),
+		cores:       len(cpus) - 1,
+		statfs:      diskUsage,
+		previousNet: make(map[string][2]uint64),
+		results:     make(map[byte]byte),
+	}
+	if len(b.meters) == 0 {
+		b.meters = defaultMeters(b.cores)
+	}
+	// Every meter is shown in a column of the grid
+	if len(b.meters) > lm.GridSize {
+		return nil, fmt.Errorf("%d meters, at most %d fit on the grid", len(b.meters), lm.GridSize)
+	}
+
+	for i := range b.meters {
+		if err := b.meters[i].validate(b.cores); err != nil {
+			return nil, fmt.Errorf("meter %d: %s", i+1, err.Error())
+		}
+	}
+
+	for i := range c.Checks {
+		check := &c.Checks[i]
+		button, ok := lm.ButtonValues[check.Button]
+		if !ok {
+			return nil, fmt.Errorf("check %d: unknown button \"%s\"", i+1, check.Button)
+		}
+		if check.Command == "" {
+			return nil, fmt.Errorf("check %d: no command", i+1)
+		}
+		if check.Shell && len(check.Args) > 0 {
+			return nil, fmt.Errorf("check %d: shell takes the arguments in the command", i+1)
+		}
+		b.checks[button] = check
+		b.results[button] = lm.ColorAmberLow
+	}
+
+	return b, nil
+}
+
+// defaultMeters shows up to five cores, memory, the root file system and the load
+func defaultMeters(cores int) []Meter {
+	meters := []Meter{}
+	for core := 1; core <= cores && core <= 5; core++ {
+		meters = append(meters, Meter{Metric: MetricCPU, Core: core})
+	}
+	return append(meters, Meter{Metric: MetricMemory}, Meter{Metric: MetricDisk}, Meter{Metric: MetricLoad})
+}
+
+// validate checks the meter and sets its defaults
+func (m *Meter) validate(cores int) error {
+	switch m.Metric {
+	case MetricCPU:
+		if m.Core < 0 || m.Core > cores {
+			return fmt.Errorf("invalid core %d, must be between 1 and %d or 0 for all cores", m.Core, cores)
+		}
+	case MetricMemory:
+	case MetricDisk:
+		if m.Path == "" {
+			m.Path = "/"
+		}
+	case MetricLoad:
+		if m.Max == 0 && cores > 0 {
+			m.Max = float64(cores)
+		} else if m.Max == 0 {
+			m.Max = 1
+		}
+	case MetricNetDown, MetricNetUp:
+		if m.Interface == "" {
+			return fmt.Errorf("%s needs an interface", m.Metric)
+		}
+		if m.Max == 0 {
+			m.Max = defaultNetworkMax
+		}
+	default:
+		return fmt.Errorf("unknown metric \"%s\"", m.Metric)
+	}
+
+	if m.Max < 0 {
+		return fmt.Errorf("invalid max %g", m.Max)
+	}
+	return nil
+}
+
+// diskUsage returns the part of the file system at path that is used, from 0 to 1
+func diskUsage(path string) (float64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	// Space reserved for root counts as used, like df does
+	used := stat.Blocks - stat.Bfree
+	if used+stat.Bavail == 0 {
+		return 0, nil
+	}
+	return float64(used) / float64(used+stat.Bavail), nil
+}
+
+// Start runs the commands of all checks every interval in the background
+func (b *Board) Start() {
+	for button := range b.checks {
+		go func(button byte) {
+			for {
+				b.runCheck(button)
+				time.Sleep(b.checks[button].interval())
+			}
+		}(button)
+	}
+}
+
+// runCheck runs the command of the check on the button and keeps its result
+func (b *Board) runCheck(button byte) {
+	color := lm.ColorRedFull
+	if b.checks[button].run() {
+		color = lm.ColorGreenFull
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.results[button] = color
+}
+
+// values reads the metrics of all meters as parts of a full bar. The first update has no values for cpu and
+// network meters. Meters that can not be read are empty and reported in the error.
+func (b *Board) values(now time.Time) ([]float64, error) {
+	values := make([]float64, len(b.meters))
+	errs := []string{}
+	fail := func(i int, err error) {
+		errs = append(errs, fmt.Sprintf("meter %d (%s): %s", i+1, b.meters[i].Metric, err.Error()))
+	}
+
+	var cpus []cpuTimes
+	seconds := now.Sub(b.previous).Seconds()
+	network := make(map[string][2]uint64)
+
+	for i, meter := range b.meters {
+		switch meter.Metric {
+		case MetricCPU:
+			if cpus == nil {
+				var err error
+				if cpus, err = b.proc.CPUs(); err != nil {
+					fail(i, err)
+					continue
+				}
+			}
+			if meter.Core < len(cpus) && meter.Core < len(b.previousCPU) {
+				values[i] = cpus[meter.Core].usage(b.previousCPU[meter.Core])
+			}
+
+		case MetricMemory:
+			value, err := b.proc.Memory()
+			if err != nil {
+				fail(i, err)
+			}
+			values[i] = value
+
+		case MetricDisk:
+			value, err := b.statfs(meter.Path)
+			if err != nil {
+				fail(i, err)
+			}
+			values[i] = value
+
+		case MetricLoad:
+			value, err := b.proc.Load()
+			if err != nil {
+				fail(i, err)
+			}
+			values[i] = value / meter.Max
+
+		case MetricNetDown, MetricNetUp:
+			bytes, ok := network[meter.Interface]
+			if !ok {
+				received, sent, err := b.proc.Network(meter.Interface)
+				if err != nil {
+					fail(i, err)
+					continue
+				}
+				bytes = [2]uint64{received, sent}
+				network[meter.Interface] = bytes
+			}
+
+			direction := 0
+			if meter.Metric == MetricNetUp {
+				direction = 1
+			}
+			previous, ok := b.previousNet[meter.Interface]
+			if ok && seconds > 0 && bytes[direction] >= previous[direction] {
+				values[i] = float64(bytes[direction]-previous[direction]) / seconds / meter.Max
+			}
+		}
+	}
+
+	if cpus != nil {
+		b.previousCPU = cpus
+	}
+	for name, bytes := range network {
+		b.previousNet[name] = bytes
+	}
+	b.previous = now
+
+	if len(errs) > 0 {
+		return values, fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return values, nil
+}
+
+// barColor returns the color of a cell of a bar counted from the bottom: green, amber for the upper half and red
+// for the top quarter
+func barColor(cell int) byte {
+	switch {
+	case cell >= 6:
+		return lm.ColorRedFull
+	case cell >= 4:
+		return lm.ColorAmberFull
+	}
+	return lm.ColorGreenFull
+}
+
+// Update reads the metrics and returns the colors of all buttons: the meters as bars from the bottom of their
+// column and the results of the checks. Checks on the grid cover the meters.
+func (b *Board) Update(now time.Time) (map[byte]byte, error) {
+	values, err := b.values(now)
+
+	buttons := make(map[byte]byte)
+	for column, value := range values {
+		cells := int(value*lm.GridSize + 0.5)
+		if cells > lm.GridSize {
+			cells = lm.GridSize
+		}
+		for cell := 0; cell < cells; cell++ {
+			buttons[lm.GridButton(byte(lm.GridSize-1-cell), byte(column))] = barColor(cell)
+		}
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for button, color := range b.results {
+		buttons[button] = color
+	}
+	return buttons, err
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+// copyProc copies the fixture proc files into a directory where they can be changed
+func copyProc(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "midistatus")
+	if err != nil {
+		t.Fatalf("Could not create directory: %s", err.Error())
+	}
+	os.Mkdir(filepath.Join(dir, "net"), 0755)
+	for _, name := range []string{"stat", "meminfo", "loadavg", "net/dev"} {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", "proc", name))
+		if err != nil {
+			t.Fatalf("Could not read fixture: %s", err.Error())
+		}
+		ioutil.WriteFile(filepath.Join(dir, name), data, 0644)
+	}
+	return dir
+}
+
+// column returns the colors of a grid column from top to bottom like "....AGGG"
+func column(buttons map[byte]byte, c int) string {
+	names := map[byte]string{lm.ColorGreenFull: "G", lm.ColorAmberFull: "A", lm.ColorRedFull: "R", lm.ColorAmberLow: "a"}
+	text := ""
+	for row := byte(0); row < lm.GridSize; row++ {
+		if name, ok := names[buttons[lm.GridButton(row, byte(c))]]; ok {
+			text += name
+		} else {
+			text += "."
+		}
+	}
+	return text
+}
+
+func TestBoard(t *testing.T) {
+	dir := copyProc(t)
+	defer os.RemoveAll(dir)
+
+	config := &Configuration{Meters: []Meter{
+		{Metric: MetricCPU, Core: 1},
+		{Metric: MetricCPU},
+		{Metric: MetricMemory},
+		{Metric: MetricDisk, Path: "/data"},
+		{Metric: MetricLoad},
+		{Metric: MetricNetDown, Interface: "eth0", Max: 1000},
+		{Metric: MetricNetUp, Interface: "eth0", Max: 1000},
+	}}
+	board, err := NewBoard(config, Proc{Root: dir})
+	if err != nil {
+		t.Fatalf("Board not created: %s", err.Error())
+	}
+	board.statfs = func(path string) (float64, error) {
+		if path != "/data" {
+			t.Errorf("Wrong disk %s", path)
+		}
+		return 0.9, nil
+	}
+
+	// The first update has no cpu and network values
+	now := time.Now()
+	buttons, err := board.Update(now)
+	if err != nil {
+		t.Fatalf("Update failed: %s", err.Error())
+	}
+	expected := []string{"........", "........", "......GG", ".RAAGGGG", "......GG", "........", "........", "........"}
+	for c, bar := range expected {
+		if got := column(buttons, c); got != bar {
+			t.Errorf("Wrong column %d: Got %s, expected %s", c, got, bar)
+		}
+	}
+
+	// cpu0 is busy 3/4 of the time, all cpus half of the time
+	stat, _ := ioutil.ReadFile(filepath.Join(dir, "stat"))
+	lines := strings.Split(string(stat), "\n")
+	lines[0] = "cpu  4905 356 584 3699376 23060 0 277 0 0 0"
+	lines[1] = "cpu0 1468 280 236 923717 5858 0 70 0 0 0"
+	ioutil.WriteFile(filepath.Join(dir, "stat"), []byte(strings.Join(lines, "\n")), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "loadavg"), []byte("9.00 0.85 0.60 2/812 6043\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "net", "dev"), []byte(fmt.Sprintf("Inter-| Receive\n face |bytes\n  eth0: %d 0 0 0 0 0 0 0 %d 0 0 0 0 0 0 0\n", 172938513+1000, 8413977+500)), 0644)
+
+	buttons, err = board.Update(now.Add(2 * time.Second))
+	if err != nil {
+		t.Fatalf("Update failed: %s", err.Error())
+	}
+	expected = []string{"..AAGGGG", "....GGGG", "......GG", ".RAAGGGG", "RRAAGGGG", "....GGGG", "......GG", "........"}
+	for c, bar := range expected {
+		if got := column(buttons, c); got != bar {
+			t.Errorf("Wrong column %d: Got %s, expected %s", c, got, bar)
+		}
+	}
+
+	// Meters that can not be read are empty
+	os.Remove(filepath.Join(dir, "meminfo"))
+	buttons, err = board.Update(now.Add(3 * time.Second))
+	if err == nil || !strings.Contains(err.Error(), "meter 3 (memory)") {
+		t.Errorf("Wrong error: %v", err)
+	}
+	if got := column(buttons, 2); got != "........" {
+		t.Errorf("Wrong column without memory: %s", got)
+	}
+}
+
+func TestChecks(t *testing.T) {
+	config := &Configuration{Checks: []Check{
+		{Button: "ButtonA", Command: "true"},
+		{Button: "ButtonA1", Command: "exit 3", Shell: true},
+		{Button: "LiveButton8", Command: "sleep", Args: []string{"1"}, Interval: 10},
+	}}
+	board, err := NewBoard(config, fixtures)
+	if err != nil {
+		t.Fatalf("Board not created: %s", err.Error())
+	}
+	board.statfs = func(path string) (float64, error) { return 0, nil }
+
+	buttons, _ := board.Update(time.Now())
+	if buttons[lm.ButtonA] != lm.ColorAmberLow || buttons[lm.LiveButton8] != lm.ColorAmberLow {
+		t.Errorf("Checks not shown as waiting: %v", buttons)
+	}
+
+	for button := range board.checks {
+		board.runCheck(button)
+	}
+	buttons, _ = board.Update(time.Now())
+	for button, color := range map[byte]byte{lm.ButtonA: lm.ColorGreenFull, lm.ButtonA1: lm.ColorRedFull, lm.LiveButton8: lm.ColorRedFull} {
+		if buttons[button] != color {
+			t.Errorf("Wrong color of %s: Got %d, expected %d", lm.ButtonNames[button], buttons[button], color)
+		}
+	}
+}
+
+func TestInvalidBoard(t *testing.T) {
+	invalid := map[string]Configuration{
+		"unknown metric": {Meters: []Meter{{Metric: "swap"}}},
+		"core":           {Meters: []Meter{{Metric: MetricCPU, Core: 5}}},
+		"interface":      {Meters: []Meter{{Metric: MetricNetUp}}},
+		"too many":       {Meters: make([]Meter, 9)},
+		"button":         {Checks: []Check{{Button: "ButtonZ", Command: "true"}}},
+		"command":        {Checks: []Check{{Button: "ButtonA"}}},
+	}
+	for name, config := range invalid {
+		if _, err := NewBoard(&config, fixtures); err == nil {
+			t.Errorf("Invalid configuration (%s) accepted", name)
+		}
+	}
+
+	board, err := NewBoard(&Configuration{}, fixtures)
+	if err != nil {
+		t.Fatalf("Board not created: %s", err.Error())
+	}
+	if len(board.meters) != 7 || board.meters[3].Core != 4 || board.meters[6].Max != 4 {
+		t.Errorf("Wrong default meters: %v", board.meters)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+/**
+ * miDiStatus turns a Launchpad Mini into a system monitor. Every column of the grid shows a metric like the usage
+ * of a cpu core, the memory, a disk, the load or network traffic as a bar, buttons show whether commands succeed.
+ *
+ * Metrics are read from /proc, so it only works on Linux.
+ */
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	lm "github.com/sirion/gomidi/lib/launchpadmini"
+)
+
+// Configuration contains the meters shown in the columns of the grid from left to right and the checks
+type Configuration struct {
+	Device   string  `json:"device"`
+	Interval int     `json:"interval,omitempty"` // Milliseconds between updates (default 1s)
+	Meters   []Meter `json:"meters,omitempty"`   // Up to 8 meters, cores, memory, disk and load if empty
+	Checks   []Check `json:"checks,omitempty"`
+}
+
+func getUserDir() string {
+	user, err := user.Current()
+	if err != nil {
+		log.Fatalf("Could not find user name: %s", err.Error())
+	}
+
+	return user.HomeDir
+}
+
+// Load reads the configuration from the given file
+func (c *Configuration) Load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Error loading configuration file: %s", err.Error())
+	}
+
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return fmt.Errorf("Error parsing configuration file: %s", err.Error())
+	}
+
+	return nil
+}
+
+func main() {
+	configurationPath := flag.String("config", "", "Override configuration file path")
+	device := flag.String("device", "", "Override configured midi device path")
+	proc := flag.String("proc", "/proc", "Mount point of the proc file system")
+	flag.Parse()
+
+	// Without a configuration file the default meters are shown on the first launchpad
+	explicit := *configurationPath != ""
+	if !explicit {
+		*configurationPath = filepath.Join(getUserDir(), ".config", "midistatus", "config.json")
+	}
+
+	config := &Configuration{Device: "auto"}
+	if _, err := os.Stat(*configurationPath); err == nil || explicit {
+		if err := config.Load(*configurationPath); err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+	if *device != "" {
+		config.Device = *device
+	}
+	interval := time.Second
+	if config.Interval > 0 {
+		interval = time.Duration(config.Interval) * time.Millisecond
+	}
+
+	board, err := NewBoard(config, Proc{Root: *proc})
+	if err != nil {
+		log.Fatalf("Error in configuration: %s", err.Error())
+	}
+
+	lp := lm.New(config.Device)
+	board.Start()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Errors are only printed when they change, not on every update
+	reported := ""
+	for {
+		select {
+		case <-signals:
+			lp.Reset()
+			lp.Close()
+			return
+		case now := <-ticker.C:
+			buttons, err := board.Update(now)
+			if err != nil && err.Error() != reported {
+				log.Printf("Error reading metrics: %s", err.Error())
+			}
+			reported = ""
+			if err != nil {
+				reported = err.Error()
+			}
+			lp.RapidUpdate(buttons)
+		}
+	}
+}
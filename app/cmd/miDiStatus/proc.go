@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Proc reads system metrics from the proc filesystem mounted at Root, which is /proc except in tests
+type Proc struct {
+	Root string
+}
+
+// cpuTimes are the times a cpu spent since boot in clock ticks
+type cpuTimes struct {
+	idle  uint64 // Idle and waiting for input/output
+	total uint64
+}
+
+// usage returns the part of the time between the previous times and t the cpu was busy, from 0 to 1
+func (t cpuTimes) usage(previous cpuTimes) float64 {
+	if t.total <= previous.total || t.idle < previous.idle {
+		return 0
+	}
+	return 1 - float64(t.idle-previous.idle)/float64(t.total-previous.total)
+}
+
+// CPUs reads the times of all cpus (index 0) and of every core (index 1 and following) from stat
+func (p Proc) CPUs() ([]cpuTimes, error) {
+	file, err := os.Open(filepath.Join(p.Root, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cpus := []cpuTimes{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+
+		times := cpuTimes{}
+		for i, field := range fields[1:] {
+			// Guest times (from the 9th value on) are part of the user times
+			if i >= 8 {
+				break
+			}
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s in stat: %s", fields[0], err.Error())
+			}
+			times.total += value
+			if i == 3 || i == 4 {
+				times.idle += value
+			}
+		}
+		cpus = append(cpus, times)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("no cpus in stat")
+	}
+	return cpus, nil
+}
+
+// Memory returns the part of the memory that is used, from 0 to 1
+func (p Proc) Memory() (float64, error) {
+	file, err := os.Open(filepath.Join(p.Root, "meminfo"))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[strings.TrimSuffix(fields[0], ":")] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	total, available := values["MemTotal"], values["MemAvailable"]
+	if total == 0 {
+		return 0, fmt.Errorf("no MemTotal and MemAvailable in meminfo")
+	}
+	if available > total {
+		return 0, fmt.Errorf("MemAvailable %d kB is more than MemTotal %d kB in meminfo", available, total)
+	}
+	return 1 - float64(available)/float64(total), nil
+}
+
+// Load returns the load average of the last minute
+func (p Proc) Load() (float64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(p.Root, "loadavg"))
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty loadavg")
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid loadavg: %s", err.Error())
+	}
+	return load, nil
+}
+
+// Network returns the bytes received and sent by the network interface from net/dev
+func (p Proc) Network(name string) (received, sent uint64, err error) {
+	file, err := os.Open(filepath.Join(p.Root, "net", "dev"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) != name {
+			continue
+		}
+
+		fields := strings.Fields(parts[1])
+		if len(fields) < 9 {
+			return 0, 0, fmt.Errorf("invalid interface %s in net/dev", name)
+		}
+		received, err1 := strconv.ParseUint(fields[0], 10, 64)
+		sent, err2 := strconv.ParseUint(fields[8], 10, 64)
+		if err1 != nil || err2 != nil {
+			return 0, 0, fmt.Errorf("invalid interface %s in net/dev", name)
+		}
+		return received, sent, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	return 0, 0, fmt.Errorf("unknown network interface %s", name)
+}
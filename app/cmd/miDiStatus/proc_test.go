@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var fixtures = Proc{Root: "testdata/proc"}
+
+func TestProc(t *testing.T) {
+	cpus, err := fixtures.CPUs()
+	if err != nil {
+		t.Fatalf("CPUs not read: %s", err.Error())
+	}
+	if len(cpus) != 5 {
+		t.Fatalf("Wrong number of cpus: %d", len(cpus))
+	}
+	if cpus[1] != (cpuTimes{idle: 923692 + 5858, total: 1393 + 280 + 236 + 923692 + 5858 + 70}) {
+		t.Errorf("Wrong times of cpu0: %v", cpus[1])
+	}
+	later := cpuTimes{idle: cpus[1].idle + 75, total: cpus[1].total + 100}
+	if usage := later.usage(cpus[1]); math.Abs(usage-0.25) > 1e-9 {
+		t.Errorf("Wrong usage: %g", usage)
+	}
+	if usage := cpus[1].usage(cpus[1]); usage != 0 {
+		t.Errorf("Usage without time: %g", usage)
+	}
+
+	memory, err := fixtures.Memory()
+	if err != nil || math.Abs(memory-(1-12236668.0/16315556.0)) > 1e-9 {
+		t.Errorf("Wrong memory: %g, %v", memory, err)
+	}
+
+	load, err := fixtures.Load()
+	if err != nil || load != 1.2 {
+		t.Errorf("Wrong load: %g, %v", load, err)
+	}
+
+	received, sent, err := fixtures.Network("eth0")
+	if err != nil || received != 172938513 || sent != 8413977 {
+		t.Errorf("Wrong traffic: %d, %d, %v", received, sent, err)
+	}
+	if _, _, err := fixtures.Network("eth1"); err == nil {
+		t.Errorf("Unknown interface found")
+	}
+
+	missing := Proc{Root: "testdata/missing"}
+	if _, err := missing.CPUs(); err == nil {
+		t.Errorf("Missing stat read")
+	}
+	if _, err := missing.Memory(); err == nil {
+		t.Errorf("Missing meminfo read")
+	}
+
+	dir := copyProc(t)
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "meminfo"), []byte("MemTotal: 1000 kB\nMemAvailable: 2000 kB\n"), 0644)
+	if _, err := (Proc{Root: dir}).Memory(); err == nil || !strings.Contains(err.Error(), "more than MemTotal") {
+		t.Errorf("Wrong error for more available than total memory: %v", err)
+	}
+}
@@ -1,3 +1,9 @@
 module github.com/sirion/gomidi
 
-require github.com/go-vgo/robotgo v0.0.0-20190125165610-5cb95037c5d1
+require (
+	github.com/BurntSushi/xgb v0.0.0-20160522181843-27f122750802
+	github.com/BurntSushi/toml v1.2.1
+	github.com/go-vgo/robotgo v0.0.0-20190125165610-5cb95037c5d1
+	go.starlark.net v0.0.0-20230525235612-a134d8f9ddca
+	gopkg.in/yaml.v3 v3.0.1
+)
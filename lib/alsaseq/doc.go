@@ -0,0 +1,12 @@
+// Package alsaseq creates virtual midi ports using the ALSA sequencer (/dev/snd/seq) on Linux.
+//
+// Other than the raw midi devices (/dev/snd/midiC*D*) used by the launchpadmini package, sequencer
+// ports can be created by any program. They show up next to the hardware ports in DAWs, synths and
+// tools like aconnect, which can connect them to other ports. Ports can also be connected from
+// within the program using Connect.
+//
+// The sequencer works with decoded events instead of midi bytes, Port converts between both so it
+// can be used as an io.ReadWriter of midi bytes like the raw midi devices.
+//
+// Without midi hardware the package can be tried out with the snd-seq-dummy and snd-virmidi kernel modules.
+package alsaseq
@@ -0,0 +1,317 @@
+package alsaseq
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/sirion/gomidi/lib/midi"
+)
+
+// Address identifies a port of a sequencer client
+type Address struct {
+	Client byte
+	Port   byte
+}
+
+// String returns the address in the "client:port" notation used by the ALSA tools
+func (a Address) String() string {
+	return fmt.Sprintf("%d:%d", a.Client, a.Port)
+}
+
+// ParseAddress parses an address in the "client:port" notation. The port is optional and defaults to 0.
+func ParseAddress(address string) (Address, error) {
+	parts := strings.Split(address, ":")
+	if len(parts) > 2 {
+		return Address{}, fmt.Errorf("invalid sequencer address \"%s\"", address)
+	}
+
+	client, err := strconv.ParseUint(parts[0], 10, 8)
+	if err != nil {
+		return Address{}, fmt.Errorf("invalid client in sequencer address \"%s\"", address)
+	}
+
+	var port uint64
+	if len(parts) == 2 {
+		port, err = strconv.ParseUint(parts[1], 10, 8)
+		if err != nil {
+			return Address{}, fmt.Errorf("invalid port in sequencer address \"%s\"", address)
+		}
+	}
+
+	return Address{Client: byte(client), Port: byte(port)}, nil
+}
+
+// Sequencer event types (snd_seq_event_type_t) that correspond to midi messages
+const (
+	eventNoteOn      = 6
+	eventNoteOff     = 7
+	eventKeyPress    = 8
+	eventController  = 10
+	eventPgmChange   = 11
+	eventChanPress   = 12
+	eventPitchBend   = 13
+	eventControl14   = 14
+	eventNonRegParam = 15
+	eventRegParam    = 16
+	eventSongPos     = 20
+	eventSongSel     = 21
+	eventQFrame      = 22
+	eventStart       = 30
+	eventContinue    = 31
+	eventStop        = 32
+	eventClock       = 36
+	eventTuneRequest = 40
+	eventReset       = 41
+	eventSensing     = 42
+	eventSysEx       = 130
+)
+
+// Special addresses and queues
+const (
+	queueDirect         = 253 // Events are delivered immediately
+	addressUnknown      = 253
+	addressSubscribers  = 254 // Events are delivered to all subscribers of the source port
+	eventLengthVariable = 1 << 2
+	eventLengthMask     = 3 << 2
+	extMask             = 0xc0000000
+)
+
+// eventSize is the size of struct snd_seq_event
+const eventSize = 28
+
+// event is the fixed size part of struct snd_seq_event. Events with variable length (system
+// exclusive) are followed by their data.
+//
+//	0: type, 1: flags, 2: tag, 3: queue, 4-11: time stamp, 12-13: source, 14-15: destination, 16-27: data
+type event struct {
+	typ    byte
+	flags  byte
+	queue  byte
+	source Address
+	dest   Address
+	data   [12]byte
+}
+
+var nativeEndian binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// marshal returns the binary representation of the event followed by the given variable length data
+func (e *event) marshal(extra []byte) []byte {
+	buffer := make([]byte, eventSize, eventSize+len(extra))
+	buffer[0] = e.typ
+	buffer[1] = e.flags
+	buffer[3] = e.queue
+	buffer[12] = e.source.Client
+	buffer[13] = e.source.Port
+	buffer[14] = e.dest.Client
+	buffer[15] = e.dest.Port
+	copy(buffer[16:], e.data[:])
+
+	if e.flags&eventLengthMask == eventLengthVariable {
+		nativeEndian.PutUint32(buffer[16:], uint32(len(extra)))
+		buffer = append(buffer, extra...)
+	}
+
+	return buffer
+}
+
+// unmarshal reads an event from the beginning of the buffer. It returns the variable length data
+// of the event and the number of bytes used including the padding after the variable length data.
+func (e *event) unmarshal(buffer []byte) (extra []byte, size int, err error) {
+	if len(buffer) < eventSize {
+		return nil, 0, fmt.Errorf("incomplete sequencer event (%d bytes)", len(buffer))
+	}
+
+	e.typ = buffer[0]
+	e.flags = buffer[1]
+	e.queue = buffer[3]
+	e.source = Address{buffer[12], buffer[13]}
+	e.dest = Address{buffer[14], buffer[15]}
+	copy(e.data[:], buffer[16:eventSize])
+
+	size = eventSize
+	if e.flags&eventLengthMask == eventLengthVariable {
+		length := int(nativeEndian.Uint32(e.data[:]) &^ extMask)
+		if eventSize+length > len(buffer) {
+			return nil, 0, fmt.Errorf("incomplete variable length sequencer event (%d of %d bytes)", len(buffer)-eventSize, length)
+		}
+		extra = buffer[eventSize : eventSize+length]
+
+		// The kernel pads the data to a multiple of the event size
+		size += (length + eventSize - 1) / eventSize * eventSize
+		if size > len(buffer) {
+			size = len(buffer)
+		}
+	}
+
+	return extra, size, nil
+}
+
+// setNote sets the data of note events: channel, note, velocity, off velocity, duration
+func (e *event) setNote(channel, note, velocity byte) {
+	e.data[0] = channel
+	e.data[1] = note
+	e.data[2] = velocity
+}
+
+// setControl sets the data of controller events: channel, 3 unused bytes, param (uint32), value (int32)
+func (e *event) setControl(channel byte, param uint32, value int32) {
+	e.data[0] = channel
+	nativeEndian.PutUint32(e.data[4:], param)
+	nativeEndian.PutUint32(e.data[8:], uint32(value))
+}
+
+func (e *event) control() (channel byte, param uint32, value int32) {
+	return e.data[0], nativeEndian.Uint32(e.data[4:]), int32(nativeEndian.Uint32(e.data[8:]))
+}
+
+// encodeEvent converts a complete midi message into a sequencer event and its variable length data.
+// It returns false for messages that cannot be sent using the sequencer.
+func encodeEvent(message []byte) (event, []byte, bool) {
+	e := event{queue: queueDirect}
+	if len(message) == 0 {
+		return e, nil, false
+	}
+
+	status := message[0]
+	data1, data2 := byte(0), byte(0)
+	if len(message) > 1 {
+		data1 = message[1]
+	}
+	if len(message) > 2 {
+		data2 = message[2]
+	}
+
+	if status < 0xf0 {
+		channel := status & 0x0f
+		switch status & 0xf0 {
+		case 0x80:
+			e.typ = eventNoteOff
+			e.setNote(channel, data1, data2)
+		case 0x90:
+			e.typ = eventNoteOn
+			e.setNote(channel, data1, data2)
+		case 0xa0:
+			e.typ = eventKeyPress
+			e.setNote(channel, data1, data2)
+		case 0xb0:
+			e.typ = eventController
+			e.setControl(channel, uint32(data1), int32(data2))
+		case 0xc0:
+			e.typ = eventPgmChange
+			e.setControl(channel, 0, int32(data1))
+		case 0xd0:
+			e.typ = eventChanPress
+			e.setControl(channel, 0, int32(data1))
+		case 0xe0:
+			// The sequencer uses signed values with 0 meaning no change in pitch
+			e.typ = eventPitchBend
+			e.setControl(channel, 0, (int32(data2)<<7|int32(data1))-0x2000)
+		}
+		return e, nil, true
+	}
+
+	switch status {
+	case 0xf0:
+		e.typ = eventSysEx
+		e.flags = eventLengthVariable
+		return e, message, true
+	case 0xf1:
+		e.typ = eventQFrame
+		e.setControl(0, 0, int32(data1))
+	case 0xf2:
+		e.typ = eventSongPos
+		e.setControl(0, 0, int32(data2)<<7|int32(data1))
+	case 0xf3:
+		e.typ = eventSongSel
+		e.setControl(0, 0, int32(data1))
+	case 0xf6:
+		e.typ = eventTuneRequest
+	case 0xf8:
+		e.typ = eventClock
+	case 0xfa:
+		e.typ = eventStart
+	case 0xfb:
+		e.typ = eventContinue
+	case 0xfc:
+		e.typ = eventStop
+	case 0xfe:
+		e.typ = eventSensing
+	case 0xff:
+		e.typ = eventReset
+	default:
+		return e, nil, false
+	}
+
+	return e, nil, true
+}
+
+// decodeEvent converts a sequencer event into midi bytes. Events that have no midi representation
+// (for example port subscription notifications) return nil.
+func decodeEvent(e *event, extra []byte) []byte {
+	channel, param, value := e.control()
+	channel &= 0x0f
+
+	switch e.typ {
+	case eventNoteOff:
+		return midi.NoteOff(channel, e.data[1], e.data[2])
+	case eventNoteOn:
+		return midi.NoteOn(channel, e.data[1], e.data[2])
+	case eventKeyPress:
+		return []byte{0xa0 | channel, e.data[1] & 0x7f, e.data[2] & 0x7f}
+	case eventController:
+		return midi.Controller(channel, byte(param), byte(value))
+	case eventPgmChange:
+		return midi.ProgramChange(channel, byte(value))
+	case eventChanPress:
+		return []byte{0xd0 | channel, byte(value) & 0x7f}
+	case eventPitchBend:
+		// Pitch bend messages are sent LSB first
+		bend := uint16(value + 0x2000)
+		return []byte{0xe0 | channel, byte(bend & 0x7f), byte(bend>>7) & 0x7f}
+	case eventControl14:
+		if param < 32 {
+			return midi.Controller14(channel, byte(param), uint16(value))
+		}
+		return midi.Controller(channel, byte(param), byte(value))
+	case eventNonRegParam:
+		return midi.NonRegisteredParameter(channel, uint16(param), uint16(value))
+	case eventRegParam:
+		return midi.RegisteredParameter(channel, uint16(param), uint16(value))
+	case eventQFrame:
+		return []byte{0xf1, byte(value) & 0x7f}
+	case eventSongPos:
+		return []byte{0xf2, byte(value) & 0x7f, byte(value>>7) & 0x7f}
+	case eventSongSel:
+		return []byte{0xf3, byte(value) & 0x7f}
+	case eventTuneRequest:
+		return []byte{0xf6}
+	case eventClock:
+		return []byte{0xf8}
+	case eventStart:
+		return []byte{0xfa}
+	case eventContinue:
+		return []byte{0xfb}
+	case eventStop:
+		return []byte{0xfc}
+	case eventSensing:
+		return []byte{0xfe}
+	case eventReset:
+		return midi.Reset()
+	case eventSysEx:
+		message := make([]byte, len(extra))
+		copy(message, extra)
+		return message
+	}
+
+	return nil
+}
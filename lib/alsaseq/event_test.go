@@ -0,0 +1,97 @@
+package alsaseq
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirion/gomidi/lib/midi"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := map[string]Address{
+		"20:0":  {20, 0},
+		"128:3": {128, 3},
+		"14":    {14, 0},
+	}
+	for input, want := range tests {
+		got, err := ParseAddress(input)
+		if err != nil || got != want {
+			t.Errorf("ParseAddress(\"%s\"): Got %v (%v), expected %v", input, got, err, want)
+		}
+		if len(input) > 2 && got.String() != input {
+			t.Errorf("Address.String(): Got \"%s\", expected \"%s\"", got.String(), input)
+		}
+	}
+
+	for _, input := range []string{"", "a:0", "1:2:3", "300:0", "1:x"} {
+		if _, err := ParseAddress(input); err == nil {
+			t.Errorf("ParseAddress(\"%s\") did not fail", input)
+		}
+	}
+}
+
+func TestEventRoundTrip(t *testing.T) {
+	messages := [][]byte{
+		midi.NoteOn(1, 60, 100),
+		midi.NoteOff(15, 127, 0),
+		midi.Controller(2, 7, 99),
+		midi.ProgramChange(3, 42),
+		{0xa4, 60, 10},
+		{0xd5, 77},
+		{0xe6, 0x00, 0x40},
+		{0xe6, 0x7f, 0x7f},
+		{0xf2, 0x01, 0x02},
+		{0xf8},
+		{0xfa},
+		{0xf0, 0x7e, 0x7f, 0x06, 0x01, 0xf7},
+	}
+
+	for _, message := range messages {
+		e, extra, ok := encodeEvent(message)
+		if !ok {
+			t.Errorf("Message %x could not be encoded", message)
+			continue
+		}
+
+		buffer := e.marshal(extra)
+		// Add the padding the kernel adds to variable length events and a second event
+		buffer = append(buffer, make([]byte, (eventSize-len(extra)%eventSize)%eventSize)...)
+		buffer = append(buffer, buffer[:eventSize]...)
+
+		decoded := event{}
+		decodedExtra, size, err := decoded.unmarshal(buffer)
+		if err != nil {
+			t.Errorf("Message %x could not be decoded: %s", message, err.Error())
+			continue
+		}
+		if size != len(buffer)-eventSize {
+			t.Errorf("Wrong event size for %x: Got %d, expected %d", message, size, len(buffer)-eventSize)
+		}
+
+		if got := decodeEvent(&decoded, decodedExtra); !bytes.Equal(got, message) {
+			t.Errorf("Wrong message after round trip: Got %x, expected %x", got, message)
+		}
+	}
+}
+
+func TestPitchBendEvent(t *testing.T) {
+	e, _, _ := encodeEvent([]byte{0xe0, 0x00, 0x40})
+	if _, _, value := e.control(); value != 0 {
+		t.Errorf("Center pitch bend: Got %d, expected 0", value)
+	}
+
+	e, _, _ = encodeEvent([]byte{0xe0, 0x00, 0x00})
+	if _, _, value := e.control(); value != -8192 {
+		t.Errorf("Minimum pitch bend: Got %d, expected -8192", value)
+	}
+}
+
+func TestParameterEvents(t *testing.T) {
+	e := event{typ: eventRegParam}
+	e.setControl(1, uint32(midi.RPNPitchBendRange), 12<<7)
+
+	got := decodeEvent(&e, nil)
+	if want := midi.PitchBendRange(1, 12, 0); !bytes.Equal(got, want) {
+		t.Errorf("Wrong registered parameter messages: Got %x, expected %x", got, want)
+	}
+}
@@ -0,0 +1,497 @@
+package alsaseq
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/sirion/gomidi/lib/midi"
+)
+
+// Port capabilities (SNDRV_SEQ_PORT_CAP_*)
+const (
+	CapRead      uint32 = 1 << 0 // Events can be read from the port
+	CapWrite     uint32 = 1 << 1 // Events can be written to the port
+	CapDuplex    uint32 = 1 << 4
+	CapSubsRead  uint32 = 1 << 5 // Other clients can subscribe to the events of the port
+	CapSubsWrite uint32 = 1 << 6 // Other clients can subscribe the port to their events
+	CapNoExport  uint32 = 1 << 7
+)
+
+// Port types (SNDRV_SEQ_PORT_TYPE_*)
+const (
+	typeMIDIGeneric uint32 = 1 << 1
+	typeSoftware    uint32 = 1 << 16
+	typeApplication uint32 = 1 << 20
+)
+
+// clientInfo has the layout of struct snd_seq_client_info, it is only used for its size
+type clientInfo struct {
+	client          int32
+	clientType      int32
+	name            [64]byte
+	filter          uint32
+	multicastFilter [8]byte
+	eventFilter     [32]byte
+	numPorts        int32
+	eventLost       int32
+	card            int32
+	pid             int32
+	reserved        [56]byte
+}
+
+// portInfo has the layout of struct snd_seq_port_info, it is only used for its size. The kernel pointer makes it
+// smaller on 32-bit systems, all fields used by this package are in front of it.
+type portInfo struct {
+	addr         [2]byte
+	name         [64]byte
+	capability   uint32
+	portType     uint32
+	midiChannels int32
+	midiVoices   int32
+	synthVoices  int32
+	readUse      int32
+	writeUse     int32
+	kernel       uintptr
+	flags        uint32
+	timeQueue    byte
+	reserved     [59]byte
+}
+
+// portSubscribe has the layout of struct snd_seq_port_subscribe, it is only used for its size
+type portSubscribe struct {
+	sender   [2]byte
+	dest     [2]byte
+	voices   uint32
+	flags    uint32
+	queue    byte
+	pad      [3]byte
+	reserved [64]byte
+}
+
+// Sizes of the structs used with ioctl calls, they depend on the architecture
+const (
+	clientInfoSize = unsafe.Sizeof(clientInfo{})
+	portInfoSize   = unsafe.Sizeof(portInfo{})
+	subscribeSize  = unsafe.Sizeof(portSubscribe{})
+)
+
+// inputSize is the number of events a port keeps until Read is called. Further events are dropped, so a port
+// that is not read does not stop the other ports.
+const inputSize = 256
+
+// ioctl request numbers: direction << 30 | size << 16 | 'S' << 8 | number
+const (
+	iocWrite = 1
+	iocRead  = 2
+
+	ioctlPVersion        = iocRead<<30 | 4<<16 | 'S'<<8 | 0x00
+	ioctlClientID        = iocRead<<30 | 4<<16 | 'S'<<8 | 0x01
+	ioctlGetClientInfo   = (iocRead|iocWrite)<<30 | clientInfoSize<<16 | 'S'<<8 | 0x10
+	ioctlSetClientInfo   = iocWrite<<30 | clientInfoSize<<16 | 'S'<<8 | 0x11
+	ioctlCreatePort      = (iocRead|iocWrite)<<30 | portInfoSize<<16 | 'S'<<8 | 0x20
+	ioctlDeletePort      = iocWrite<<30 | portInfoSize<<16 | 'S'<<8 | 0x21
+	ioctlSubscribePort   = iocWrite<<30 | subscribeSize<<16 | 'S'<<8 | 0x30
+	ioctlUnsubscribePort = iocWrite<<30 | subscribeSize<<16 | 'S'<<8 | 0x31
+	ioctlQueryNextClient = (iocRead|iocWrite)<<30 | clientInfoSize<<16 | 'S'<<8 | 0x51
+	ioctlQueryNextPort   = (iocRead|iocWrite)<<30 | portInfoSize<<16 | 'S'<<8 | 0x52
+)
+
+// DevicePath is the path of the ALSA sequencer device
+const DevicePath = "/dev/snd/seq"
+
+// PortInfo describes a port of a sequencer client
+type PortInfo struct {
+	Address
+	Name       string
+	ClientName string
+	Capability uint32
+}
+
+// Sequencer is a client of the ALSA sequencer that can create ports and connect them to other ports
+type Sequencer struct {
+	fd     *os.File
+	client byte
+
+	mutex     sync.Mutex
+	ports     map[byte]*Port
+	listening sync.Once
+	closing   bool
+	err       error // Error that ended listening
+
+	errors chan error
+}
+
+// Open connects to the ALSA sequencer as a new client with the given name. The name is shown by other
+// programs next to the names of the ports.
+func Open(name string) (*Sequencer, error) {
+	fd, err := os.OpenFile(DevicePath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sequencer{
+		fd:     fd,
+		ports:  make(map[byte]*Port),
+		errors: make(chan error, 16),
+	}
+
+	var version [4]byte
+	if err := s.ioctl(ioctlPVersion, version[:]); err != nil {
+		fd.Close()
+		return nil, fmt.Errorf("sequencer does not respond: %s", err.Error())
+	}
+
+	var id [4]byte
+	if err := s.ioctl(ioctlClientID, id[:]); err != nil {
+		fd.Close()
+		return nil, fmt.Errorf("could not get sequencer client id: %s", err.Error())
+	}
+	s.client = byte(nativeEndian.Uint32(id[:]))
+
+	info := make([]byte, clientInfoSize)
+	nativeEndian.PutUint32(info[0:], uint32(s.client))
+	if err := s.ioctl(ioctlGetClientInfo, info); err != nil {
+		fd.Close()
+		return nil, fmt.Errorf("could not get sequencer client info: %s", err.Error())
+	}
+	putString(info[8:72], name)
+	if err := s.ioctl(ioctlSetClientInfo, info); err != nil {
+		fd.Close()
+		return nil, fmt.Errorf("could not set sequencer client name: %s", err.Error())
+	}
+
+	return s, nil
+}
+
+// Client returns the client number assigned by the sequencer
+func (s *Sequencer) Client() byte {
+	return s.client
+}
+
+// CreatePort creates a new virtual port with the given name that other clients can read from and write to
+func (s *Sequencer) CreatePort(name string) (*Port, error) {
+	info := make([]byte, portInfoSize)
+	info[0] = s.client
+	putString(info[2:66], name)
+	nativeEndian.PutUint32(info[68:], CapRead|CapWrite|CapDuplex|CapSubsRead|CapSubsWrite)
+	nativeEndian.PutUint32(info[72:], typeMIDIGeneric|typeSoftware|typeApplication)
+	nativeEndian.PutUint32(info[76:], 16) // midi channels
+
+	if err := s.ioctl(ioctlCreatePort, info); err != nil {
+		return nil, fmt.Errorf("could not create sequencer port \"%s\": %s", name, err.Error())
+	}
+
+	p := &Port{
+		seq:   s,
+		addr:  Address{s.client, info[1]},
+		name:  name,
+		input: make(chan []byte, inputSize),
+		done:  make(chan struct{}),
+	}
+
+	s.mutex.Lock()
+	s.ports[p.addr.Port] = p
+	s.mutex.Unlock()
+
+	return p, nil
+}
+
+// Ports returns all ports of all sequencer clients including the ports of this client
+func (s *Sequencer) Ports() ([]PortInfo, error) {
+	var ports []PortInfo
+
+	client := make([]byte, clientInfoSize)
+	nativeEndian.PutUint32(client[0:], 0xffffffff) // -1: start with the first client
+	for s.ioctl(ioctlQueryNextClient, client) == nil {
+		clientNumber := nativeEndian.Uint32(client[0:])
+		clientName := getString(client[8:72])
+
+		port := make([]byte, portInfoSize)
+		port[0] = byte(clientNumber)
+		port[1] = 0xff // -1: start with the first port
+		for {
+			port[0] = byte(clientNumber)
+			if s.ioctl(ioctlQueryNextPort, port) != nil {
+				break
+			}
+			ports = append(ports, PortInfo{
+				Address:    Address{port[0], port[1]},
+				Name:       getString(port[2:66]),
+				ClientName: clientName,
+				Capability: nativeEndian.Uint32(port[68:]),
+			})
+		}
+	}
+
+	return ports, nil
+}
+
+// FindPort returns the address of a port given either as address ("client:port") or as a case
+// insensitive part of its client and port name (for example "Launchpad" or "Midi Through Port-0")
+func (s *Sequencer) FindPort(name string) (Address, error) {
+	if address, err := ParseAddress(name); err == nil {
+		return address, nil
+	}
+
+	ports, err := s.Ports()
+	if err != nil {
+		return Address{}, err
+	}
+
+	search := strings.ToLower(name)
+	for _, port := range ports {
+		if strings.Contains(strings.ToLower(port.ClientName+" "+port.Name), search) {
+			return port.Address, nil
+		}
+	}
+
+	return Address{}, fmt.Errorf("no sequencer port matches \"%s\"", name)
+}
+
+// Connect subscribes the destination port to the events of the sender port, this works like aconnect.
+// The connection does not need to involve a port of this client.
+func (s *Sequencer) Connect(sender, dest Address) error {
+	return s.ioctl(ioctlSubscribePort, subscription(sender, dest))
+}
+
+// Disconnect removes a connection created by Connect or by another program
+func (s *Sequencer) Disconnect(sender, dest Address) error {
+	return s.ioctl(ioctlUnsubscribePort, subscription(sender, dest))
+}
+
+// Close deletes all ports of this client and closes the connection to the sequencer
+func (s *Sequencer) Close() error {
+	s.mutex.Lock()
+	s.closing = true
+	s.mutex.Unlock()
+
+	return s.fd.Close()
+}
+
+// Errors returns the errors that happen while events are received, like invalid events or events that were
+// dropped because a port was not read. Errors are dropped while the channel is full.
+func (s *Sequencer) Errors() <-chan error {
+	return s.errors
+}
+
+// report passes an error to Errors without blocking
+func (s *Sequencer) report(err error) {
+	select {
+	case s.errors <- err:
+	default:
+	}
+}
+
+func (s *Sequencer) ioctl(request uintptr, data []byte) error {
+	conn, err := s.fd.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(unsafe.Pointer(&data[0])))
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// listen reads events from the sequencer and passes them to the ports they are sent to.
+// It ends when the sequencer is closed.
+func (s *Sequencer) listen() {
+	buffer := make([]byte, 16*1024)
+
+	defer func() {
+		s.mutex.Lock()
+		for _, p := range s.ports {
+			p.stop()
+		}
+		s.mutex.Unlock()
+	}()
+
+	for {
+		read, err := s.fd.Read(buffer)
+		if err != nil {
+			s.mutex.Lock()
+			if !s.closing {
+				s.err = fmt.Errorf("reading error from sequencer: %s", err.Error())
+				s.report(s.err)
+			}
+			s.mutex.Unlock()
+			return
+		}
+
+		data := buffer[:read]
+		for len(data) > 0 {
+			e := event{}
+			extra, size, err := e.unmarshal(data)
+			if err != nil {
+				s.report(fmt.Errorf("invalid sequencer event: %s", err.Error()))
+				break
+			}
+			data = data[size:]
+
+			message := decodeEvent(&e, extra)
+			if message == nil {
+				continue
+			}
+
+			s.mutex.Lock()
+			p, ok := s.ports[e.dest.Port]
+			s.mutex.Unlock()
+			if ok {
+				select {
+				case p.input <- message:
+				default:
+					s.report(fmt.Errorf("port \"%s\" is not read, event dropped", p.name))
+				}
+			}
+		}
+	}
+}
+
+// Port is a virtual port of the sequencer client. Midi bytes written to the port are sent to all
+// subscribers, Read returns the midi bytes of the events sent to the port.
+type Port struct {
+	seq  *Sequencer
+	addr Address
+	name string
+
+	output midi.Parser
+	input  chan []byte
+	buffer []byte
+	done   chan struct{}
+	closed sync.Once
+}
+
+// Address returns the sequencer address of the port
+func (p *Port) Address() Address {
+	return p.addr
+}
+
+// Name returns the name of the port
+func (p *Port) Name() string {
+	return p.name
+}
+
+// ConnectTo sends all events written to this port to the given port
+func (p *Port) ConnectTo(dest Address) error {
+	return p.seq.Connect(p.addr, dest)
+}
+
+// ConnectFrom makes all events sent by the given port available to Read
+func (p *Port) ConnectFrom(sender Address) error {
+	return p.seq.Connect(sender, p.addr)
+}
+
+// Write sends the midi messages contained in data to all subscribers of the port.
+// Incomplete messages are kept until they are completed by the next call to Write.
+func (p *Port) Write(data []byte) (int, error) {
+	var out bytes.Buffer
+
+	for _, message := range p.output.Parse(data) {
+		e, extra, ok := encodeEvent(message)
+		if !ok {
+			continue
+		}
+		e.source = p.addr
+		e.dest = Address{addressSubscribers, addressUnknown}
+		out.Write(e.marshal(extra))
+	}
+
+	if out.Len() > 0 {
+		if _, err := p.seq.fd.Write(out.Bytes()); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(data), nil
+}
+
+// Read reads the midi bytes of the events sent to the port. It blocks until an event is received
+// and returns io.EOF when the port or the sequencer has been closed or the error that stopped reading
+// from the sequencer.
+func (p *Port) Read(data []byte) (int, error) {
+	p.seq.listening.Do(func() {
+		go p.seq.listen()
+	})
+
+	if len(p.buffer) == 0 {
+		select {
+		case p.buffer = <-p.input:
+		case <-p.done:
+			return 0, p.seq.readError()
+		}
+	}
+
+	n := copy(data, p.buffer)
+	p.buffer = p.buffer[n:]
+	return n, nil
+}
+
+// readError returns the error that ended listening or io.EOF if the sequencer was closed
+func (s *Sequencer) readError() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	return io.EOF
+}
+
+// Close deletes the port, the sequencer stays open
+func (p *Port) Close() error {
+	info := make([]byte, portInfoSize)
+	info[0] = p.addr.Client
+	info[1] = p.addr.Port
+
+	p.seq.mutex.Lock()
+	delete(p.seq.ports, p.addr.Port)
+	p.seq.mutex.Unlock()
+	p.stop()
+
+	return p.seq.ioctl(ioctlDeletePort, info)
+}
+
+// stop ends all pending and future calls to Read
+func (p *Port) stop() {
+	p.closed.Do(func() {
+		close(p.done)
+	})
+}
+
+func subscription(sender, dest Address) []byte {
+	data := make([]byte, subscribeSize)
+	data[0] = sender.Client
+	data[1] = sender.Port
+	data[2] = dest.Client
+	data[3] = dest.Port
+	return data
+}
+
+// putString copies a string into a zero terminated fixed size char array
+func putString(dest []byte, value string) {
+	n := copy(dest[:len(dest)-1], value)
+	for i := n; i < len(dest); i++ {
+		dest[i] = 0
+	}
+}
+
+// getString reads a zero terminated string from a fixed size char array
+func getString(data []byte) string {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+	return string(data)
+}
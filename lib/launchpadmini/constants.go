@@ -30,6 +30,9 @@ const (
 	ColorYellowFlashing byte = 58
 )
 
+// GridSize is the number of rows and columns of the grid buttons
+const GridSize = 8
+
 // These constants describe all buttons on the Launchpad Mini.
 // The constants represent the hardware/midi byte values for the (Grid) Buttons.
 // The Live-Buttons are mapped to values +100 because of an overlap in the byte value of LiveButton1 with ButtonG
@@ -90,9 +90,14 @@ func (l *LaunchpadMini) Button(button, color byte) {
 	l.fd.Sync()
 }
 
+// GridButton returns the button value of the grid button in the given row and column, counted from the top left
+func GridButton(row, column byte) byte {
+	return 16*row + column
+}
+
 // Grid sets one of the grid buttons identified by its rown and column to the given color from the Color* constants
 func (l *LaunchpadMini) Grid(row, column, color byte) {
-	l.fd.Write(midi.NoteOn(0, (16*row)+column, color))
+	l.fd.Write(midi.NoteOn(0, GridButton(row, column), color))
 	l.fd.Sync()
 }
 
@@ -108,13 +113,39 @@ func (l *LaunchpadMini) Reset() {
 	l.fd.Sync()
 }
 
+// ButtonEvent is a press or release of a button
+type ButtonEvent struct {
+	Button  byte // Button as described by the Button* and LiveButton* constants
+	Pressed bool // True on button down, false on button up
+}
+
 // Listen returns a channel containing the pressed keys on the launchpad.
 // The key is sent to the channel on button down only and contains the key as described by the Button* and LiveButton* constants
 func (l *LaunchpadMini) Listen() chan byte {
 	l.input = make(chan byte, 1)
+	events := l.ListenEvents()
+
+	go func() {
+		for event := range events {
+			if event.Pressed {
+				l.input <- event.Button
+			}
+		}
+	}()
+
+	return l.input
+}
+
+// ListenEvents returns a channel containing the button presses and releases on the launchpad.
+// The channel is closed when reading from the device fails.
+// Only one of Listen and ListenEvents can be used.
+func (l *LaunchpadMini) ListenEvents() chan ButtonEvent {
+	events := make(chan ButtonEvent, 1)
 	l.listening = true
 
 	go func() {
+		defer close(events)
+
 		buffer := make([]byte, 3, 3)
 		for l.listening {
 			read, err := l.fd.Read(buffer)
@@ -123,19 +154,18 @@ func (l *LaunchpadMini) Listen() chan byte {
 				return
 			}
 
-			if read != 3 || buffer[2] != 127 {
-				if buffer[2] != 0 {
-					fmt.Printf("Unknown Launchpad input: %#v\n", buffer[:read])
-				}
+			if read != 3 || (buffer[2] != 127 && buffer[2] != 0) {
+				fmt.Printf("Unknown Launchpad input: %#v\n", buffer[:read])
 				continue
 			}
 
+			pressed := buffer[2] == 127
 			if buffer[0] == 144 {
 				// Grid Button
-				l.input <- buffer[1]
+				events <- ButtonEvent{buffer[1], pressed}
 			} else if buffer[0] == 176 {
 				// Live Button
-				l.input <- buffer[1] + 100
+				events <- ButtonEvent{buffer[1] + 100, pressed}
 			} else {
 				// Ignored
 				fmt.Printf("Unknown: %d (%d)\n", buffer[1], buffer[2])
@@ -144,7 +174,7 @@ func (l *LaunchpadMini) Listen() chan byte {
 
 	}()
 
-	return l.input
+	return events
 }
 
 // Text outputs a string to the launchpad in the given color from the COlor* constants
@@ -189,9 +219,9 @@ func (l *LaunchpadMini) RapidUpdate(buttonmap map[byte]byte) {
 	i++
 
 	// In order to improve readability I will not spell out all buttons and instead loop over the grid.
-	for y = 0; y < 8; y++ {
-		for x = 0; x < 8; x++ {
-			buttons[i] = buttonmap[x+16*y]
+	for y = 0; y < GridSize; y++ {
+		for x = 0; x < GridSize; x++ {
+			buttons[i] = buttonmap[GridButton(y, x)]
 			i++
 		}
 	}
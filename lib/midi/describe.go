@@ -0,0 +1,65 @@
+package midi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Describe returns a human readable form of the given complete midi message, for example
+// "ch1 NoteOn C4 vel 100". Channels are counted from 1 like on most devices.
+func Describe(message []byte) string {
+	if len(message) == 0 {
+		return ""
+	}
+
+	messageType := Type(message)
+	if len(message) < messageLength(message[0]) {
+		return fmt.Sprintf("%s (incomplete) %s", messageType, hexBytes(message))
+	}
+
+	prefix := ""
+	if channel, ok := Channel(message); ok {
+		prefix = fmt.Sprintf("ch%d ", channel+1)
+	}
+
+	switch messageType {
+	case TypeNoteOff, TypeNoteOn:
+		return fmt.Sprintf("%s%s %s vel %d", prefix, messageType, NoteName(message[1]), message[2])
+	case TypePolyPressure:
+		return fmt.Sprintf("%s%s %s pressure %d", prefix, messageType, NoteName(message[1]), message[2])
+	case TypeController:
+		return fmt.Sprintf("%s%s %d value %d", prefix, messageType, message[1], message[2])
+	case TypeProgramChange, TypeChannelPressure, TypeSongSelect:
+		return fmt.Sprintf("%s%s %d", prefix, messageType, message[1])
+	case TypePitchBend:
+		return fmt.Sprintf("%s%s %d", prefix, messageType, PitchBendValue(message))
+	case TypeSongPosition:
+		return fmt.Sprintf("%s %d", messageType, int(message[1])|int(message[2])<<7)
+	case TypeTimeCode:
+		return fmt.Sprintf("%s frame %d value %d", messageType, message[1]>>4, message[1]&b00001111)
+	case TypeSysEx:
+		return fmt.Sprintf("%s %s", messageType, hexBytes(message))
+	}
+
+	if _, known := messageTypeNames[messageType]; !known {
+		return fmt.Sprintf("%s %s", messageType, hexBytes(message))
+	}
+	return messageType.String()
+}
+
+// PitchBendValue returns the value of a pitch bend message from -8192 to 8191, 0 is the center.
+// The message is read in wire order (LSB first).
+func PitchBendValue(message []byte) int {
+	if Type(message) != TypePitchBend || len(message) < 3 {
+		return 0
+	}
+	return int(message[1]&b01111111) | int(message[2]&b01111111)<<7 - 8192
+}
+
+func hexBytes(data []byte) string {
+	parts := make([]string, len(data))
+	for i, b := range data {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(parts, " ")
+}
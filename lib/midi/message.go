@@ -0,0 +1,141 @@
+package midi
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MessageType is the type of a midi message. For channel messages it is the status byte without
+// the channel, for system messages it is the complete status byte.
+type MessageType byte
+
+// Message types of midi 1.0
+const (
+	TypeNoteOff         MessageType = 0x80
+	TypeNoteOn          MessageType = 0x90
+	TypePolyPressure    MessageType = 0xa0
+	TypeController      MessageType = 0xb0
+	TypeProgramChange   MessageType = 0xc0
+	TypeChannelPressure MessageType = 0xd0
+	TypePitchBend       MessageType = 0xe0
+	TypeSysEx           MessageType = 0xf0
+	TypeTimeCode        MessageType = 0xf1
+	TypeSongPosition    MessageType = 0xf2
+	TypeSongSelect      MessageType = 0xf3
+	TypeTuneRequest     MessageType = 0xf6
+	TypeClock           MessageType = 0xf8
+	TypeStart           MessageType = 0xfa
+	TypeContinue        MessageType = 0xfb
+	TypeStop            MessageType = 0xfc
+	TypeActiveSensing   MessageType = 0xfe
+	TypeReset           MessageType = 0xff
+)
+
+var messageTypeNames = map[MessageType]string{
+	TypeNoteOff:         "NoteOff",
+	TypeNoteOn:          "NoteOn",
+	TypePolyPressure:    "PolyPressure",
+	TypeController:      "Controller",
+	TypeProgramChange:   "ProgramChange",
+	TypeChannelPressure: "ChannelPressure",
+	TypePitchBend:       "PitchBend",
+	TypeSysEx:           "SysEx",
+	TypeTimeCode:        "TimeCode",
+	TypeSongPosition:    "SongPosition",
+	TypeSongSelect:      "SongSelect",
+	TypeTuneRequest:     "TuneRequest",
+	TypeClock:           "Clock",
+	TypeStart:           "Start",
+	TypeContinue:        "Continue",
+	TypeStop:            "Stop",
+	TypeActiveSensing:   "ActiveSensing",
+	TypeReset:           "Reset",
+}
+
+// String returns the name of the message type, for example "NoteOn"
+func (t MessageType) String() string {
+	if name, ok := messageTypeNames[t]; ok {
+		return name
+	}
+	return "Unknown(0x" + strconv.FormatUint(uint64(t), 16) + ")"
+}
+
+// IsChannelMessage returns whether messages of this type are sent on a channel
+func (t MessageType) IsChannelMessage() bool {
+	return t >= TypeNoteOff && t < TypeSysEx
+}
+
+// ParseMessageType returns the message type for the given name (case insensitive, see MessageType.String)
+func ParseMessageType(name string) (MessageType, bool) {
+	for t, typeName := range messageTypeNames {
+		if strings.EqualFold(typeName, name) {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// Type returns the type of the given complete midi message
+func Type(message []byte) MessageType {
+	if len(message) == 0 {
+		return 0
+	}
+	if message[0] < 0xf0 {
+		return MessageType(message[0] & 0xf0)
+	}
+	return MessageType(message[0])
+}
+
+// Channel returns the channel (0 to 15) of the given midi message.
+// The second return value is false for system messages that are not sent on a channel.
+func Channel(message []byte) (byte, bool) {
+	if !Type(message).IsChannelMessage() {
+		return 0, false
+	}
+	return message[0] & b00001111, true
+}
+
+var noteNames = []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// NoteName returns the name of the given note number with octave, note 60 (middle C) is "C4"
+func NoteName(note byte) string {
+	return noteNames[note%12] + strconv.Itoa(int(note)/12-1)
+}
+
+// ParseNoteName returns the note number for a note name like "C4", "f#2", "Bb-1" or a plain note number like "60"
+func ParseNoteName(name string) (byte, bool) {
+	if number, err := strconv.ParseUint(name, 10, 8); err == nil {
+		return byte(number), number <= 127
+	}
+
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if len(name) < 2 {
+		return 0, false
+	}
+
+	note := strings.Index("C D EF G A B", name[0:1])
+	if note < 0 || name[0] == ' ' {
+		return 0, false
+	}
+
+	rest := name[1:]
+	switch {
+	case strings.HasPrefix(rest, "#"):
+		note++
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "B"):
+		note--
+		rest = rest[1:]
+	}
+
+	octave, err := strconv.Atoi(rest)
+	if err != nil {
+		return 0, false
+	}
+
+	number := (octave+1)*12 + note
+	if number < 0 || number > 127 {
+		return 0, false
+	}
+	return byte(number), true
+}
@@ -0,0 +1,69 @@
+package midi
+
+import (
+	"testing"
+)
+
+func TestType(t *testing.T) {
+	if Type(NoteOn(3, 60, 100)) != TypeNoteOn || Type(Controller(0, 1, 1)) != TypeController || Type(Reset()) != TypeReset {
+		t.Errorf("Wrong message types")
+	}
+
+	if channel, ok := Channel(NoteOff(9, 60, 0)); !ok || channel != 9 {
+		t.Errorf("Wrong channel: Got %d (%t), expected 9", channel, ok)
+	}
+	if _, ok := Channel([]byte{0xf8}); ok {
+		t.Errorf("Clock message returned a channel")
+	}
+
+	if messageType, ok := ParseMessageType("programchange"); !ok || messageType != TypeProgramChange {
+		t.Errorf("Wrong message type for \"programchange\": Got %s", messageType)
+	}
+}
+
+func TestNoteName(t *testing.T) {
+	names := map[byte]string{0: "C-1", 60: "C4", 61: "C#4", 69: "A4", 127: "G9"}
+	for note, name := range names {
+		if got := NoteName(note); got != name {
+			t.Errorf("NoteName(%d): Got %s, expected %s", note, got, name)
+		}
+		if got, ok := ParseNoteName(name); !ok || got != note {
+			t.Errorf("ParseNoteName(%s): Got %d (%t), expected %d", name, got, ok, note)
+		}
+	}
+
+	parsed := map[string]byte{"db4": 61, "Bb3": 58, "60": 60, "c-1": 0}
+	for name, note := range parsed {
+		if got, ok := ParseNoteName(name); !ok || got != note {
+			t.Errorf("ParseNoteName(%s): Got %d (%t), expected %d", name, got, ok, note)
+		}
+	}
+
+	for _, name := range []string{"", "H4", "C", "G#9", "C-2", "200"} {
+		if _, ok := ParseNoteName(name); ok {
+			t.Errorf("ParseNoteName(%s) did not fail", name)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	descriptions := map[string][]byte{
+		"ch1 NoteOn C4 vel 100":            NoteOn(0, 60, 100),
+		"ch10 NoteOff A4 vel 0":            NoteOff(9, 69, 0),
+		"ch2 Controller 7 value 127":       Controller(1, 7, 127),
+		"ch16 ProgramChange 5":             ProgramChange(15, 5),
+		"ch1 PitchBend 0":                  {0xe0, 0x00, 0x40},
+		"ch1 PitchBend -8192":              {0xe0, 0x00, 0x00},
+		"SongPosition 129":                 {0xf2, 0x01, 0x01},
+		"SysEx f0 7e 7f 06 01 f7":          {0xf0, 0x7e, 0x7f, 0x06, 0x01, 0xf7},
+		"Clock":                            {0xf8},
+		"NoteOn (incomplete) 90 3c":        {0x90, 0x3c},
+		"ch3 PolyPressure C#4 pressure 20": {0xa2, 61, 20},
+	}
+
+	for description, message := range descriptions {
+		if got := Describe(message); got != description {
+			t.Errorf("Wrong description for %x: Got \"%s\", expected \"%s\"", message, got, description)
+		}
+	}
+}
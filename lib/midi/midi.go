@@ -1,4 +1,5 @@
-// Package midi only contains helper functions that create midi-messages (byte-slices).
+// Package midi contains helper functions that create midi-messages (byte-slices)
+// and parsers that reassemble midi-messages from a byte stream.
 // It is essentially the code version of what I learned from reading http://www.music-software-development.com/midi-tutorial.html.
 //
 // Rule for all midi messages: The first bit in every byte determines whether it is a command byte (1) or a data byte (0) which follows a command byte
@@ -9,6 +10,7 @@
 //  Controller:  1011cccc 0nnnnnnn 0vvvvvvv (c = channel, n = controller number, v = value)
 //  Prog Change: 1100cccc 0xxxxxxx          (c = channel, x = instrument number)
 //  Bend Pitch:  1110cccc 0vvvvvvv 0vvvvvvv (c = channel, v = value) -> v has 14 bit 0 to 16383 (0x3fff)
+//  SysEx:       11110000 0ddddddd ... 11110111 (d = data of any length)
 //  Reset:       11111111
 //
 //  - LSB = Least Significant Byte, MSB = Most Significant Byte
@@ -189,8 +191,8 @@ func BendPitch(channel byte, value uint16) []byte {
 
 	return []byte{
 		(channel & b00001111) | b11100000,
-		byte(value >> 7),
 		byte(value & b01111111),
+		byte(value >> 7),
 	}
 }
 
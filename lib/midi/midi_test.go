@@ -135,11 +135,12 @@ func TestProgramChange(t *testing.T) {
  *  Bend Pitch:  1110cccc 0vvvvvvv 0vvvvvvv (c = channel, v = value) -> v has 14 bit 0 to 16383 (0x3fff)
  */
 func TestBendPitch(t *testing.T) {
-	compareBytes(t, BendPitch(0, 127), []byte{0xe0, 0, 127})
+	compareBytes(t, BendPitch(0, 127), []byte{0xe0, 127, 0})
 	compareBytes(t, BendPitch(0, 0), []byte{0xe0, 0, 0})
-	compareBytes(t, BendPitch(0, 93), []byte{0xe0, 0, 93})
-	compareBytes(t, BendPitch(0, 128), []byte{0xe0, 1, 0})
+	compareBytes(t, BendPitch(0, 93), []byte{0xe0, 93, 0})
+	compareBytes(t, BendPitch(0, 128), []byte{0xe0, 0, 1})
 	compareBytes(t, BendPitch(0, 129), []byte{0xe0, 1, 1})
+	compareBytes(t, BendPitch(0, 0x2000), []byte{0xe0, 0, 64})
 
 	compareBytes(t, BendPitch(1, 0), []byte{0xe1, 0, 0})
 	compareBytes(t, BendPitch(10, 0), []byte{0xea, 0, 0})
@@ -150,6 +151,13 @@ func TestBendPitch(t *testing.T) {
 	// Max value for value is 16383
 	compareBytes(t, BendPitch(0, 16384), []byte{0xe0, 127, 127})
 	compareBytes(t, BendPitch(0, 43214), []byte{0xe0, 127, 127})
+
+	// Values are read back in wire order
+	for _, value := range []uint16{0, 1, 127, 128, 0x1fff, 0x2000, 0x2001, 0x3f80, 0x3fff} {
+		if bent := PitchBendValue(BendPitch(3, value)); bent != int(value)-8192 {
+			t.Errorf("Wrong value read back for %d: %d", value, bent)
+		}
+	}
 }
 
 /*
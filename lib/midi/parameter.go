@@ -0,0 +1,315 @@
+package midi
+
+// Controller numbers with a special meaning for parameter changes.
+//
+// Controllers 0 to 31 send the most significant 7 bits (MSB) of a 14bit value,
+// the controllers 32 to 63 send the least significant 7 bits (LSB) for the
+// controller with the number 32 lower.
+//
+// Registered (RPN) and non-registered (NRPN) parameters are selected by sending
+// the parameter number using the *MSB/*LSB controllers, their value is then set
+// using the data entry controllers or changed with data increment/decrement.
+const (
+	ControllerDataEntryMSB  byte = 6
+	ControllerDataEntryLSB  byte = 38
+	ControllerDataIncrement byte = 96
+	ControllerDataDecrement byte = 97
+	ControllerNRPNLSB       byte = 98
+	ControllerNRPNMSB       byte = 99
+	ControllerRPNLSB        byte = 100
+	ControllerRPNMSB        byte = 101
+)
+
+// Registered parameter numbers (RPN) defined in the midi specification
+const (
+	RPNPitchBendRange uint16 = 0x0000 // MSB: semitones, LSB: cents
+	RPNFineTuning     uint16 = 0x0001 // 14bit value, 0x2000 means A440
+	RPNCoarseTuning   uint16 = 0x0002 // MSB only, 0x40 means A440
+	RPNNull           uint16 = 0x3fff // Deselects the current parameter
+)
+
+// Controller14 sends a 14bit value to one of the controllers 0 to 31 on the
+// given channel. Two controller messages are created: The first one sends the
+// 7 most significant bits to the given controller, the second one sends the 7
+// least significant bits to the controller number + 32.
+func Controller14(channel, controller byte, value uint16) []byte {
+	if controller > 31 {
+		controller = 31
+	}
+	if value > b0011111111111111 {
+		value = b0011111111111111
+	}
+
+	return append(
+		Controller(channel, controller, byte(value>>7)),
+		Controller(channel, controller+32, byte(value&b01111111))...,
+	)
+}
+
+// RegisteredParameter selects the registered parameter (see the RPN* constants)
+// on the given channel and sets it to the given 14bit value.
+func RegisteredParameter(channel byte, parameter, value uint16) []byte {
+	return append(
+		selectParameter(channel, ControllerRPNMSB, ControllerRPNLSB, parameter),
+		dataEntry(channel, value)...,
+	)
+}
+
+// NonRegisteredParameter selects the manufacturer specific non-registered
+// parameter on the given channel and sets it to the given 14bit value.
+func NonRegisteredParameter(channel byte, parameter, value uint16) []byte {
+	return append(
+		selectParameter(channel, ControllerNRPNMSB, ControllerNRPNLSB, parameter),
+		dataEntry(channel, value)...,
+	)
+}
+
+// RegisteredParameterNull deselects the current parameter on the given channel
+// so following data entry messages do not change it by accident.
+func RegisteredParameterNull(channel byte) []byte {
+	return selectParameter(channel, ControllerRPNMSB, ControllerRPNLSB, RPNNull)
+}
+
+// RegisteredParameterIncrement selects the registered parameter on the given
+// channel and increments its value by one step.
+func RegisteredParameterIncrement(channel byte, parameter uint16) []byte {
+	return append(selectParameter(channel, ControllerRPNMSB, ControllerRPNLSB, parameter), DataIncrement(channel)...)
+}
+
+// RegisteredParameterDecrement selects the registered parameter on the given
+// channel and decrements its value by one step.
+func RegisteredParameterDecrement(channel byte, parameter uint16) []byte {
+	return append(selectParameter(channel, ControllerRPNMSB, ControllerRPNLSB, parameter), DataDecrement(channel)...)
+}
+
+// NonRegisteredParameterIncrement selects the non-registered parameter on the
+// given channel and increments its value by one step.
+func NonRegisteredParameterIncrement(channel byte, parameter uint16) []byte {
+	return append(selectParameter(channel, ControllerNRPNMSB, ControllerNRPNLSB, parameter), DataIncrement(channel)...)
+}
+
+// NonRegisteredParameterDecrement selects the non-registered parameter on the
+// given channel and decrements its value by one step.
+func NonRegisteredParameterDecrement(channel byte, parameter uint16) []byte {
+	return append(selectParameter(channel, ControllerNRPNMSB, ControllerNRPNLSB, parameter), DataDecrement(channel)...)
+}
+
+// DataIncrement increments the currently selected parameter on the given channel by one step
+func DataIncrement(channel byte) []byte {
+	return Controller(channel, ControllerDataIncrement, 0)
+}
+
+// DataDecrement decrements the currently selected parameter on the given channel by one step
+func DataDecrement(channel byte) []byte {
+	return Controller(channel, ControllerDataDecrement, 0)
+}
+
+// PitchBendRange sets the range of BendPitch on the given channel to the given
+// number of semitones and cents in each direction. The default is usually 2 semitones.
+func PitchBendRange(channel, semitones, cents byte) []byte {
+	if semitones > b01111111 {
+		semitones = b01111111
+	}
+	if cents > 99 {
+		cents = 99
+	}
+
+	return RegisteredParameter(channel, RPNPitchBendRange, uint16(semitones)<<7|uint16(cents))
+}
+
+// FineTuning tunes the given channel by the given 14bit value where 0x0 means
+// -100 cents, 0x2000 means no change (A440) and 0x3fff means +100 cents
+func FineTuning(channel byte, value uint16) []byte {
+	return RegisteredParameter(channel, RPNFineTuning, value)
+}
+
+// CoarseTuning tunes the given channel in semitones where 0 means -64 semitones,
+// 64 means no change (A440) and 127 means +63 semitones.
+// Only the data entry MSB is sent since the LSB is ignored for coarse tuning.
+func CoarseTuning(channel, semitones byte) []byte {
+	if semitones > b01111111 {
+		semitones = b01111111
+	}
+
+	return append(
+		selectParameter(channel, ControllerRPNMSB, ControllerRPNLSB, RPNCoarseTuning),
+		Controller(channel, ControllerDataEntryMSB, semitones)...,
+	)
+}
+
+// selectParameter sends the given 14bit parameter number to the msb and lsb controllers
+func selectParameter(channel, msbController, lsbController byte, parameter uint16) []byte {
+	if parameter > b0011111111111111 {
+		parameter = b0011111111111111
+	}
+
+	return append(
+		Controller(channel, msbController, byte(parameter>>7)),
+		Controller(channel, lsbController, byte(parameter&b01111111))...,
+	)
+}
+
+// dataEntry sends the given 14bit value using the data entry controllers
+func dataEntry(channel byte, value uint16) []byte {
+	return Controller14(channel, ControllerDataEntryMSB, value)
+}
+
+// ParameterKind describes what kind of parameter has been changed
+type ParameterKind byte
+
+// Kinds of parameter changes reported by the ParameterParser
+const (
+	// ParameterController is a 14bit controller (0 to 31) with its MSB and LSB controller
+	ParameterController ParameterKind = iota
+	// ParameterRegistered is a registered parameter (RPN)
+	ParameterRegistered
+	// ParameterNonRegistered is a non-registered parameter (NRPN)
+	ParameterNonRegistered
+)
+
+// Parameter describes a parameter change reassembled from several controller messages
+type Parameter struct {
+	Kind    ParameterKind
+	Channel byte
+
+	// Number is the controller number (0 to 31) or the 14bit parameter number
+	Number uint16
+
+	// Value is the 14bit value. When only the MSB has been received the LSB is 0.
+	Value uint16
+
+	// Step is 1 for data increment and -1 for data decrement messages. Value is not set in this case.
+	Step int
+}
+
+// ParameterParser reassembles 14bit controller values, registered and
+// non-registered parameter changes from a midi stream. It keeps track of the
+// selected parameter and the last received MSB for every channel.
+//
+// A Parameter is reported for every MSB as well as every LSB, since senders
+// are allowed to only send the MSB when the LSB is not needed.
+type ParameterParser struct {
+	stream   Parser
+	channels [16]parameterState
+}
+
+type parameterState struct {
+	rpn      uint16 // Selected registered parameter
+	nrpn     uint16 // Selected non-registered parameter
+	kind     ParameterKind
+	selected bool
+	data     byte     // Last data entry MSB
+	msb      [32]byte // Last MSB of the 14bit controllers
+}
+
+// NewParameterParser creates a new ParameterParser without selected parameters
+func NewParameterParser() *ParameterParser {
+	p := &ParameterParser{}
+	for i := range p.channels {
+		p.channels[i].rpn = RPNNull
+		p.channels[i].nrpn = RPNNull
+	}
+	return p
+}
+
+// Parse adds the given bytes to the stream and returns all parameter changes that were completed by them
+func (p *ParameterParser) Parse(data []byte) []Parameter {
+	var parameters []Parameter
+
+	for _, message := range p.stream.Parse(data) {
+		if parameter, ok := p.ParseMessage(message); ok {
+			parameters = append(parameters, parameter)
+		}
+	}
+
+	return parameters
+}
+
+// ParseMessage updates the parser state with a single complete midi message.
+// It returns true with the changed parameter if the message completed a parameter change.
+func (p *ParameterParser) ParseMessage(message []byte) (Parameter, bool) {
+	if len(message) != 3 || message[0]&0xf0 != b10110000 {
+		return Parameter{}, false
+	}
+
+	channel := message[0] & b00001111
+	controller := message[1]
+	value := message[2] & b01111111
+	state := &p.channels[channel]
+
+	switch controller {
+	case ControllerRPNMSB:
+		state.rpn = uint16(value)<<7 | state.rpn&b01111111
+		state.select14(ParameterRegistered, state.rpn)
+	case ControllerRPNLSB:
+		state.rpn = state.rpn&^b01111111 | uint16(value)
+		state.select14(ParameterRegistered, state.rpn)
+	case ControllerNRPNMSB:
+		state.nrpn = uint16(value)<<7 | state.nrpn&b01111111
+		state.select14(ParameterNonRegistered, state.nrpn)
+	case ControllerNRPNLSB:
+		state.nrpn = state.nrpn&^b01111111 | uint16(value)
+		state.select14(ParameterNonRegistered, state.nrpn)
+
+	case ControllerDataEntryMSB:
+		if state.selected {
+			state.data = value
+			return state.parameter(channel, uint16(value)<<7, 0), true
+		}
+	case ControllerDataEntryLSB:
+		if state.selected {
+			return state.parameter(channel, uint16(state.data)<<7|uint16(value), 0), true
+		}
+	case ControllerDataIncrement:
+		if state.selected {
+			return state.parameter(channel, 0, 1), true
+		}
+	case ControllerDataDecrement:
+		if state.selected {
+			return state.parameter(channel, 0, -1), true
+		}
+
+	default:
+		if controller < 32 {
+			state.msb[controller] = value
+			return Parameter{
+				Kind:    ParameterController,
+				Channel: channel,
+				Number:  uint16(controller),
+				Value:   uint16(value) << 7,
+			}, true
+		} else if controller < 64 {
+			return Parameter{
+				Kind:    ParameterController,
+				Channel: channel,
+				Number:  uint16(controller - 32),
+				Value:   uint16(state.msb[controller-32])<<7 | uint16(value),
+			}, true
+		}
+	}
+
+	return Parameter{}, false
+}
+
+// select14 makes the given parameter the active one, RPNNull deselects it
+func (s *parameterState) select14(kind ParameterKind, number uint16) {
+	s.kind = kind
+	s.selected = number != RPNNull
+	s.data = 0
+}
+
+// parameter creates a Parameter for the currently selected parameter
+func (s *parameterState) parameter(channel byte, value uint16, step int) Parameter {
+	number := s.rpn
+	if s.kind == ParameterNonRegistered {
+		number = s.nrpn
+	}
+
+	return Parameter{
+		Kind:    s.kind,
+		Channel: channel,
+		Number:  number,
+		Value:   value,
+		Step:    step,
+	}
+}
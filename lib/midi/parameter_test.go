@@ -0,0 +1,68 @@
+package midi
+
+import (
+	"testing"
+)
+
+func TestController14(t *testing.T) {
+	compareBytes(t, Controller14(0, 1, 0x3fff), []byte{0xb0, 1, 127, 0xb0, 33, 127})
+	compareBytes(t, Controller14(2, 7, 0x2001), []byte{0xb2, 7, 64, 0xb2, 39, 1})
+
+	// Only controllers 0 to 31 have a LSB controller
+	compareBytes(t, Controller14(0, 64, 0), []byte{0xb0, 31, 0, 0xb0, 63, 0})
+	compareBytes(t, Controller14(0, 0, 0xffff), []byte{0xb0, 0, 127, 0xb0, 32, 127})
+}
+
+func TestRegisteredParameter(t *testing.T) {
+	compareBytes(t, RegisteredParameter(0, RPNFineTuning, 0x2000), []byte{
+		0xb0, 101, 0, 0xb0, 100, 1, 0xb0, 6, 64, 0xb0, 38, 0,
+	})
+	compareBytes(t, PitchBendRange(1, 12, 50), []byte{
+		0xb1, 101, 0, 0xb1, 100, 0, 0xb1, 6, 12, 0xb1, 38, 50,
+	})
+	compareBytes(t, CoarseTuning(0, 66), []byte{
+		0xb0, 101, 0, 0xb0, 100, 2, 0xb0, 6, 66,
+	})
+	compareBytes(t, RegisteredParameterNull(3), []byte{0xb3, 101, 127, 0xb3, 100, 127})
+	compareBytes(t, NonRegisteredParameter(0, 0x0101, 0x3fff), []byte{
+		0xb0, 99, 2, 0xb0, 98, 1, 0xb0, 6, 127, 0xb0, 38, 127,
+	})
+	compareBytes(t, NonRegisteredParameterDecrement(0, 5), []byte{
+		0xb0, 99, 0, 0xb0, 98, 5, 0xb0, 97, 0,
+	})
+}
+
+func TestParameterParser(t *testing.T) {
+	p := NewParameterParser()
+
+	// Data entry without selected parameter is ignored
+	if parameters := p.Parse(Controller(0, ControllerDataEntryMSB, 3)); len(parameters) != 0 {
+		t.Errorf("Data entry without parameter returned: %v", parameters)
+	}
+
+	stream := append(PitchBendRange(1, 12, 50), DataIncrement(1)...)
+	stream = append(stream, NonRegisteredParameter(2, 0x0101, 0x1234)...)
+	stream = append(stream, Controller14(3, 7, 0x1fff)...)
+	stream = append(stream, RegisteredParameterNull(1)...)
+	stream = append(stream, DataDecrement(1)...)
+
+	parameters := p.Parse(stream)
+	expected := []Parameter{
+		{Kind: ParameterRegistered, Channel: 1, Number: RPNPitchBendRange, Value: 12 << 7},
+		{Kind: ParameterRegistered, Channel: 1, Number: RPNPitchBendRange, Value: 12<<7 | 50},
+		{Kind: ParameterRegistered, Channel: 1, Number: RPNPitchBendRange, Step: 1},
+		{Kind: ParameterNonRegistered, Channel: 2, Number: 0x0101, Value: 0x1200},
+		{Kind: ParameterNonRegistered, Channel: 2, Number: 0x0101, Value: 0x1234},
+		{Kind: ParameterController, Channel: 3, Number: 7, Value: 0x1f80},
+		{Kind: ParameterController, Channel: 3, Number: 7, Value: 0x1fff},
+	}
+
+	if len(parameters) != len(expected) {
+		t.Fatalf("Wrong number of parameters: Got %d, expected %d (%v)", len(parameters), len(expected), parameters)
+	}
+	for i := range expected {
+		if parameters[i] != expected[i] {
+			t.Errorf("Wrong parameter at position %d: Got %+v, expected %+v", i, parameters[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package midi
+
+// Parser splits a stream of midi bytes into complete midi messages.
+// Data can be given in arbitrarily sized chunks, incomplete messages are kept
+// until the next call to Parse. The parser supports running status (channel
+// messages that omit the status byte if it did not change), system exclusive
+// messages and real time messages that are interleaved with other messages.
+//
+// The zero value of Parser is ready to use.
+type Parser struct {
+	status  byte   // Running status, only set for channel messages
+	message []byte // Message that is currently being assembled
+	sysex   bool   // Whether a system exclusive message is being assembled
+}
+
+// Parse adds the given bytes to the stream and returns all messages that were
+// completed by them. Each returned message is a newly allocated byte slice.
+func (p *Parser) Parse(data []byte) [][]byte {
+	var messages [][]byte
+
+	for _, b := range data {
+		switch {
+		case b >= 0xf8:
+			// Real time messages consist of one byte and may appear anywhere, even inside other messages
+			messages = append(messages, []byte{b})
+
+		case b == 0xf0:
+			p.status = 0
+			p.sysex = true
+			p.message = append(p.message[:0], b)
+
+		case b == 0xf7:
+			if p.sysex {
+				p.sysex = false
+				messages = append(messages, p.complete(b))
+			}
+
+		case b&b10000000 != 0:
+			// Any other status byte ends an unterminated system exclusive message, which is dropped
+			p.sysex = false
+			p.message = append(p.message[:0], b)
+
+			if b >= 0xf0 {
+				// System common messages cancel the running status
+				p.status = 0
+			} else {
+				p.status = b
+			}
+
+			if messageLength(b) == 1 {
+				messages = append(messages, p.complete())
+			}
+
+		default:
+			if p.sysex {
+				p.message = append(p.message, b)
+				continue
+			}
+
+			if len(p.message) == 0 {
+				if p.status == 0 {
+					// Data byte without status, ignored
+					continue
+				}
+				p.message = append(p.message, p.status)
+			}
+
+			p.message = append(p.message, b)
+			if len(p.message) == messageLength(p.message[0]) {
+				messages = append(messages, p.complete())
+			}
+		}
+	}
+
+	return messages
+}
+
+// complete returns a copy of the current message with the given bytes appended and starts a new message
+func (p *Parser) complete(suffix ...byte) []byte {
+	message := make([]byte, len(p.message), len(p.message)+len(suffix))
+	copy(message, p.message)
+	message = append(message, suffix...)
+
+	p.message = p.message[:0]
+	return message
+}
+
+// messageLength returns the length of a midi message including the status byte.
+// System exclusive messages do not have a fixed length, 0 is returned for them.
+func messageLength(status byte) int {
+	switch status & 0xf0 {
+	case b10000000, b10010000, 0xa0, b10110000, b11100000:
+		return 3
+	case b11000000, 0xd0:
+		return 2
+	}
+
+	switch status {
+	case 0xf0:
+		return 0
+	case 0xf2:
+		return 3
+	case 0xf1, 0xf3:
+		return 2
+	}
+
+	return 1
+}
@@ -0,0 +1,33 @@
+package midi
+
+import (
+	"testing"
+)
+
+func TestParser(t *testing.T) {
+	p := Parser{}
+
+	// Running status, split over several calls and interleaved with a clock message
+	messages := p.Parse([]byte{0x90, 60})
+	if len(messages) != 0 {
+		t.Errorf("Incomplete message returned: %v", messages)
+	}
+
+	messages = p.Parse([]byte{100, 62, 0xf8, 101, 0xc1, 5, 0xf0, 0x7e, 0x01, 0xf7, 64})
+	if len(messages) != 5 {
+		t.Fatalf("Wrong number of messages: Got %d, expected 5", len(messages))
+	}
+	compareBytes(t, messages[0], []byte{0x90, 60, 100})
+	compareBytes(t, messages[1], []byte{0xf8})
+	compareBytes(t, messages[2], []byte{0x90, 62, 101})
+	compareBytes(t, messages[3], []byte{0xc1, 5})
+	compareBytes(t, messages[4], []byte{0xf0, 0x7e, 0x01, 0xf7})
+
+	// The system exclusive message cancelled the running status, 64 must be ignored
+	messages = p.Parse([]byte{0xb0, 7, 127, 10, 64})
+	if len(messages) != 2 {
+		t.Fatalf("Wrong number of messages: Got %d, expected 2", len(messages))
+	}
+	compareBytes(t, messages[0], []byte{0xb0, 7, 127})
+	compareBytes(t, messages[1], []byte{0xb0, 10, 64})
+}
@@ -0,0 +1,661 @@
+package midi
+
+import (
+	"encoding/binary"
+)
+
+// Universal MIDI Packets (UMP) are the message format of MIDI 2.0. Every packet consists of 1 to 4
+// 32bit words, the first 4 bits of the first word contain the message type which determines the
+// size of the packet, the next 4 bits contain the group (one of 16 virtual midi cables).
+//
+// Packets used by this package (hexadecimal notation, one digit = 4 bits):
+//  Utility:          0gsttttt                            (g = group, s = status, t = time stamp) -> g is always 0
+//  System:           1gssxxyy                            (s = midi 1.0 status byte, x, y = data bytes)
+//  MIDI 1.0 Voice:   2gssxxyy                            (s = midi 1.0 status byte, x, y = data bytes)
+//  SysEx7:           3gsnxxxx xxxxxxxx                   (s = status, n = number of bytes, x = up to 6 data bytes)
+//  MIDI 2.0 Voice:   4gocxxyy dddddddd                   (o = opcode, c = channel, x, y = index, d = 32bit data)
+//  SysEx8:           5gsniixx xxxxxxxx xxxxxxxx xxxxxxxx (s = status, n = number of bytes, i = stream, x = up to 13 bytes)
+//
+// The opcodes of the MIDI 2.0 channel voice messages are the same as the upper 4 bits of the
+// midi 1.0 status bytes, with additional opcodes below 0x8 for registered (RPN) and assignable
+// (NRPN) controllers.
+
+// Packet is a Universal MIDI Packet consisting of 1 to 4 32bit words
+type Packet []uint32
+
+// UMP message types
+const (
+	UMPUtility           byte = 0x0
+	UMPSystem            byte = 0x1
+	UMPMIDI1ChannelVoice byte = 0x2
+	UMPData64            byte = 0x3
+	UMPMIDI2ChannelVoice byte = 0x4
+	UMPData128           byte = 0x5
+)
+
+// Statuses of utility messages
+const (
+	UtilityNoOp        byte = 0x0
+	UtilityJRClock     byte = 0x1
+	UtilityJRTimestamp byte = 0x2
+)
+
+// Statuses of SysEx7 and SysEx8 packets, a message that does not fit into a single packet is split
+// into a start packet, any number of continue packets and an end packet
+const (
+	SysExComplete byte = 0x0
+	SysExStart    byte = 0x1
+	SysExContinue byte = 0x2
+	SysExEnd      byte = 0x3
+)
+
+// Opcodes of MIDI 2.0 channel voice messages that do not exist in midi 1.0
+const (
+	OpcodeRegisteredController         byte = 0x2
+	OpcodeAssignableController         byte = 0x3
+	OpcodeRelativeRegisteredController byte = 0x4
+	OpcodeRelativeAssignableController byte = 0x5
+)
+
+// PacketSize returns the number of 32bit words of a packet with the given message type
+func PacketSize(messageType byte) int {
+	switch messageType & 0xf {
+	case 0x0, 0x1, 0x2, 0x6, 0x7:
+		return 1
+	case 0x3, 0x4, 0x8, 0x9, 0xa:
+		return 2
+	case 0xb, 0xc:
+		return 3
+	}
+	return 4
+}
+
+// SplitPackets splits a stream of 32bit words into packets. An incomplete packet at the end is dropped.
+func SplitPackets(words []uint32) []Packet {
+	var packets []Packet
+
+	for len(words) > 0 {
+		size := PacketSize(byte(words[0] >> 28))
+		if size > len(words) {
+			break
+		}
+		packets = append(packets, Packet(words[:size:size]))
+		words = words[size:]
+	}
+
+	return packets
+}
+
+// Type returns the message type of the packet (one of the UMP* constants)
+func (p Packet) Type() byte {
+	if len(p) == 0 {
+		return UMPUtility
+	}
+	return byte(p[0] >> 28)
+}
+
+// Group returns the group (0 to 15) of the packet
+func (p Packet) Group() byte {
+	if len(p) == 0 {
+		return 0
+	}
+	return byte(p[0]>>24) & b00001111
+}
+
+// Status returns the status of the packet, for channel voice messages it is the status byte
+// containing opcode and channel, for all other messages only the lower 4 bits are used.
+func (p Packet) Status() byte {
+	if len(p) == 0 {
+		return 0
+	}
+	if t := p.Type(); t == UMPSystem || t == UMPMIDI1ChannelVoice || t == UMPMIDI2ChannelVoice {
+		return byte(p[0] >> 16)
+	}
+	return byte(p[0]>>20) & b00001111
+}
+
+// Bytes returns the packet in big endian byte order as it is sent over the wire
+func (p Packet) Bytes() []byte {
+	data := make([]byte, 4*len(p))
+	for i, word := range p {
+		binary.BigEndian.PutUint32(data[4*i:], word)
+	}
+	return data
+}
+
+// NoOp creates a utility message without function
+func NoOp() Packet {
+	return Packet{umpWord(UMPUtility, 0, UtilityNoOp<<4, 0, 0)}
+}
+
+// JRClock creates a jitter reduction clock message containing the sender's time in units of 32µs
+func JRClock(time uint16) Packet {
+	return Packet{umpWord(UMPUtility, 0, UtilityJRClock<<4, byte(time>>8), byte(time))}
+}
+
+// JRTimestamp creates a jitter reduction timestamp for the following message in units of 32µs
+func JRTimestamp(time uint16) Packet {
+	return Packet{umpWord(UMPUtility, 0, UtilityJRTimestamp<<4, byte(time>>8), byte(time))}
+}
+
+// SystemPacket wraps a midi 1.0 system common or real time message into a packet
+func SystemPacket(group byte, message []byte) Packet {
+	status, data1, data2 := messageBytes(message)
+	return Packet{umpWord(UMPSystem, group, status, data1, data2)}
+}
+
+// MIDI1Packet wraps a midi 1.0 channel voice message into a packet without changing it
+func MIDI1Packet(group byte, message []byte) Packet {
+	status, data1, data2 := messageBytes(message)
+	return Packet{umpWord(UMPMIDI1ChannelVoice, group, status, data1, data2)}
+}
+
+// MIDI2NoteOn starts playing a note with a 16bit velocity. The attribute type and attribute can be
+// used for additional per note data (for example 0x3 = pitch 7.9), set both to 0 if not needed.
+func MIDI2NoteOn(group, channel, note byte, velocity uint16, attributeType byte, attribute uint16) Packet {
+	return midi2Voice(group, b10010000, channel, note, attributeType, uint32(velocity)<<16|uint32(attribute))
+}
+
+// MIDI2NoteOff stops playing a note, the velocity has 16bit.
+// See MIDI2NoteOn for attribute type and attribute.
+func MIDI2NoteOff(group, channel, note byte, velocity uint16, attributeType byte, attribute uint16) Packet {
+	return midi2Voice(group, b10000000, channel, note, attributeType, uint32(velocity)<<16|uint32(attribute))
+}
+
+// MIDI2PolyPressure sets the 32bit pressure (aftertouch) value of a single note
+func MIDI2PolyPressure(group, channel, note byte, value uint32) Packet {
+	return midi2Voice(group, 0xa0, channel, note, 0, value)
+}
+
+// MIDI2Controller sets one of the 128 controllers to a 32bit value.
+// See Controller for a list of common controller numbers.
+func MIDI2Controller(group, channel, controller byte, value uint32) Packet {
+	return midi2Voice(group, b10110000, channel, controller, 0, value)
+}
+
+// MIDI2ProgramChange changes the instrument without changing the bank
+func MIDI2ProgramChange(group, channel, program byte) Packet {
+	return midi2Voice(group, b11000000, channel, 0, 0, uint32(program&b01111111)<<24)
+}
+
+// MIDI2ProgramChangeBank changes the instrument and the 14bit bank in a single message
+func MIDI2ProgramChangeBank(group, channel, program byte, bank uint16) Packet {
+	if bank > b0011111111111111 {
+		bank = b0011111111111111
+	}
+	return midi2Voice(group, b11000000, channel, 0, 1, uint32(program&b01111111)<<24|uint32(bank>>7)<<8|uint32(bank&b01111111))
+}
+
+// MIDI2ChannelPressure sets the 32bit pressure (aftertouch) value of the whole channel
+func MIDI2ChannelPressure(group, channel byte, value uint32) Packet {
+	return midi2Voice(group, 0xd0, channel, 0, 0, value)
+}
+
+// MIDI2BendPitch bends the pitch of the whole channel by the given 32bit value
+// with 0x80000000 being the middle value meaning no change in pitch
+func MIDI2BendPitch(group, channel byte, value uint32) Packet {
+	return midi2Voice(group, b11100000, channel, 0, 0, value)
+}
+
+// MIDI2RegisteredController sets the registered parameter (RPN) to a 32bit value.
+// In midi 2.0 the parameter is set in a single message instead of four controller messages.
+func MIDI2RegisteredController(group, channel byte, parameter uint16, value uint32) Packet {
+	return midi2Parameter(group, OpcodeRegisteredController, channel, parameter, value)
+}
+
+// MIDI2AssignableController sets the assignable (non-registered) parameter (NRPN) to a 32bit value
+func MIDI2AssignableController(group, channel byte, parameter uint16, value uint32) Packet {
+	return midi2Parameter(group, OpcodeAssignableController, channel, parameter, value)
+}
+
+// MIDI2RelativeRegisteredController changes the registered parameter (RPN) by the given amount
+func MIDI2RelativeRegisteredController(group, channel byte, parameter uint16, delta int32) Packet {
+	return midi2Parameter(group, OpcodeRelativeRegisteredController, channel, parameter, uint32(delta))
+}
+
+// MIDI2RelativeAssignableController changes the assignable parameter (NRPN) by the given amount
+func MIDI2RelativeAssignableController(group, channel byte, parameter uint16, delta int32) Packet {
+	return midi2Parameter(group, OpcodeRelativeAssignableController, channel, parameter, uint32(delta))
+}
+
+// SysEx7 splits the given system exclusive data (without the surrounding 0xf0 and 0xf7 bytes)
+// into 64bit packets with up to 6 data bytes each
+func SysEx7(group byte, data []byte) []Packet {
+	var packets []Packet
+
+	for _, chunk := range sysExChunks(data, 6) {
+		var bytes [8]byte
+		bytes[0] = UMPData64<<4 | clampGroup(group)
+		bytes[1] = chunk.status<<4 | byte(len(chunk.data))
+		for i, b := range chunk.data {
+			bytes[2+i] = b & b01111111
+		}
+
+		packets = append(packets, Packet{
+			binary.BigEndian.Uint32(bytes[0:4]),
+			binary.BigEndian.Uint32(bytes[4:8]),
+		})
+	}
+
+	return packets
+}
+
+// SysEx8 splits the given 8bit system exclusive data into 128bit packets with up to 13 data bytes each.
+// The stream ID allows sending several system exclusive messages at the same time.
+func SysEx8(group, stream byte, data []byte) []Packet {
+	var packets []Packet
+
+	for _, chunk := range sysExChunks(data, 13) {
+		var bytes [16]byte
+		bytes[0] = UMPData128<<4 | clampGroup(group)
+		// The stream ID is counted as one of the bytes
+		bytes[1] = chunk.status<<4 | byte(len(chunk.data)+1)
+		bytes[2] = stream
+		copy(bytes[3:], chunk.data)
+
+		packets = append(packets, Packet{
+			binary.BigEndian.Uint32(bytes[0:4]),
+			binary.BigEndian.Uint32(bytes[4:8]),
+			binary.BigEndian.Uint32(bytes[8:12]),
+			binary.BigEndian.Uint32(bytes[12:16]),
+		})
+	}
+
+	return packets
+}
+
+// SysExData returns the status and the data bytes of a SysEx7 or SysEx8 packet.
+// The stream ID of SysEx8 packets is not part of the data.
+func SysExData(p Packet) (status byte, data []byte) {
+	bytes := p.Bytes()
+	if len(bytes) < 8 {
+		return 0, nil
+	}
+
+	status = bytes[1] >> 4
+	count := int(bytes[1] & b00001111)
+
+	switch p.Type() {
+	case UMPData64:
+		if count > 6 {
+			count = 6
+		}
+		return status, bytes[2 : 2+count]
+	case UMPData128:
+		if len(bytes) < 16 || count < 1 {
+			return status, nil
+		}
+		if count > 14 {
+			count = 14
+		}
+		return status, bytes[3 : 2+count]
+	}
+
+	return 0, nil
+}
+
+// ScaleUp increases the resolution of a value from srcBits to dstBits using the min-center-max
+// algorithm of the midi 2.0 specification: The minimum, center and maximum values are kept
+// and values above the center are expanded by repeating their lower bits.
+func ScaleUp(value uint32, srcBits, dstBits uint) uint32 {
+	if srcBits >= dstBits {
+		return ScaleDown(value, srcBits, dstBits)
+	}
+
+	scaleBits := dstBits - srcBits
+	shifted := value << scaleBits
+	center := uint32(1) << (srcBits - 1)
+	if value <= center {
+		return shifted
+	}
+
+	repeatBits := srcBits - 1
+	repeat := value & (1<<repeatBits - 1)
+	if scaleBits > repeatBits {
+		repeat <<= scaleBits - repeatBits
+	} else {
+		repeat >>= repeatBits - scaleBits
+	}
+
+	for repeat != 0 {
+		shifted |= repeat
+		repeat >>= repeatBits
+	}
+
+	return shifted
+}
+
+// ScaleDown decreases the resolution of a value from srcBits to dstBits by dropping the lower bits
+func ScaleDown(value uint32, srcBits, dstBits uint) uint32 {
+	if srcBits <= dstBits {
+		return value
+	}
+	return value >> (srcBits - dstBits)
+}
+
+// Translator translates between midi 1.0 messages and Universal MIDI Packets following the
+// translation rules of the midi 2.0 specification.
+//
+// Translation from midi 1.0 to MIDI 2.0 channel voice messages needs state, since a registered
+// parameter change or a program change with bank select consists of several midi 1.0 messages.
+// The bank select and parameter selection controllers are not translated themselves. System
+// exclusive messages in packets are collected until the end packet has been translated.
+type Translator struct {
+	// Group is used for all packets created from midi 1.0 messages
+	Group byte
+
+	parameters *ParameterParser
+	banks      [16]bank
+	sysex      []byte
+}
+
+type bank struct {
+	msb, lsb byte
+	valid    bool
+}
+
+// NewTranslator creates a Translator that creates packets in the given group
+func NewTranslator(group byte) *Translator {
+	return &Translator{
+		Group:      group,
+		parameters: NewParameterParser(),
+	}
+}
+
+// ToUMP translates a complete midi 1.0 message (as returned by Parser) into packets. Channel voice
+// messages are translated into MIDI 2.0 channel voice messages, system exclusive messages into
+// SysEx7 packets and all other system messages into system packets.
+// No packets are returned for messages that only change the state of the translator.
+func (t *Translator) ToUMP(message []byte) []Packet {
+	if len(message) == 0 {
+		return nil
+	}
+
+	status := message[0]
+	switch {
+	case status == 0xf0:
+		data := message[1:]
+		if len(data) > 0 && data[len(data)-1] == 0xf7 {
+			data = data[:len(data)-1]
+		}
+		return SysEx7(t.Group, data)
+	case status >= 0xf0:
+		return []Packet{SystemPacket(t.Group, message)}
+	case len(message) < messageLength(status):
+		return nil
+	}
+
+	channel := status & b00001111
+	switch status & 0xf0 {
+	case b10000000:
+		return []Packet{MIDI2NoteOff(t.Group, channel, message[1], uint16(ScaleUp(uint32(message[2]), 7, 16)), 0, 0)}
+
+	case b10010000:
+		if message[2] == 0 {
+			// A note on with velocity 0 is a note off with the default velocity
+			return []Packet{MIDI2NoteOff(t.Group, channel, message[1], uint16(ScaleUp(0x40, 7, 16)), 0, 0)}
+		}
+		return []Packet{MIDI2NoteOn(t.Group, channel, message[1], uint16(ScaleUp(uint32(message[2]), 7, 16)), 0, 0)}
+
+	case 0xa0:
+		return []Packet{MIDI2PolyPressure(t.Group, channel, message[1], ScaleUp(uint32(message[2]), 7, 32))}
+
+	case b10110000:
+		return t.controllerToUMP(message)
+
+	case b11000000:
+		b := t.banks[channel]
+		if b.valid {
+			return []Packet{MIDI2ProgramChangeBank(t.Group, channel, message[1], uint16(b.msb)<<7|uint16(b.lsb))}
+		}
+		return []Packet{MIDI2ProgramChange(t.Group, channel, message[1])}
+
+	case 0xd0:
+		return []Packet{MIDI2ChannelPressure(t.Group, channel, ScaleUp(uint32(message[1]), 7, 32))}
+
+	case b11100000:
+		// The first data byte of pitch bend messages contains the LSB
+		value := uint32(message[2])<<7 | uint32(message[1])
+		return []Packet{MIDI2BendPitch(t.Group, channel, ScaleUp(value, 14, 32))}
+	}
+
+	return nil
+}
+
+func (t *Translator) controllerToUMP(message []byte) []Packet {
+	channel := message[0] & b00001111
+	controller := message[1]
+	value := message[2] & b01111111
+
+	switch controller {
+	case 0:
+		t.banks[channel].msb = value
+		t.banks[channel].valid = true
+		return nil
+	case 32:
+		t.banks[channel].lsb = value
+		t.banks[channel].valid = true
+		return nil
+	case ControllerRPNMSB, ControllerRPNLSB, ControllerNRPNMSB, ControllerNRPNLSB:
+		t.parameters.ParseMessage(message)
+		return nil
+	case ControllerDataEntryMSB, ControllerDataEntryLSB, ControllerDataIncrement, ControllerDataDecrement:
+		parameter, ok := t.parameters.ParseMessage(message)
+		if !ok {
+			break
+		}
+
+		opcode := OpcodeRegisteredController
+		if parameter.Kind == ParameterNonRegistered {
+			opcode = OpcodeAssignableController
+		}
+		if parameter.Step != 0 {
+			return []Packet{midi2Parameter(t.Group, opcode+2, channel, parameter.Number, uint32(int32(parameter.Step)))}
+		}
+		return []Packet{midi2Parameter(t.Group, opcode, channel, parameter.Number, ScaleUp(uint32(parameter.Value), 14, 32))}
+	}
+
+	return []Packet{MIDI2Controller(t.Group, channel, controller, ScaleUp(uint32(value), 7, 32))}
+}
+
+// ToMIDI1 translates a packet into midi 1.0 messages. MIDI 2.0 channel voice messages are scaled
+// down, a program change with bank and registered or assignable controllers result in several
+// midi 1.0 messages. Utility messages, SysEx8 packets and MIDI 2.0 messages without a midi 1.0
+// equivalent (per note controllers) are not translated.
+func (t *Translator) ToMIDI1(p Packet) [][]byte {
+	if len(p) < PacketSize(p.Type()) {
+		return nil
+	}
+
+	switch p.Type() {
+	case UMPSystem:
+		// SysEx is sent in data packets, everything below is a channel message
+		status := p.Status()
+		if status <= 0xf0 || status == 0xf7 {
+			return nil
+		}
+		message := []byte{status, byte(p[0]>>8) & b01111111, byte(p[0]) & b01111111}
+		return [][]byte{message[:messageLength(status)]}
+
+	case UMPMIDI1ChannelVoice:
+		status := p.Status()
+		if status < b10000000 || status >= 0xf0 {
+			return nil
+		}
+		message := []byte{status, byte(p[0]>>8) & b01111111, byte(p[0]) & b01111111}
+		return [][]byte{message[:messageLength(status)]}
+
+	case UMPData64:
+		status, data := SysExData(p)
+		switch status {
+		case SysExComplete:
+			return [][]byte{append(append([]byte{0xf0}, data...), 0xf7)}
+		case SysExStart:
+			t.sysex = append(append(t.sysex[:0], 0xf0), data...)
+		case SysExContinue:
+			if len(t.sysex) > 0 {
+				t.sysex = append(t.sysex, data...)
+			}
+		case SysExEnd:
+			if len(t.sysex) > 0 {
+				message := append(t.sysex, data...)
+				message = append(message, 0xf7)
+				t.sysex = nil
+				return [][]byte{message}
+			}
+		}
+		return nil
+
+	case UMPMIDI2ChannelVoice:
+		return midi2ToMIDI1(p)
+	}
+
+	return nil
+}
+
+func midi2ToMIDI1(p Packet) [][]byte {
+	opcode := p.Status() >> 4
+	channel := p.Status() & b00001111
+	index := byte(p[0]>>8) & b01111111
+	index2 := byte(p[0]) & b01111111
+	data := p[1]
+
+	switch opcode {
+	case 0x8:
+		return [][]byte{NoteOff(channel, index, byte(ScaleDown(data>>16, 16, 7)))}
+
+	case 0x9:
+		velocity := byte(ScaleDown(data>>16, 16, 7))
+		if velocity == 0 {
+			// Velocity 0 would be a note off in midi 1.0
+			velocity = 1
+		}
+		return [][]byte{NoteOn(channel, index, velocity)}
+
+	case 0xa:
+		return [][]byte{{0xa0 | channel, index, byte(ScaleDown(data, 32, 7))}}
+
+	case 0xb:
+		return [][]byte{Controller(channel, index, byte(ScaleDown(data, 32, 7)))}
+
+	case 0xc:
+		var messages [][]byte
+		if p[0]&1 == 1 {
+			messages = append(messages,
+				Controller(channel, 0, byte(data>>8)&b01111111),
+				Controller(channel, 32, byte(data)&b01111111),
+			)
+		}
+		return append(messages, ProgramChange(channel, byte(data>>24)&b01111111))
+
+	case 0xd:
+		return [][]byte{{0xd0 | channel, byte(ScaleDown(data, 32, 7))}}
+
+	case 0xe:
+		// Pitch bend messages are sent LSB first on the wire
+		value := uint16(ScaleDown(data, 32, 14))
+		return [][]byte{{b11100000 | channel, byte(value & b01111111), byte(value >> 7)}}
+
+	case OpcodeRegisteredController, OpcodeAssignableController:
+		parameter := uint16(index)<<7 | uint16(index2)
+		value := uint16(ScaleDown(data, 32, 14))
+		if opcode == OpcodeRegisteredController {
+			return splitMessages(RegisteredParameter(channel, parameter, value))
+		}
+		return splitMessages(NonRegisteredParameter(channel, parameter, value))
+
+	case OpcodeRelativeRegisteredController, OpcodeRelativeAssignableController:
+		parameter := uint16(index)<<7 | uint16(index2)
+		delta := int32(data)
+		switch {
+		case opcode == OpcodeRelativeRegisteredController && delta > 0:
+			return splitMessages(RegisteredParameterIncrement(channel, parameter))
+		case opcode == OpcodeRelativeRegisteredController && delta < 0:
+			return splitMessages(RegisteredParameterDecrement(channel, parameter))
+		case delta > 0:
+			return splitMessages(NonRegisteredParameterIncrement(channel, parameter))
+		case delta < 0:
+			return splitMessages(NonRegisteredParameterDecrement(channel, parameter))
+		}
+	}
+
+	return nil
+}
+
+// splitMessages splits a byte slice of concatenated 3 byte controller messages
+func splitMessages(data []byte) [][]byte {
+	var messages [][]byte
+	for i := 0; i+3 <= len(data); i += 3 {
+		messages = append(messages, data[i:i+3:i+3])
+	}
+	return messages
+}
+
+type sysExChunk struct {
+	status byte
+	data   []byte
+}
+
+// sysExChunks splits data into chunks with the given size and their SysEx* status
+func sysExChunks(data []byte, size int) []sysExChunk {
+	if len(data) <= size {
+		return []sysExChunk{{SysExComplete, data}}
+	}
+
+	var chunks []sysExChunk
+	for start := 0; start < len(data); start += size {
+		end := start + size
+		status := SysExContinue
+		if start == 0 {
+			status = SysExStart
+		}
+		if end >= len(data) {
+			end = len(data)
+			status = SysExEnd
+		}
+		chunks = append(chunks, sysExChunk{status, data[start:end]})
+	}
+	return chunks
+}
+
+// messageBytes returns the first three bytes of a midi 1.0 message, missing bytes are 0
+func messageBytes(message []byte) (status, data1, data2 byte) {
+	if len(message) > 0 {
+		status = message[0]
+	}
+	if len(message) > 1 {
+		data1 = message[1] & b01111111
+	}
+	if len(message) > 2 {
+		data2 = message[2] & b01111111
+	}
+	return
+}
+
+func midi2Voice(group, opcode, channel, index1, index2 byte, data uint32) Packet {
+	if channel > b00001111 {
+		channel = b00001111
+	}
+	return Packet{
+		umpWord(UMPMIDI2ChannelVoice, group, opcode&0xf0|channel, index1&b01111111, index2),
+		data,
+	}
+}
+
+func midi2Parameter(group, opcode, channel byte, parameter uint16, value uint32) Packet {
+	if parameter > b0011111111111111 {
+		parameter = b0011111111111111
+	}
+	return midi2Voice(group, opcode<<4, channel, byte(parameter>>7), byte(parameter&b01111111), value)
+}
+
+func umpWord(messageType, group, status, data1, data2 byte) uint32 {
+	return uint32(messageType&b00001111)<<28 | uint32(clampGroup(group))<<24 | uint32(status)<<16 | uint32(data1)<<8 | uint32(data2)
+}
+
+func clampGroup(group byte) byte {
+	if group > b00001111 {
+		return b00001111
+	}
+	return group
+}
@@ -0,0 +1,154 @@
+package midi
+
+import (
+	"testing"
+)
+
+func comparePackets(t *testing.T, got, want []Packet) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("Wrong number of packets: Got %08x, expected %08x", got, want)
+	}
+
+	for i := range want {
+		if len(got[i]) != len(want[i]) {
+			t.Errorf("Wrong packet size at position %d: Got %08x, expected %08x", i, got[i], want[i])
+			continue
+		}
+		for j := range want[i] {
+			if got[i][j] != want[i][j] {
+				t.Errorf("Wrong packet at position %d: Got %08x, expected %08x", i, got[i], want[i])
+				break
+			}
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		value, srcBits, dstBits, want uint32
+	}{
+		{0x00, 7, 16, 0x0000},
+		{0x40, 7, 16, 0x8000},
+		{0x7f, 7, 16, 0xffff},
+		{0x7f, 7, 32, 0xffffffff},
+		{0x2000, 14, 32, 0x80000000},
+		{0x3fff, 14, 32, 0xffffffff},
+	}
+
+	for _, test := range tests {
+		got := ScaleUp(test.value, uint(test.srcBits), uint(test.dstBits))
+		if got != test.want {
+			t.Errorf("ScaleUp(%#x, %d, %d): Got %#x, expected %#x", test.value, test.srcBits, test.dstBits, got, test.want)
+		}
+		if back := ScaleDown(got, uint(test.dstBits), uint(test.srcBits)); back != test.value {
+			t.Errorf("ScaleDown(%#x, %d, %d): Got %#x, expected %#x", got, test.dstBits, test.srcBits, back, test.value)
+		}
+	}
+}
+
+func TestPackets(t *testing.T) {
+	comparePackets(t, []Packet{
+		NoOp(),
+		JRTimestamp(0x1234),
+		MIDI1Packet(1, NoteOn(2, 60, 100)),
+		SystemPacket(0, []byte{0xf8}),
+		MIDI2NoteOn(3, 1, 60, 0xc000, 0, 0),
+		MIDI2ProgramChangeBank(0, 0, 5, 0x0081),
+		MIDI2RegisteredController(0, 2, RPNFineTuning, 0x80000000),
+	}, []Packet{
+		{0x00000000},
+		{0x00201234},
+		{0x21923c64},
+		{0x10f80000},
+		{0x43913c00, 0xc0000000},
+		{0x40c00001, 0x05000101},
+		{0x40220001, 0x80000000},
+	})
+
+	packet := MIDI2NoteOn(3, 1, 60, 0xc000, 0, 0)
+	if packet.Type() != UMPMIDI2ChannelVoice || packet.Group() != 3 || packet.Status() != 0x91 {
+		t.Errorf("Wrong packet fields: type %d, group %d, status %#x", packet.Type(), packet.Group(), packet.Status())
+	}
+	compareBytes(t, packet.Bytes(), []byte{0x43, 0x91, 0x3c, 0x00, 0xc0, 0x00, 0x00, 0x00})
+
+	split := SplitPackets([]uint32{0x00000000, 0x43913c00, 0xc0000000, 0x21923c64, 0x40000000})
+	comparePackets(t, split, []Packet{{0x00000000}, {0x43913c00, 0xc0000000}, {0x21923c64}})
+}
+
+func TestSysEx(t *testing.T) {
+	comparePackets(t, SysEx7(0, []byte{1, 2, 3}), []Packet{{0x30030102, 0x03000000}})
+	comparePackets(t, SysEx7(2, []byte{1, 2, 3, 4, 5, 6, 7, 8}), []Packet{
+		{0x32160102, 0x03040506},
+		{0x32320708, 0x00000000},
+	})
+	comparePackets(t, SysEx8(0, 7, []byte{0xff, 0x80}), []Packet{{0x500307ff, 0x80000000, 0, 0}})
+
+	status, data := SysExData(SysEx8(0, 7, []byte{0xff, 0x80})[0])
+	if status != SysExComplete {
+		t.Errorf("Wrong status: Got %d, expected %d", status, SysExComplete)
+	}
+	compareBytes(t, data, []byte{0xff, 0x80})
+}
+
+func TestTranslator(t *testing.T) {
+	tr := NewTranslator(1)
+
+	stream := NoteOn(0, 60, 0x40)
+	stream = append(stream, NoteOn(0, 60, 0)...)
+	stream = append(stream, Controller(1, 0, 1)...)
+	stream = append(stream, Controller(1, 32, 2)...)
+	stream = append(stream, ProgramChange(1, 10)...)
+	stream = append(stream, PitchBendRange(2, 12, 0)...)
+	stream = append(stream, 0xe3, 0x00, 0x40)
+	stream = append(stream, 0xf0, 1, 2, 3, 0xf7)
+
+	var packets []Packet
+	p := Parser{}
+	for _, message := range p.Parse(stream) {
+		packets = append(packets, tr.ToUMP(message)...)
+	}
+
+	comparePackets(t, packets, []Packet{
+		{0x41903c00, 0x80000000},
+		{0x41803c00, 0x80000000},
+		{0x41c10001, 0x0a000102},
+		{0x41220000, 0x18000000},
+		{0x41220000, 0x18000000},
+		{0x41e30000, 0x80000000},
+		{0x31030102, 0x03000000},
+	})
+
+	var messages [][]byte
+	for _, packet := range packets {
+		messages = append(messages, tr.ToMIDI1(packet)...)
+	}
+
+	want := [][]byte{
+		NoteOn(0, 60, 0x40),
+		NoteOff(0, 60, 0x40),
+		Controller(1, 0, 1),
+		Controller(1, 32, 2),
+		ProgramChange(1, 10),
+	}
+	for i := 0; i < 2; i++ {
+		want = append(want, splitMessages(PitchBendRange(2, 12, 0))...)
+	}
+	want = append(want, []byte{0xe3, 0x00, 0x40}, []byte{0xf0, 1, 2, 3, 0xf7})
+
+	if len(messages) != len(want) {
+		t.Fatalf("Wrong number of messages: Got %d, expected %d", len(messages), len(want))
+	}
+	for i := range want {
+		compareBytes(t, messages[i], want[i])
+	}
+
+	// System packets only contain system common and realtime messages
+	compareBytes(t, tr.ToMIDI1(Packet{0x10f80000})[0], []byte{0xf8})
+	compareBytes(t, tr.ToMIDI1(Packet{0x10f23412})[0], []byte{0xf2, 0x34, 0x12})
+	for _, packet := range []Packet{{0x10f00000}, {0x10f70000}, {0x10903c40}, {0x10000000}} {
+		if messages := tr.ToMIDI1(packet); messages != nil {
+			t.Errorf("Invalid system packet %08x translated: %x", packet, messages)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Package midiport opens midi ports by name, so programs can be configured to use raw midi
+// devices as well as ALSA sequencer ports. All ports are byte streams of midi messages.
+//
+// Port names:
+//
+//	/dev/snd/midiC1D0  - raw midi device (any path starting with "/")
+//	seq:20:0           - sequencer port with the given address
+//	seq:Launchpad      - sequencer port whose client or port name contains the given text
+//	virtual:Synth In   - new virtual sequencer port with the given name that other programs can connect to
+package midiport
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// Prefixes of the port names
+const (
+	PrefixSequencer = "seq:"
+	PrefixVirtual   = "virtual:"
+)
+
+// Open opens the midi port with the given name, see the package documentation for supported names
+func Open(name string) (io.ReadWriteCloser, error) {
+	switch {
+	case strings.HasPrefix(name, PrefixSequencer):
+		return openSequencer(strings.TrimPrefix(name, PrefixSequencer))
+	case strings.HasPrefix(name, PrefixVirtual):
+		return openVirtual(strings.TrimPrefix(name, PrefixVirtual))
+	}
+
+	return os.OpenFile(name, os.O_RDWR, os.ModePerm)
+}
+
+// Pipe creates two connected in-memory ports: Bytes written to one of them can be read from the other one.
+// Pipes can be used instead of real ports in tests.
+func Pipe() (io.ReadWriteCloser, io.ReadWriteCloser) {
+	aReader, bWriter := io.Pipe()
+	bReader, aWriter := io.Pipe()
+
+	return &pipe{aReader, aWriter}, &pipe{bReader, bWriter}
+}
+
+type pipe struct {
+	*io.PipeReader
+	*io.PipeWriter
+}
+
+// Close closes both directions of the pipe. Reading from the other end returns io.EOF afterwards.
+func (p *pipe) Close() error {
+	p.PipeReader.Close()
+	return p.PipeWriter.Close()
+}
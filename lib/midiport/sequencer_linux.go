@@ -0,0 +1,80 @@
+package midiport
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirion/gomidi/lib/alsaseq"
+)
+
+var (
+	sequencer      *alsaseq.Sequencer
+	sequencerError error
+	sequencerOnce  sync.Once
+
+	sequencerErrors = make(chan error)
+)
+
+// getSequencer opens the sequencer client shared by all ports of this program
+func getSequencer() (*alsaseq.Sequencer, error) {
+	sequencerOnce.Do(func() {
+		sequencer, sequencerError = alsaseq.Open(filepath.Base(os.Args[0]))
+		if sequencerError == nil {
+			go forwardErrors(sequencer.Errors())
+		}
+	})
+	return sequencer, sequencerError
+}
+
+// forwardErrors passes the errors of the sequencer to Errors. The sequencer drops errors while nobody receives them.
+func forwardErrors(errs <-chan error) {
+	for err := range errs {
+		sequencerErrors <- err
+	}
+}
+
+// Errors returns the errors that happen while sequencer ports receive events, see alsaseq.Sequencer.Errors.
+// Raw midi devices return their errors from Read and Write.
+func Errors() <-chan error {
+	return sequencerErrors
+}
+
+// openSequencer creates a port that is connected in both directions to the given sequencer port
+func openSequencer(name string) (io.ReadWriteCloser, error) {
+	seq, err := getSequencer()
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := seq.FindPort(name)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := seq.CreatePort(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ports may only support one direction, so errors are ignored as long as one connection works
+	errFrom := port.ConnectFrom(address)
+	errTo := port.ConnectTo(address)
+	if errFrom != nil && errTo != nil {
+		port.Close()
+		return nil, errFrom
+	}
+
+	return port, nil
+}
+
+// openVirtual creates a port that other programs can connect to
+func openVirtual(name string) (io.ReadWriteCloser, error) {
+	seq, err := getSequencer()
+	if err != nil {
+		return nil, err
+	}
+
+	return seq.CreatePort(name)
+}
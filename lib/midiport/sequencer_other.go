@@ -0,0 +1,24 @@
+//go:build !linux
+// +build !linux
+
+package midiport
+
+import (
+	"errors"
+	"io"
+)
+
+var errNoSequencer = errors.New("sequencer ports are only supported on Linux")
+
+// Errors returns a channel that never receives, there are no sequencer ports that could report errors
+func Errors() <-chan error {
+	return make(chan error)
+}
+
+func openSequencer(name string) (io.ReadWriteCloser, error) {
+	return nil, errNoSequencer
+}
+
+func openVirtual(name string) (io.ReadWriteCloser, error) {
+	return nil, errNoSequencer
+}
@@ -0,0 +1,113 @@
+package smf
+
+import (
+	"io"
+	"time"
+
+	"github.com/sirion/gomidi/lib/midi"
+)
+
+// Clock is the time source of the player and the recorder. It can be replaced for tests.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// SystemClock uses the monotonic system clock
+type SystemClock struct{}
+
+// Now returns the current time including the monotonic clock reading
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep pauses for at least the given duration
+func (SystemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// maxSleep is the longest time the player sleeps before checking whether it was stopped
+const maxSleep = 50 * time.Millisecond
+
+// Player sends the events of a file to a midi port at their time
+type Player struct {
+	Out   io.Writer
+	Clock Clock
+
+	notes map[[2]byte]bool // Playing notes (channel, note)
+}
+
+// NewPlayer creates a player that writes to the given port using the system clock
+func NewPlayer(out io.Writer) *Player {
+	return &Player{Out: out, Clock: SystemClock{}}
+}
+
+// Play sends all events of the file and returns when the last event has been sent or when the stop
+// channel is closed. Notes that are still playing are stopped before returning.
+//
+// The time of every event is calculated from the start of the playback instead of the previous event,
+// so inaccuracies of sleeping and writing do not add up over the length of the file.
+func (p *Player) Play(f *File, stop <-chan struct{}) error {
+	p.notes = make(map[[2]byte]bool)
+	defer p.stopNotes()
+
+	start := p.Clock.Now()
+	for _, event := range f.Events() {
+		message := event.Message()
+		if len(message) == 0 {
+			continue
+		}
+
+		if !p.wait(start.Add(event.Time), stop) {
+			return nil
+		}
+
+		if _, err := p.Out.Write(message); err != nil {
+			return err
+		}
+		p.track(message)
+	}
+
+	return nil
+}
+
+// wait sleeps until the target time, it returns false if the playback was stopped
+func (p *Player) wait(target time.Time, stop <-chan struct{}) bool {
+	for {
+		select {
+		case <-stop:
+			return false
+		default:
+		}
+
+		remaining := target.Sub(p.Clock.Now())
+		if remaining <= 0 {
+			return true
+		}
+		if remaining > maxSleep {
+			remaining = maxSleep
+		}
+		p.Clock.Sleep(remaining)
+	}
+}
+
+// track keeps track of the playing notes
+func (p *Player) track(message []byte) {
+	switch midi.Type(message) {
+	case midi.TypeNoteOn:
+		if message[2] > 0 {
+			p.notes[[2]byte{message[0] & 0x0f, message[1]}] = true
+			return
+		}
+		fallthrough
+	case midi.TypeNoteOff:
+		delete(p.notes, [2]byte{message[0] & 0x0f, message[1]})
+	}
+}
+
+func (p *Player) stopNotes() {
+	for note := range p.notes {
+		p.Out.Write(midi.NoteOff(note[0], note[1], 0))
+	}
+	p.notes = nil
+}
@@ -0,0 +1,97 @@
+package smf
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sirion/gomidi/lib/midi"
+)
+
+// Recorder collects midi messages with the time they were received and converts them into a file
+type Recorder struct {
+	Clock    Clock
+	Division uint16 // Ticks per quarter note
+	Tempo    uint32 // Microseconds per quarter note
+
+	mutex    sync.Mutex
+	start    time.Time
+	messages []recordedMessage
+}
+
+type recordedMessage struct {
+	time    time.Duration
+	message []byte
+}
+
+// NewRecorder creates a recorder with 480 ticks per quarter note at 120 bpm that uses the system clock
+func NewRecorder() *Recorder {
+	return &Recorder{
+		Clock:    SystemClock{},
+		Division: 480,
+		Tempo:    DefaultTempo,
+	}
+}
+
+// Start removes all recorded messages and restarts the time
+func (r *Recorder) Start() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.start = r.Clock.Now()
+	r.messages = nil
+}
+
+// Add records a complete midi message at the current time. Real time messages and system common messages
+// cannot be stored in files and are ignored.
+func (r *Recorder) Add(message []byte) {
+	if len(message) == 0 || message[0] > 0xf0 {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.start.IsZero() {
+		r.start = r.Clock.Now()
+	}
+	r.messages = append(r.messages, recordedMessage{r.Clock.Now().Sub(r.start), message})
+}
+
+// Record reads messages from the port and records them until the port returns an error.
+// Reaching the end of the input is not an error.
+func (r *Recorder) Record(in io.Reader) error {
+	parser := midi.Parser{}
+	buffer := make([]byte, 1024)
+
+	for {
+		read, err := in.Read(buffer)
+		for _, message := range parser.Parse(buffer[:read]) {
+			r.Add(message)
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// File returns a file (format 0) with all recorded messages
+func (r *Recorder) File() *File {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	m := NewTempoMap(r.Division, r.Tempo)
+	track := Track{TempoEvent(0, r.Tempo)}
+
+	var last uint64
+	for _, recorded := range r.messages {
+		tick := m.Tick(recorded.time)
+		track = append(track, Event{uint32(tick - last), recorded.message})
+		last = tick
+	}
+	track = append(track, EndOfTrack(0))
+
+	return &File{Format: 0, Division: r.Division, Tracks: []Track{track}}
+}
@@ -0,0 +1,305 @@
+// Package smf reads and writes Standard MIDI Files (.mid), plays them through a midi port and records
+// midi messages into new files.
+//
+// Events store their data the way it is sent to a port:
+//
+//	midi messages       - the complete message including the status byte (running status is resolved)
+//	system exclusive    - 0xF0, data, 0xF7
+//	escaped data        - 0xF7, raw bytes that are sent as they are
+//	meta events         - 0xFF, meta type, data (without the length)
+package smf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Meta event types
+const (
+	MetaSequenceNumber    byte = 0x00
+	MetaText              byte = 0x01
+	MetaCopyright         byte = 0x02
+	MetaTrackName         byte = 0x03
+	MetaInstrumentName    byte = 0x04
+	MetaLyric             byte = 0x05
+	MetaMarker            byte = 0x06
+	MetaCuePoint          byte = 0x07
+	MetaChannelPrefix     byte = 0x20
+	MetaEndOfTrack        byte = 0x2f
+	MetaTempo             byte = 0x51
+	MetaSMPTEOffset       byte = 0x54
+	MetaTimeSignature     byte = 0x58
+	MetaKeySignature      byte = 0x59
+	MetaSequencerSpecific byte = 0x7f
+)
+
+// DefaultTempo is the tempo in microseconds per quarter note until the first tempo event (120 bpm)
+const DefaultTempo = 500000
+
+// File is a Standard MIDI File
+type File struct {
+	// Format 0 contains a single track, format 1 contains simultaneous tracks and
+	// format 2 contains independent sequences
+	Format uint16
+	// Division is the number of ticks per quarter note. If the highest bit is set, the upper byte
+	// is the negative number of SMPTE frames per second and the lower byte the number of ticks per frame.
+	Division uint16
+	Tracks   []Track
+}
+
+// Track is a list of events
+type Track []Event
+
+// Event is a single event of a track
+type Event struct {
+	Delta uint32 // Ticks since the previous event of the track
+	Data  []byte
+}
+
+// MetaEvent creates a meta event of the given type
+func MetaEvent(delta uint32, metaType byte, data []byte) Event {
+	return Event{delta, append([]byte{0xff, metaType}, data...)}
+}
+
+// TempoEvent creates a tempo change to the given microseconds per quarter note
+func TempoEvent(delta uint32, tempo uint32) Event {
+	return MetaEvent(delta, MetaTempo, []byte{byte(tempo >> 16), byte(tempo >> 8), byte(tempo)})
+}
+
+// EndOfTrack creates the event that has to end every track
+func EndOfTrack(delta uint32) Event {
+	return MetaEvent(delta, MetaEndOfTrack, nil)
+}
+
+// IsMeta returns whether the event is a meta event that is not sent to a port
+func (e Event) IsMeta() bool {
+	return len(e.Data) >= 2 && e.Data[0] == 0xff
+}
+
+// MetaType returns the type of a meta event
+func (e Event) MetaType() byte {
+	if !e.IsMeta() {
+		return 0
+	}
+	return e.Data[1]
+}
+
+// MetaData returns the data of a meta event
+func (e Event) MetaData() []byte {
+	if !e.IsMeta() {
+		return nil
+	}
+	return e.Data[2:]
+}
+
+// Tempo returns the microseconds per quarter note of a tempo event
+func (e Event) Tempo() (uint32, bool) {
+	data := e.MetaData()
+	if e.MetaType() != MetaTempo || len(data) < 3 {
+		return 0, false
+	}
+	return uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2]), true
+}
+
+// Message returns the bytes that are sent to a port for the event, meta events return nil
+func (e Event) Message() []byte {
+	if len(e.Data) == 0 || e.IsMeta() {
+		return nil
+	}
+	if e.Data[0] == 0xf7 {
+		return e.Data[1:]
+	}
+	return e.Data
+}
+
+// ReadFile reads the Standard MIDI File at the given path
+func ReadFile(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Read(f)
+}
+
+// Read reads a Standard MIDI File. Unknown chunks are skipped.
+func Read(r io.Reader) (*File, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkType, header, data, err := readChunk(data)
+	if err != nil {
+		return nil, err
+	}
+	if chunkType != "MThd" || len(header) < 6 {
+		return nil, fmt.Errorf("not a midi file")
+	}
+
+	f := &File{
+		Format:   binary.BigEndian.Uint16(header[0:2]),
+		Division: binary.BigEndian.Uint16(header[4:6]),
+	}
+	trackCount := int(binary.BigEndian.Uint16(header[2:4]))
+
+	for len(data) > 0 && len(f.Tracks) < trackCount {
+		var chunk []byte
+		chunkType, chunk, data, err = readChunk(data)
+		if err != nil {
+			return nil, err
+		}
+		if chunkType != "MTrk" {
+			continue
+		}
+
+		track, err := readTrack(chunk)
+		if err != nil {
+			return nil, fmt.Errorf("track %d: %s", len(f.Tracks)+1, err.Error())
+		}
+		f.Tracks = append(f.Tracks, track)
+	}
+
+	if len(f.Tracks) < trackCount {
+		return nil, fmt.Errorf("expected %d tracks, found %d", trackCount, len(f.Tracks))
+	}
+
+	return f, nil
+}
+
+// readChunk returns the type and data of the first chunk and the remaining data
+func readChunk(data []byte) (string, []byte, []byte, error) {
+	if len(data) < 8 {
+		return "", nil, nil, io.ErrUnexpectedEOF
+	}
+
+	length := binary.BigEndian.Uint32(data[4:8])
+	if uint64(len(data)-8) < uint64(length) {
+		return "", nil, nil, io.ErrUnexpectedEOF
+	}
+
+	return string(data[0:4]), data[8 : 8+length], data[8+length:], nil
+}
+
+func readTrack(data []byte) (Track, error) {
+	r := bytes.NewReader(data)
+	track := Track{}
+	var status byte
+
+	for r.Len() > 0 {
+		delta, err := readVariable(r)
+		if err != nil {
+			return nil, err
+		}
+
+		first, err := r.ReadByte()
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		event := Event{Delta: delta}
+
+		switch {
+		case first == 0xff:
+			// Meta events and system exclusive events cancel the running status
+			status = 0
+			metaType, err := r.ReadByte()
+			if err != nil {
+				return nil, io.ErrUnexpectedEOF
+			}
+			metaData, err := readData(r)
+			if err != nil {
+				return nil, err
+			}
+			event.Data = append([]byte{0xff, metaType}, metaData...)
+
+		case first == 0xf0 || first == 0xf7:
+			status = 0
+			sysex, err := readData(r)
+			if err != nil {
+				return nil, err
+			}
+			event.Data = append([]byte{first}, sysex...)
+
+		case first&0x80 != 0 && first < 0xf0:
+			status = first
+			event.Data, err = readMessage(r, status, nil)
+
+		case first < 0x80 && status != 0:
+			event.Data, err = readMessage(r, status, &first)
+
+		default:
+			return nil, fmt.Errorf("unexpected byte 0x%02x", first)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		track = append(track, event)
+
+		if event.MetaType() == MetaEndOfTrack {
+			break
+		}
+	}
+
+	return track, nil
+}
+
+// readMessage reads the data bytes of a channel message. If running status is used, the first data byte
+// has already been read.
+func readMessage(r *bytes.Reader, status byte, first *byte) ([]byte, error) {
+	length := 2
+	if status&0xf0 == 0xc0 || status&0xf0 == 0xd0 {
+		length = 1
+	}
+
+	message := []byte{status}
+	if first != nil {
+		message = append(message, *first)
+	}
+	for len(message) < length+1 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		message = append(message, b)
+	}
+
+	return message, nil
+}
+
+// readData reads data that is prefixed with its length
+func readData(r *bytes.Reader) ([]byte, error) {
+	length, err := readVariable(r)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(length) > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	data := make([]byte, length)
+	r.Read(data)
+	return data, nil
+}
+
+// readVariable reads a variable length quantity (7 bits per byte, the highest bit is set on all but the last byte)
+func readVariable(r io.ByteReader) (uint32, error) {
+	var value uint32
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+		value = value<<7 | uint32(b&0x7f)
+		if b&0x80 == 0 {
+			return value, nil
+		}
+	}
+	return 0, fmt.Errorf("variable length quantity longer than 4 bytes")
+}
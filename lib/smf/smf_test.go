@@ -0,0 +1,245 @@
+package smf
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirion/gomidi/lib/midi"
+)
+
+// testFile is a format 1 file with 96 ticks per quarter note, a tempo change to 60 bpm after one quarter note
+// and a track using running status
+var testFile = []byte{
+	'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 1, 0, 2, 0, 96,
+	'M', 'T', 'r', 'k', 0, 0, 0, 18,
+	0x00, 0xff, 0x51, 0x03, 0x07, 0xa1, 0x20, // 120 bpm
+	0x60, 0xff, 0x51, 0x03, 0x0f, 0x42, 0x40, // 60 bpm after 96 ticks
+	0x00, 0xff, 0x2f, 0x00,
+	'M', 'T', 'r', 'k', 0, 0, 0, 21,
+	0x00, 0x90, 0x3c, 0x64, // NoteOn C4
+	0x60, 0x3c, 0x00, // Running status: NoteOn C4 velocity 0
+	0x81, 0x40, 0xf0, 0x03, 0x7e, 0x01, 0xf7, // SysEx after 192 ticks
+	0x00, 0xc0, 0x05, // ProgramChange
+	0x00, 0xff, 0x2f, 0x00,
+}
+
+func TestRead(t *testing.T) {
+	f, err := Read(bytes.NewReader(testFile))
+	if err != nil {
+		t.Fatalf("Valid file not read: %s", err.Error())
+	}
+
+	if f.Format != 1 || f.Division != 96 || len(f.Tracks) != 2 {
+		t.Fatalf("Wrong header: format %d, division %d, %d tracks", f.Format, f.Division, len(f.Tracks))
+	}
+
+	expected := Track{
+		{0, midi.NoteOn(0, 60, 100)},
+		{96, midi.NoteOn(0, 60, 0)},
+		{192, []byte{0xf0, 0x7e, 0x01, 0xf7}},
+		{0, midi.ProgramChange(0, 5)},
+		EndOfTrack(0),
+	}
+	if len(f.Tracks[1]) != len(expected) {
+		t.Fatalf("Wrong number of events: Got %d, expected %d", len(f.Tracks[1]), len(expected))
+	}
+	for i, event := range f.Tracks[1] {
+		if event.Delta != expected[i].Delta || !bytes.Equal(event.Data, expected[i].Data) {
+			t.Errorf("Wrong event %d: Got %d %x, expected %d %x", i, event.Delta, event.Data, expected[i].Delta, expected[i].Data)
+		}
+	}
+
+	if tempo, ok := f.Tracks[0][1].Tempo(); !ok || tempo != 1000000 {
+		t.Errorf("Wrong tempo: Got %d", tempo)
+	}
+
+	for _, invalid := range [][]byte{testFile[:10], testFile[:30], []byte("RIFF0000")} {
+		if _, err := Read(bytes.NewReader(invalid)); err == nil {
+			t.Errorf("Invalid file %x was read", invalid)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	f, _ := Read(bytes.NewReader(testFile))
+	f.Tracks = append(f.Tracks, Track{{300, []byte{0xf7, 0xf8}}, MetaEvent(0, MetaTrackName, []byte("Test"))})
+
+	buffer := &bytes.Buffer{}
+	if err := f.Write(buffer); err != nil {
+		t.Fatalf("Error writing file: %s", err.Error())
+	}
+
+	read, err := Read(buffer)
+	if err != nil {
+		t.Fatalf("Written file not readable: %s", err.Error())
+	}
+
+	if len(read.Tracks) != 3 || len(read.Tracks[1]) != len(f.Tracks[1]) {
+		t.Fatalf("Wrong tracks after writing: %v", read.Tracks)
+	}
+	for i, event := range f.Tracks[1] {
+		if event.Delta != read.Tracks[1][i].Delta || !bytes.Equal(event.Data, read.Tracks[1][i].Data) {
+			t.Errorf("Event %d changed by writing: Got %x, expected %x", i, read.Tracks[1][i].Data, event.Data)
+		}
+	}
+
+	// An end of track event is added to the last track
+	last := read.Tracks[2]
+	if len(last) != 3 || last[2].MetaType() != MetaEndOfTrack || !bytes.Equal(last[0].Message(), []byte{0xf8}) || last[0].Delta != 300 {
+		t.Errorf("Wrong last track: %v", last)
+	}
+
+	buffer.Reset()
+	writeVariable(buffer, 0x0fffffff)
+	if !bytes.Equal(buffer.Bytes(), []byte{0xff, 0xff, 0xff, 0x7f}) {
+		t.Errorf("Wrong variable length quantity: %x", buffer.Bytes())
+	}
+}
+
+func TestTempoMap(t *testing.T) {
+	f, _ := Read(bytes.NewReader(testFile))
+	m := f.TempoMap()
+
+	times := map[uint64]time.Duration{
+		0:   0,
+		48:  250 * time.Millisecond,
+		96:  500 * time.Millisecond,
+		192: 1500 * time.Millisecond,
+		288: 2500 * time.Millisecond,
+	}
+	for tick, expected := range times {
+		if got := m.Time(tick); got != expected {
+			t.Errorf("Wrong time of tick %d: Got %s, expected %s", tick, got, expected)
+		}
+		if got := m.Tick(expected); got != tick {
+			t.Errorf("Wrong tick at %s: Got %d, expected %d", expected, got, tick)
+		}
+	}
+
+	events := f.Events()
+	if len(events) != 8 || events[5].Time != 2500*time.Millisecond || events[5].Track != 1 {
+		t.Errorf("Wrong events: %v", events)
+	}
+
+	smpte := NewTempoMap(0xe728, DefaultTempo) // 25 frames per second, 40 ticks per frame
+	if got := smpte.Time(1000); got != time.Second {
+		t.Errorf("Wrong SMPTE time: Got %s, expected 1s", got)
+	}
+}
+
+// testClock advances its time only when sleeping. Every sleep takes a little longer than requested.
+type testClock struct {
+	mutex sync.Mutex
+	now   time.Time
+	late  time.Duration
+}
+
+func (c *testClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *testClock) Sleep(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d + c.late)
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.Sleep(d - c.late)
+}
+
+// timedWriter records the clock time of every write
+type timedWriter struct {
+	clock    Clock
+	times    []time.Time
+	messages [][]byte
+}
+
+func (w *timedWriter) Write(data []byte) (int, error) {
+	w.times = append(w.times, w.clock.Now())
+	w.messages = append(w.messages, append([]byte{}, data...))
+	return len(data), nil
+}
+
+func TestPlayer(t *testing.T) {
+	start := time.Date(2019, 2, 1, 12, 0, 0, 0, time.UTC)
+	clock := &testClock{now: start, late: 3 * time.Millisecond}
+	out := &timedWriter{clock: clock}
+	player := &Player{Out: out, Clock: clock}
+
+	// Many short notes would add up the sleeping inaccuracy without drift correction
+	track := Track{TempoEvent(0, DefaultTempo)}
+	for i := 0; i < 100; i++ {
+		track = append(track, Event{10, midi.NoteOn(0, 60, 100)}, Event{10, midi.NoteOff(0, 60, 0)})
+	}
+	track = append(track, Event{10, midi.NoteOn(0, 62, 100)})
+
+	if err := player.Play(&File{0, 96, []Track{track}}, nil); err != nil {
+		t.Fatalf("Error playing: %s", err.Error())
+	}
+
+	m := NewTempoMap(96, DefaultTempo)
+	if len(out.messages) != 202 {
+		t.Fatalf("Wrong number of messages: Got %d, expected 202", len(out.messages))
+	}
+	for i, at := range out.times[:201] {
+		expected := start.Add(m.Time(uint64(i+1) * 10))
+		if late := at.Sub(expected); late < 0 || late > clock.late {
+			t.Errorf("Message %d sent %s late", i, late)
+		}
+	}
+
+	// The note that is still playing is stopped at the end
+	if !bytes.Equal(out.messages[201], midi.NoteOff(0, 62, 0)) {
+		t.Errorf("Playing note not stopped: Got %x", out.messages[201])
+	}
+}
+
+func TestPlayerStop(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	out := &timedWriter{clock: clock}
+	player := &Player{Out: out, Clock: clock}
+
+	stop := make(chan struct{})
+	close(stop)
+
+	track := Track{{0, midi.NoteOn(0, 60, 100)}, {96, midi.NoteOff(0, 60, 0)}}
+	player.Play(&File{0, 96, []Track{track}}, stop)
+
+	if len(out.messages) != 0 {
+		t.Errorf("Stopped player sent messages: %x", out.messages)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	recorder := &Recorder{Clock: clock, Division: 96, Tempo: DefaultTempo}
+	recorder.Start()
+
+	clock.Advance(250 * time.Millisecond)
+	recorder.Add(midi.NoteOn(1, 60, 100))
+	recorder.Add([]byte{0xf8})
+	clock.Advance(time.Second)
+	recorder.Record(bytes.NewReader(append(midi.NoteOff(1, 60, 0), 0xf0, 0x01, 0xf7)))
+
+	f := recorder.File()
+	expected := Track{
+		TempoEvent(0, DefaultTempo),
+		{48, midi.NoteOn(1, 60, 100)},
+		{192, midi.NoteOff(1, 60, 0)},
+		{0, []byte{0xf0, 0x01, 0xf7}},
+		EndOfTrack(0),
+	}
+	if f.Format != 0 || f.Division != 96 || len(f.Tracks) != 1 || len(f.Tracks[0]) != len(expected) {
+		t.Fatalf("Wrong file: %v", f)
+	}
+	for i, event := range f.Tracks[0] {
+		if event.Delta != expected[i].Delta || !bytes.Equal(event.Data, expected[i].Data) {
+			t.Errorf("Wrong event %d: Got %d %x, expected %d %x", i, event.Delta, event.Data, expected[i].Delta, expected[i].Data)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package smf
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// TempoMap converts between ticks and time using the tempo changes of a file
+type TempoMap struct {
+	division uint16
+	changes  []tempoChange
+}
+
+type tempoChange struct {
+	tick  uint64
+	time  time.Duration
+	tempo uint32 // Microseconds per quarter note
+}
+
+// TimedEvent is an event with its absolute position in the file
+type TimedEvent struct {
+	Event
+	Track int
+	Tick  uint64
+	Time  time.Duration
+}
+
+// NewTempoMap creates a tempo map with a constant tempo in microseconds per quarter note
+func NewTempoMap(division uint16, tempo uint32) *TempoMap {
+	return &TempoMap{division, []tempoChange{{0, 0, tempo}}}
+}
+
+// TempoMap returns the tempo map of the file. Tempo events of all tracks are used.
+func (f *File) TempoMap() *TempoMap {
+	m := NewTempoMap(f.Division, DefaultTempo)
+
+	type change struct {
+		tick  uint64
+		tempo uint32
+	}
+	changes := []change{}
+	for _, track := range f.Tracks {
+		var tick uint64
+		for _, event := range track {
+			tick += uint64(event.Delta)
+			if tempo, ok := event.Tempo(); ok && tempo > 0 {
+				changes = append(changes, change{tick, tempo})
+			}
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].tick < changes[j].tick })
+
+	for _, c := range changes {
+		last := m.changes[len(m.changes)-1]
+		if c.tick == last.tick {
+			m.changes[len(m.changes)-1].tempo = c.tempo
+			continue
+		}
+		m.changes = append(m.changes, tempoChange{c.tick, m.Time(c.tick), c.tempo})
+	}
+
+	return m
+}
+
+// Time returns the time of the given tick since the start of the file
+func (m *TempoMap) Time(tick uint64) time.Duration {
+	c := m.changes[0]
+	for _, change := range m.changes[1:] {
+		if change.tick > tick {
+			break
+		}
+		c = change
+	}
+
+	return c.time + m.duration(tick-c.tick, c.tempo)
+}
+
+// Tick returns the tick at the given time since the start of the file, rounded to the nearest tick
+func (m *TempoMap) Tick(t time.Duration) uint64 {
+	c := m.changes[0]
+	for _, change := range m.changes[1:] {
+		if change.time > t {
+			break
+		}
+		c = change
+	}
+
+	// The duration of a million ticks keeps the precision of fractional tick durations
+	ticks := float64(t-c.time) * 1000000 / float64(m.duration(1000000, c.tempo))
+	if ticks < 0 {
+		return c.tick
+	}
+	return c.tick + uint64(math.Round(ticks))
+}
+
+// duration returns the duration of the given number of ticks
+func (m *TempoMap) duration(ticks uint64, tempo uint32) time.Duration {
+	if m.division&0x8000 != 0 {
+		// SMPTE time: The tempo does not matter, 29 frames per second means 29.97 (drop frame)
+		framesPerSecond := uint64(-int8(m.division >> 8))
+		ticksPerFrame := uint64(m.division & 0xff)
+		if framesPerSecond == 0 || ticksPerFrame == 0 {
+			framesPerSecond, ticksPerFrame = 25, 40
+		}
+		if framesPerSecond == 29 {
+			return time.Duration(ticks * uint64(time.Second) * 100 / (2997 * ticksPerFrame))
+		}
+		return time.Duration(ticks * uint64(time.Second) / (framesPerSecond * ticksPerFrame))
+	}
+
+	division := uint64(m.division)
+	if division == 0 {
+		division = 1
+	}
+	return time.Duration(ticks * uint64(tempo) * uint64(time.Microsecond) / division)
+}
+
+// Events returns the events of all tracks sorted by time. Events at the same tick keep the order of
+// their tracks.
+func (f *File) Events() []TimedEvent {
+	m := f.TempoMap()
+
+	events := []TimedEvent{}
+	for i, track := range f.Tracks {
+		var tick uint64
+		for _, event := range track {
+			tick += uint64(event.Delta)
+			events = append(events, TimedEvent{event, i, tick, m.Time(tick)})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Tick < events[j].Tick })
+
+	return events
+}
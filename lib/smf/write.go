@@ -0,0 +1,98 @@
+package smf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// WriteFile writes the file to the given path
+func (f *File) WriteFile(path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = f.Write(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Write writes the file in the Standard MIDI File format. Tracks that do not end with an
+// end of track event get one. Running status is not used.
+func (f *File) Write(w io.Writer) error {
+	header := make([]byte, 6)
+	binary.BigEndian.PutUint16(header[0:2], f.Format)
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(f.Tracks)))
+	binary.BigEndian.PutUint16(header[4:6], f.Division)
+
+	if err := writeChunk(w, "MThd", header); err != nil {
+		return err
+	}
+
+	for _, track := range f.Tracks {
+		if err := writeChunk(w, "MTrk", track.bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeChunk(w io.Writer, chunkType string, data []byte) error {
+	header := make([]byte, 8)
+	copy(header, chunkType)
+	binary.BigEndian.PutUint32(header[4:8], uint32(len(data)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func (t Track) bytes() []byte {
+	buffer := &bytes.Buffer{}
+
+	for _, event := range t {
+		if len(event.Data) == 0 {
+			continue
+		}
+
+		writeVariable(buffer, event.Delta)
+		switch {
+		case event.IsMeta():
+			buffer.Write(event.Data[:2])
+			writeVariable(buffer, uint32(len(event.Data)-2))
+			buffer.Write(event.Data[2:])
+		case event.Data[0] == 0xf0 || event.Data[0] == 0xf7:
+			buffer.WriteByte(event.Data[0])
+			writeVariable(buffer, uint32(len(event.Data)-1))
+			buffer.Write(event.Data[1:])
+		default:
+			buffer.Write(event.Data)
+		}
+	}
+
+	if len(t) == 0 || t[len(t)-1].MetaType() != MetaEndOfTrack {
+		buffer.Write([]byte{0x00, 0xff, MetaEndOfTrack, 0x00})
+	}
+
+	return buffer.Bytes()
+}
+
+// writeVariable writes a variable length quantity
+func writeVariable(buffer *bytes.Buffer, value uint32) {
+	if value > 0x0fffffff {
+		value = 0x0fffffff
+	}
+
+	data := []byte{byte(value & 0x7f)}
+	for value >>= 7; value > 0; value >>= 7 {
+		data = append([]byte{byte(value&0x7f) | 0x80}, data...)
+	}
+	buffer.Write(data)
+}